@@ -0,0 +1,141 @@
+package sqrl
+
+import (
+	"bytes"
+	"sync"
+)
+
+// SqlAppender is the interface that wraps the AppendSql method.
+//
+// AppendSql writes a SQL representation of the Sqlizer to buf and returns args
+// with the Sqlizer's own args appended. Unlike ToSql it doesn't allocate an
+// intermediate string, and placeholders are always written as question marks:
+// the placeholder format is applied once, when the top-level statement is
+// rendered.
+//
+// All builders and expressions of sqrl implement SqlAppender. Sqlizers that
+// don't are rendered with ToSql.
+type SqlAppender interface {
+	AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error)
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return &bytes.Buffer{} },
+}
+
+// maxPooledBufferSize limits the size of buffers returned to the pool, so that
+// a single huge statement doesn't pin its memory forever.
+const maxPooledBufferSize = 64 << 10
+
+func getBuffer() *bytes.Buffer {
+	return bufferPool.Get().(*bytes.Buffer)
+}
+
+func putBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	buf.Reset()
+	bufferPool.Put(buf)
+}
+
+// appendSql writes s to buf, using AppendSql if s implements SqlAppender.
+func appendSql(buf *bytes.Buffer, s Sqlizer, args []interface{}) ([]interface{}, error) {
+	if a, ok := s.(SqlAppender); ok {
+		return a.AppendSql(buf, args)
+	}
+
+	sql, sArgs, err := s.ToSql()
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteString(sql)
+	return append(args, sArgs...), nil
+}
+
+// appendToSql writes parts to buf separated by sep. Parts that render to an
+// empty string are skipped.
+func appendToSql(parts []Sqlizer, buf *bytes.Buffer, sep string, args []interface{}) ([]interface{}, error) {
+	written := false
+	for _, p := range parts {
+		start, argc := buf.Len(), len(args)
+		if written {
+			buf.WriteString(sep)
+		}
+		partStart := buf.Len()
+
+		var err error
+		args, err = appendSql(buf, p, args)
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() == partStart {
+			buf.Truncate(start)
+			args = args[:argc]
+			continue
+		}
+		written = true
+	}
+	return args, nil
+}
+
+// writeStrings writes s to buf separated by sep. Unlike strings.Join it doesn't
+// allocate.
+func writeStrings(buf *bytes.Buffer, s []string, sep string) {
+	for i, str := range s {
+		if i > 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteString(str)
+	}
+}
+
+// appenderToSql renders a into a pooled buffer and returns the result as ToSql
+// does, without applying any placeholder format.
+func appenderToSql(a SqlAppender) (string, []interface{}, error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	args, err := a.AppendSql(buf, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return buf.String(), args, nil
+}
+
+// statementToSql renders a top-level statement and the tags of sb into a
+// pooled buffer and applies the placeholder format of sb while copying the
+// result out of the buffer. If sb interpolates, args are inlined instead.
+func statementToSql(a SqlAppender, sb StatementBuilderType) (string, []interface{}, error) {
+	if len(sb.rewriters) > 0 {
+		s, err := rewrite(a.(Sqlizer), sb)
+		if err != nil {
+			return "", nil, err
+		}
+		a = s.(SqlAppender)
+	}
+
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	args, err := a.AppendSql(buf, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	sb.tags.appendComment(buf)
+
+	if sb.interpolate != 0 {
+		sql, err := sb.interpolate.interpolate(buf.String(), args, replacePlaceholders, false)
+		if err != nil {
+			return "", nil, err
+		}
+		return sql, nil, nil
+	}
+
+	sql, err := replacePlaceholdersBytes(sb.placeholderFormat, buf.Bytes())
+	if err != nil {
+		return "", nil, err
+	}
+	return sql, args, nil
+}
@@ -0,0 +1,153 @@
+package sqrl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqlAppenderImplementations(t *testing.T) {
+	appenders := []Sqlizer{
+		Select(), Insert(""), Update(""), Delete(), Case(),
+		Expr(""), Alias(Expr(""), ""), Eq{}, NotEq{}, Lt{}, LtOrEq{}, Gt{}, GtOrEq{}, And{}, Or{},
+		newPart(""), NewWherePart(""),
+	}
+	for _, a := range appenders {
+		_, ok := a.(SqlAppender)
+		assert.True(t, ok, "%T should implement SqlAppender", a)
+	}
+}
+
+type toSqlOnly struct {
+	sql  string
+	args []interface{}
+}
+
+func (s toSqlOnly) ToSql() (string, []interface{}, error) {
+	return s.sql, s.args, nil
+}
+
+func TestAppendSqlFallsBackToToSql(t *testing.T) {
+	sql, args, err := Select("a").From("b").Where(toSqlOnly{"c = ?", []interface{}{1}}).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c = ?", sql)
+	assert.Equal(t, []interface{}{1}, args)
+}
+
+func TestAppendSqlArgs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	args, err := Eq{"a": []int{1, 2}}.AppendSql(buf, []interface{}{0})
+	assert.NoError(t, err)
+	assert.Equal(t, "a IN (?,?)", buf.String())
+	assert.Equal(t, []interface{}{0, 1, 2}, args)
+}
+
+func TestAppendToSqlSkipsEmptyParts(t *testing.T) {
+	sql, args, err := Select("a").From("b").Where(Eq{}).Where("c = ?", 1).Where(And{}).Where("d").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c = ? AND d", sql)
+	assert.Equal(t, []interface{}{1}, args)
+}
+
+func TestNestedPlaceholdersNumberedOnce(t *testing.T) {
+	subQ := Select("c").From("d").Where("e = ?", 1).PlaceholderFormat(Dollar)
+	sql, args, err := Select("a").
+		FromSelect(subQ, "f").
+		Where(Expr("g IN (?)", Select("h").From("i").Where("j = ?", 2))).
+		Where("k = ? AND l ?? m", 3).
+		PlaceholderFormat(Dollar).
+		ToSql()
+
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT c FROM d WHERE e = $1) AS f WHERE g IN (SELECT h FROM i WHERE j = $2) AND k = $3 AND l ? m", sql)
+	assert.Equal(t, []interface{}{1, 2, 3}, args)
+}
+
+func TestExprWithSqlizerArgsUnescapesPlaceholders(t *testing.T) {
+	e := Expr("a ?? b AND c IN (?)", Select("d").From("e").Where("f = ?", 1))
+
+	sql, args, err := e.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "a ? b AND c IN (SELECT d FROM e WHERE f = ?)", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, _, err = Select("g").From("h").Where(e).Where("i ?? j").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT g FROM h WHERE a ? b AND c IN (SELECT d FROM e WHERE f = ?) AND i ?? j", sql)
+}
+
+func TestDollarReplaceBytes(t *testing.T) {
+	sql := "x = ? AND y ?? z AND w IN (?,?,?,?,?,?,?,?,?,?)"
+	expected, _ := Dollar.ReplacePlaceholders(sql)
+	assert.Equal(t, expected, Dollar.replaceBytes([]byte(sql)))
+	assert.Equal(t, "no placeholders", Dollar.replaceBytes([]byte("no placeholders")))
+}
+
+func benchmarkToSql(b *testing.B, s Sqlizer) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := s.ToSql(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToSqlSelectSimple(b *testing.B) {
+	benchmarkToSql(b, Select("a", "b").From("c").Where(Eq{"d": 1}).Limit(10))
+}
+
+func BenchmarkToSqlSelectComplex(b *testing.B) {
+	benchmarkToSql(b, Select("a", "b").
+		Prefix("WITH prefix AS ?", 0).
+		Distinct().
+		Columns("c").
+		Column("IF(d IN ("+Placeholders(3)+"), 1, 0) as stat_column", 1, 2, 3).
+		Column(Expr("a > ?", 100)).
+		Column(Alias(Eq{"b": []int{101, 102, 103}}, "b_alias")).
+		Column(Alias(Select("aa", "bb").From("dd"), "subq")).
+		From("e").
+		Join("j2").
+		LeftJoin("j3").
+		Where("f = ?", 4).
+		Where(Eq{"g": 5}).
+		Where(Eq{"i": []int{7, 8, 9}}).
+		Where(Or{Expr("j = ?", 10), And{Eq{"k": 11}, Expr("true")}}).
+		GroupBy("l").
+		Having("m = n").
+		OrderBy("o ASC", "p DESC").
+		Limit(12).
+		Offset(13).
+		Suffix("FETCH FIRST ? ROWS ONLY", 14))
+}
+
+func BenchmarkToSqlSelectDollar(b *testing.B) {
+	benchmarkToSql(b, Select("a", "b").
+		From("c").
+		Where(Eq{"d": []int{1, 2, 3, 4, 5}}).
+		Where("e > ? AND f < ?", 6, 7).
+		PlaceholderFormat(Dollar))
+}
+
+func BenchmarkToSqlInsert(b *testing.B) {
+	benchmarkToSql(b, Insert("a").
+		Columns("b", "c", "d").
+		Values(1, 2, 3).
+		Values(4, Expr("? + 1", 5), 6).
+		PlaceholderFormat(Dollar))
+}
+
+func BenchmarkToSqlUpdate(b *testing.B) {
+	benchmarkToSql(b, Update("a").
+		Set("b", 1).
+		Set("c", Expr("c + ?", 2)).
+		Where(Eq{"d": 3}).
+		PlaceholderFormat(Dollar))
+}
+
+func BenchmarkToSqlDelete(b *testing.B) {
+	benchmarkToSql(b, Delete("a").
+		Where(Eq{"b": []int{1, 2, 3}}).
+		Where(Lt{"c": 4}).
+		PlaceholderFormat(Dollar))
+}
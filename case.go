@@ -8,7 +8,7 @@ import (
 // sqlizerBuffer is a helper that allows to write many Sqlizers one by one
 // without constant checks for errors that may come from Sqlizer
 type sqlizerBuffer struct {
-	bytes.Buffer
+	*bytes.Buffer
 	args []interface{}
 	err  error
 }
@@ -19,21 +19,12 @@ func (b *sqlizerBuffer) WriteSql(item Sqlizer) {
 		return
 	}
 
-	var str string
-	var args []interface{}
-	str, args, b.err = item.ToSql()
-
+	b.args, b.err = appendSql(b.Buffer, item, b.args)
 	if b.err != nil {
 		return
 	}
 
-	b.WriteString(str)
 	b.WriteByte(' ')
-	b.args = append(b.args, args...)
-}
-
-func (b *sqlizerBuffer) ToSql() (string, []interface{}, error) {
-	return b.String(), b.args, b.err
 }
 
 // whenPart is a helper structure to describe SQLs "WHEN ... THEN ..." expression
@@ -51,17 +42,56 @@ type CaseBuilder struct {
 	whatPart  Sqlizer
 	whenParts []whenPart
 	elsePart  Sqlizer
+
+	// immutable makes every method return a modified copy, see
+	// StatementBuilderType.Immutable.
+	immutable bool
+}
+
+// derive returns the builder that a modification should be applied to: b
+// itself, or a copy of b if the builder is immutable.
+//
+// The copy shares when parts with b, but their capacity is capped so that
+// appending to them always allocates.
+func (b *CaseBuilder) derive() *CaseBuilder {
+	if !b.immutable {
+		return b
+	}
+	c := *b
+	c.whenParts = c.whenParts[:len(c.whenParts):len(c.whenParts)]
+	return &c
+}
+
+// Clone returns a deep copy of the builder.
+func (b *CaseBuilder) Clone() *CaseBuilder {
+	c := *b
+	if b.whatPart != nil {
+		c.whatPart = cloneSqlizer(b.whatPart)
+	}
+	if b.whenParts != nil {
+		c.whenParts = make([]whenPart, len(b.whenParts))
+		for i, p := range b.whenParts {
+			c.whenParts[i] = whenPart{when: cloneSqlizer(p.when), then: cloneSqlizer(p.then)}
+		}
+	}
+	if b.elsePart != nil {
+		c.elsePart = cloneSqlizer(b.elsePart)
+	}
+	return &c
 }
 
 // ToSql implements Sqlizer
 func (b *CaseBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
-	if len(b.whenParts) == 0 {
-		err = errors.New("case expression must contain at lease one WHEN clause")
+	return appenderToSql(b)
+}
 
-		return
+// AppendSql implements SqlAppender
+func (b *CaseBuilder) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	if len(b.whenParts) == 0 {
+		return nil, errors.New("case expression must contain at lease one WHEN clause")
 	}
 
-	sql := sqlizerBuffer{}
+	sql := sqlizerBuffer{Buffer: buf, args: args}
 
 	sql.WriteString("CASE ")
 	if b.whatPart != nil {
@@ -82,11 +112,15 @@ func (b *CaseBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 
 	sql.WriteString("END")
 
-	return sql.ToSql()
+	if sql.err != nil {
+		return nil, sql.err
+	}
+	return sql.args, nil
 }
 
 // what sets optional value for CASE construct "CASE [value] ..."
 func (b *CaseBuilder) what(expr interface{}) *CaseBuilder {
+	b = b.derive()
 	b.whatPart = newPart(expr)
 	return b
 }
@@ -95,12 +129,14 @@ func (b *CaseBuilder) what(expr interface{}) *CaseBuilder {
 func (b *CaseBuilder) When(when interface{}, then interface{}) *CaseBuilder {
 	// TODO: performance hint: replace slice of WhenPart with just slice of parts
 	// where even indices of the slice belong to "when"s and odd indices belong to "then"s
+	b = b.derive()
 	b.whenParts = append(b.whenParts, newWhenPart(when, then))
 	return b
 }
 
 // Else sets optional "ELSE ..." part for CASE construct
 func (b *CaseBuilder) Else(expr interface{}) *CaseBuilder {
+	b = b.derive()
 	b.elsePart = newPart(expr)
 	return b
 
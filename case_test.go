@@ -139,3 +139,17 @@ func TestCaseWithNoWhenClause(t *testing.T) {
 
 	assert.Equal(t, "case expression must contain at lease one WHEN clause", err.Error())
 }
+
+func TestCaseClone(t *testing.T) {
+	base := Case("x").When("1", "'one'")
+
+	clone := base.Clone().When("2", "'two'").Else("'many'")
+
+	sql, _, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "CASE x WHEN 1 THEN 'one' END", sql)
+
+	sql, _, err = clone.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "CASE x WHEN 1 THEN 'one' WHEN 2 THEN 'two' ELSE 'many' END", sql)
+}
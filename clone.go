@@ -0,0 +1,73 @@
+package sqrl
+
+// cloneSqlizer returns a copy of s that shares no mutable state with the
+// original. Builders and expressions known to sqrl are copied recursively,
+// other Sqlizers are returned as is.
+func cloneSqlizer(s Sqlizer) Sqlizer {
+	switch s := s.(type) {
+	case *SelectBuilder:
+		return s.Clone()
+	case *InsertBuilder:
+		return s.Clone()
+	case *UpdateBuilder:
+		return s.Clone()
+	case *DeleteBuilder:
+		return s.Clone()
+	case *CaseBuilder:
+		return s.Clone()
+	case *part:
+		return &part{pred: cloneValue(s.pred), args: cloneValues(s.args)}
+	case *WherePart:
+		return &WherePart{pred: cloneValue(s.pred), args: cloneValues(s.args)}
+	case expr:
+		return s.clone()
+	case aliasExpr:
+		return aliasExpr{expr: cloneSqlizer(s.expr), alias: s.alias}
+	case And:
+		return And(cloneSqlizers(s))
+	case Or:
+		return Or(cloneSqlizers(s))
+	default:
+		return s
+	}
+}
+
+// cloneSqlizers copies a slice of Sqlizers, see cloneSqlizer.
+func cloneSqlizers(parts []Sqlizer) []Sqlizer {
+	if parts == nil {
+		return nil
+	}
+	cloned := make([]Sqlizer, len(parts))
+	for i, p := range parts {
+		cloned[i] = cloneSqlizer(p)
+	}
+	return cloned
+}
+
+// cloneValue copies v if it is a Sqlizer, plain values are returned as is.
+func cloneValue(v interface{}) interface{} {
+	if s, ok := v.(Sqlizer); ok {
+		return cloneSqlizer(s)
+	}
+	return v
+}
+
+// cloneValues copies a slice of query arguments, see cloneValue.
+func cloneValues(values []interface{}) []interface{} {
+	if values == nil {
+		return nil
+	}
+	cloned := make([]interface{}, len(values))
+	for i, v := range values {
+		cloned[i] = cloneValue(v)
+	}
+	return cloned
+}
+
+// cloneStrings copies a slice of strings.
+func cloneStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(s)), s...)
+}
@@ -0,0 +1,18 @@
+package sqrl
+
+import "fmt"
+
+// DebugSql returns the SQL of s with its args inlined as literals of dialect,
+// so that it can be copied from logs into psql or the mysql client.
+//
+// The result is meant for humans only and must never be executed: values that
+// can't be encoded are inlined as strings, and the SQL is preceded by a
+// comment that marks it as debug output. Use ToSql or Interpolate to run
+// queries.
+func DebugSql(s Sqlizer, dialect Dialect) string {
+	sql, err := dialect.inlineArgs(s, true)
+	if err != nil {
+		return fmt.Sprintf("-- sqrl.DebugSql: %v", err)
+	}
+	return "-- sqrl.DebugSql: for debugging only, do not execute\n" + sql
+}
@@ -0,0 +1,110 @@
+package sqrl
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const debugHeader = "-- sqrl.DebugSql: for debugging only, do not execute\n"
+
+type status string
+
+type valuerStub struct {
+	value interface{}
+	err   error
+}
+
+func (v valuerStub) Value() (driver.Value, error) {
+	return v.value, v.err
+}
+
+func TestDebugSql(t *testing.T) {
+	ts := time.Date(2020, 1, 2, 3, 4, 5, 600000000, time.FixedZone("", 2*3600))
+	s := Select("*").
+		From("users").
+		Where(Eq{
+			"name":    "O'Brien",
+			"id":      []int{1, 2},
+			"deleted": nil,
+		}).
+		Where("active = ? AND score > ? AND created < ? AND avatar = ?", true, 1.5, ts, []byte{0xde, 0xad}).
+		Where("data ?? 'key'").
+		PlaceholderFormat(Dollar)
+
+	assert.Equal(t, debugHeader+
+		"SELECT * FROM users WHERE deleted IS NULL AND id IN (1,2) AND name = 'O''Brien' "+
+		"AND active = TRUE AND score > 1.5 AND created < '2020-01-02 03:04:05.6+02:00'::timestamptz "+
+		`AND avatar = E'\\xdead'::bytea AND data ? 'key'`,
+		DebugSql(s, PostgreSQL))
+
+	assert.Equal(t, debugHeader+
+		"SELECT * FROM users WHERE deleted IS NULL AND id IN (1,2) AND name = 'O''Brien' "+
+		"AND active = TRUE AND score > 1.5 AND created < '2020-01-02 01:04:05.6' "+
+		"AND avatar = X'DEAD' AND data ? 'key'",
+		DebugSql(s, MySQL))
+
+	assert.Equal(t, debugHeader+
+		"SELECT * FROM users WHERE deleted IS NULL AND id IN (1,2) AND name = 'O''Brien' "+
+		"AND active = 1 AND score > 1.5 AND created < '2020-01-02 03:04:05.6+02:00' "+
+		"AND avatar = X'DEAD' AND data ? 'key'",
+		DebugSql(s, SQLite))
+}
+
+func TestDebugSqlValues(t *testing.T) {
+	name := "moe"
+	var nilName *string
+	testCases := []struct {
+		value  interface{}
+		pg     string
+		mysql  string
+		sqlite string
+	}{
+		{nil, "NULL", "NULL", "NULL"},
+		{[]byte(nil), "NULL", "NULL", "NULL"},
+		{false, "FALSE", "FALSE", "0"},
+		{int8(-8), "-8", "-8", "-8"},
+		{uint64(math.MaxUint64), "18446744073709551615", "18446744073709551615", "18446744073709551615"},
+		{float32(0.1), "0.1", "0.1", "0.1"},
+		{math.Inf(-1), "'-Infinity'::float8", "'-Inf'", "'-Inf'"},
+		{`a\b`, `E'a\\b'`, `'a\\b'`, `'a\b'`},
+		{"line\nbreak", "'line\nbreak'", `'line\nbreak'`, "'line\nbreak'"},
+		{status("new"), "'new'", "'new'", "'new'"},
+		{&name, "'moe'", "'moe'", "'moe'"},
+		{nilName, "NULL", "NULL", "NULL"},
+		{sql.NullString{String: "x", Valid: true}, "'x'", "'x'", "'x'"},
+		{sql.NullInt64{}, "NULL", "NULL", "NULL"},
+		{valuerStub{value: int64(7)}, "7", "7", "7"},
+		{valuerStub{err: errors.New("boom")}, "'!(boom)'", "'!(boom)'", "'!(boom)'"},
+		{struct{ A int }{1}, "'{1}'", "'{1}'", "'{1}'"},
+	}
+
+	for _, tc := range testCases {
+		for dialect, expected := range map[Dialect]string{PostgreSQL: tc.pg, MySQL: tc.mysql, SQLite: tc.sqlite} {
+			actual := strings.TrimPrefix(DebugSql(Expr("?", tc.value), dialect), debugHeader)
+			assert.Equal(t, expected, actual, "%#v as %s", tc.value, dialect)
+		}
+	}
+}
+
+func TestDebugSqlErrors(t *testing.T) {
+	assert.Equal(t, "-- sqrl.DebugSql: insert statements must specify a table", DebugSql(Insert(""), PostgreSQL))
+	assert.Equal(t, "-- sqrl.DebugSql: 1 args given for 0 placeholders", DebugSql(Expr("a", 1), PostgreSQL))
+	assert.Equal(t, "-- sqrl.DebugSql: not enough args for placeholder 2: 1 given", DebugSql(Expr("? ?", 1), PostgreSQL))
+}
+
+type dollarSqlizer struct{}
+
+func (dollarSqlizer) ToSql() (string, []interface{}, error) {
+	return "SELECT $2, $1, '$'", []interface{}{1, "a"}, nil
+}
+
+func TestDebugSqlDollarSqlizer(t *testing.T) {
+	assert.Equal(t, debugHeader+"SELECT 'a', 1, '$'", DebugSql(dollarSqlizer{}, PostgreSQL))
+}
@@ -6,7 +6,6 @@ import (
 	"database/sql"
 	"fmt"
 	"strconv"
-	"strings"
 )
 
 // Builder
@@ -31,6 +30,9 @@ type DeleteBuilder struct {
 	offsetValid bool
 
 	suffixes exprs
+
+	// unscoped disables the scopes of the query, see Unscoped.
+	unscoped bool
 }
 
 // NewDeleteBuilder creates new instance of DeleteBuilder
@@ -38,9 +40,48 @@ func NewDeleteBuilder(b StatementBuilderType) *DeleteBuilder {
 	return &DeleteBuilder{StatementBuilderType: b}
 }
 
-// RunWith sets a Runner (like database/sql.DB) to be used with e.g. Exec.
-func (b *DeleteBuilder) RunWith(runner BaseRunner) *DeleteBuilder {
-	b.runWith = wrapRunner(runner)
+// Clone returns a deep copy of the builder.
+//
+// Modifying the clone, including subqueries added with UsingSelect, never
+// affects the original builder and vice versa.
+func (b *DeleteBuilder) Clone() *DeleteBuilder {
+	c := *b
+	c.returning = b.returning.clone()
+	c.prefixes = b.prefixes.clone()
+	c.what = cloneStrings(b.what)
+	c.joins = cloneStrings(b.joins)
+	c.usingParts = cloneSqlizers(b.usingParts)
+	c.whereParts = cloneSqlizers(b.whereParts)
+	c.orderBys = cloneStrings(b.orderBys)
+	c.suffixes = b.suffixes.clone()
+	return &c
+}
+
+// derive returns the builder that a modification should be applied to: b
+// itself, or a copy of b if the builder is immutable.
+//
+// The copy shares clause slices with b, but their capacity is capped so that
+// appending to them always allocates.
+func (b *DeleteBuilder) derive() *DeleteBuilder {
+	if !b.immutable {
+		return b
+	}
+	c := *b
+	c.returning = c.returning[:len(c.returning):len(c.returning)]
+	c.prefixes = c.prefixes[:len(c.prefixes):len(c.prefixes)]
+	c.what = c.what[:len(c.what):len(c.what)]
+	c.joins = c.joins[:len(c.joins):len(c.joins)]
+	c.usingParts = c.usingParts[:len(c.usingParts):len(c.usingParts)]
+	c.whereParts = c.whereParts[:len(c.whereParts):len(c.whereParts)]
+	c.orderBys = c.orderBys[:len(c.orderBys):len(c.orderBys)]
+	c.suffixes = c.suffixes[:len(c.suffixes):len(c.suffixes)]
+	return &c
+}
+
+// RunWith sets a Runner (like database/sql.DB or sql.Conn) to be used with e.g. Exec.
+func (b *DeleteBuilder) RunWith(runner BaseRunnerContext) *DeleteBuilder {
+	b = b.derive()
+	b.runWith = adaptRunner(runner)
 	return b
 }
 
@@ -95,21 +136,47 @@ func (b *DeleteBuilder) Scan(dest ...interface{}) error {
 // PlaceholderFormat sets PlaceholderFormat (e.g. Question or Dollar) for the
 // query.
 func (b *DeleteBuilder) PlaceholderFormat(f PlaceholderFormat) *DeleteBuilder {
+	b = b.derive()
 	b.placeholderFormat = f
 	return b
 }
 
 // ToSql builds the query into a SQL string and bound args.
 func (b *DeleteBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
+	return statementToSql(b, b.StatementBuilderType)
+}
+
+// Tag adds a key/value tag that ToSql appends to the query as a sqlcommenter
+// comment, e.g. "/*route='%2Fusers'*/". Keys and values are URL-encoded.
+//
+// Tags are only rendered for top-level statements, not for subqueries.
+func (b *DeleteBuilder) Tag(key, value string) *DeleteBuilder {
+	b = b.derive()
+	b.tags = b.tags.with(key, value)
+	return b
+}
+
+// Unscoped disables the default scopes of the query, see
+// StatementBuilderType.Scope. Deletes from soft-deleted tables delete rows.
+func (b *DeleteBuilder) Unscoped() *DeleteBuilder {
+	b = b.derive()
+	b.unscoped = true
+	return b
+}
+
+// AppendSql implements SqlAppender
+func (b *DeleteBuilder) AppendSql(sql *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	var err error
+
 	if len(b.from) == 0 {
-		err = fmt.Errorf("delete statements must specify a From table")
-		return
+		return nil, fmt.Errorf("delete statements must specify a From table")
 	}
 
-	sql := &bytes.Buffer{}
-
 	if len(b.prefixes) > 0 {
-		args, _ = b.prefixes.AppendToSql(sql, " ", args)
+		args, err = b.prefixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 		sql.WriteString(" ")
 	}
 
@@ -117,7 +184,7 @@ func (b *DeleteBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 	// following condition helps to avoid duplicate "from" value in DELETE query
 	// e.g. "DELETE a FROM a ..." which is valid for MySQL but not for PostgreSQL
 	if len(b.what) > 0 && (len(b.what) != 1 || b.what[0] != b.from) {
-		sql.WriteString(strings.Join(b.what, ", "))
+		writeStrings(sql, b.what, ", ")
 		sql.WriteString(" ")
 	}
 
@@ -126,14 +193,14 @@ func (b *DeleteBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 
 	if len(b.joins) > 0 {
 		sql.WriteString(" ")
-		sql.WriteString(strings.Join(b.joins, " "))
+		writeStrings(sql, b.joins, " ")
 	}
 
 	if len(b.usingParts) > 0 {
 		sql.WriteString(" USING ")
 		args, err = appendToSql(b.usingParts, sql, ", ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
@@ -141,13 +208,13 @@ func (b *DeleteBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 		sql.WriteString(" WHERE ")
 		args, err = appendToSql(b.whereParts, sql, " AND ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.orderBys) > 0 {
 		sql.WriteString(" ORDER BY ")
-		sql.WriteString(strings.Join(b.orderBys, ", "))
+		writeStrings(sql, b.orderBys, ", ")
 	}
 
 	// TODO: limit == 0 and offswt == 0 are valid. Need to go dbr way and implement offsetValid and limitValid
@@ -164,33 +231,38 @@ func (b *DeleteBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 	if len(b.returning) > 0 {
 		args, err = b.returning.AppendToSql(sql, args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.suffixes) > 0 {
 		sql.WriteString(" ")
-		args, _ = b.suffixes.AppendToSql(sql, " ", args)
+		args, err = b.suffixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	sqlStr, err = b.placeholderFormat.ReplacePlaceholders(sql.String())
-	return
+	return args, nil
 }
 
 // Prefix adds an expression to the beginning of the query
 func (b *DeleteBuilder) Prefix(sql string, args ...interface{}) *DeleteBuilder {
+	b = b.derive()
 	b.prefixes = append(b.prefixes, Expr(sql, args...))
 	return b
 }
 
 // From sets the FROM clause of the query.
 func (b *DeleteBuilder) From(from string) *DeleteBuilder {
+	b = b.derive()
 	b.from = from
 	return b
 }
 
 // What sets names of tables to be used for deleting from
 func (b *DeleteBuilder) What(what ...string) *DeleteBuilder {
+	b = b.derive()
 	filteredWhat := make([]string, 0, len(what))
 	for _, item := range what {
 		if len(item) > 0 {
@@ -200,7 +272,7 @@ func (b *DeleteBuilder) What(what ...string) *DeleteBuilder {
 
 	b.what = filteredWhat
 	if len(filteredWhat) == 1 {
-		b.From(filteredWhat[0])
+		b.from = filteredWhat[0]
 	}
 
 	return b
@@ -210,6 +282,7 @@ func (b *DeleteBuilder) What(what ...string) *DeleteBuilder {
 //
 // DELETE ... USING is an MySQL/PostgreSQL specific extension
 func (b *DeleteBuilder) Using(tables ...string) *DeleteBuilder {
+	b = b.derive()
 	parts := make([]Sqlizer, len(tables))
 	for i, table := range tables {
 		parts[i] = newPart(table)
@@ -223,24 +296,28 @@ func (b *DeleteBuilder) Using(tables ...string) *DeleteBuilder {
 //
 // DELETE ... USING is an MySQL/PostgreSQL specific extension
 func (b *DeleteBuilder) UsingSelect(from *SelectBuilder, alias string) *DeleteBuilder {
+	b = b.derive()
 	b.usingParts = append(b.usingParts, Alias(from, alias))
 	return b
 }
 
 // Where adds WHERE expressions to the query.
 func (b *DeleteBuilder) Where(pred interface{}, args ...interface{}) *DeleteBuilder {
+	b = b.derive()
 	b.whereParts = append(b.whereParts, NewWherePart(pred, args...))
 	return b
 }
 
 // OrderBy adds ORDER BY expressions to the query.
 func (b *DeleteBuilder) OrderBy(orderBys ...string) *DeleteBuilder {
+	b = b.derive()
 	b.orderBys = append(b.orderBys, orderBys...)
 	return b
 }
 
 // Limit sets a LIMIT clause on the query.
 func (b *DeleteBuilder) Limit(limit uint64) *DeleteBuilder {
+	b = b.derive()
 	b.limit = limit
 	b.limitValid = true
 	return b
@@ -248,6 +325,7 @@ func (b *DeleteBuilder) Limit(limit uint64) *DeleteBuilder {
 
 // Offset sets a OFFSET clause on the query.
 func (b *DeleteBuilder) Offset(offset uint64) *DeleteBuilder {
+	b = b.derive()
 	b.offset = offset
 	b.offsetValid = true
 	return b
@@ -257,6 +335,7 @@ func (b *DeleteBuilder) Offset(offset uint64) *DeleteBuilder {
 //
 // DELETE ... RETURNING is PostgreSQL specific extension
 func (b *DeleteBuilder) Returning(columns ...string) *DeleteBuilder {
+	b = b.derive()
 	b.returning.Returning(columns...)
 	return b
 }
@@ -265,18 +344,21 @@ func (b *DeleteBuilder) Returning(columns ...string) *DeleteBuilder {
 //
 // DELETE ... RETURNING is PostgreSQL specific extension
 func (b *DeleteBuilder) ReturningSelect(from *SelectBuilder, alias string) *DeleteBuilder {
+	b = b.derive()
 	b.returning.ReturningSelect(from, alias)
 	return b
 }
 
 // Suffix adds an expression to the end of the query
 func (b *DeleteBuilder) Suffix(sql string, args ...interface{}) *DeleteBuilder {
+	b = b.derive()
 	b.suffixes = append(b.suffixes, Expr(sql, args...))
 	return b
 }
 
 // JoinClause adds a join clause to the query.
 func (b *DeleteBuilder) JoinClause(join string) *DeleteBuilder {
+	b = b.derive()
 	b.joins = append(b.joins, join)
 	return b
 }
@@ -228,3 +228,21 @@ func TestIssue11(t *testing.T) {
 	expectedArgs := []interface{}{1}
 	assert.Equal(t, expectedArgs, args)
 }
+
+func TestDeleteBuilderClone(t *testing.T) {
+	sb := Select("b").From("c")
+	base := Delete("a").UsingSelect(sb, "d").Where("a.id = d.id")
+
+	clone := base.Clone().Where("e = ?", 1)
+	sb.Where("f = ?", 2)
+
+	sql, args, err := clone.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM a USING (SELECT b FROM c) AS d WHERE a.id = d.id AND e = ?", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, args, err = base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM a USING (SELECT b FROM c WHERE f = ?) AS d WHERE a.id = d.id", sql)
+	assert.Equal(t, []interface{}{2}, args)
+}
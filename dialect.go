@@ -0,0 +1,397 @@
+package sqrl
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Dialect is a SQL dialect, for the few features of sqrl that render SQL
+// differently depending on the database.
+type Dialect int
+
+const (
+	// PostgreSQL is PostgreSQL 9.1 or newer.
+	PostgreSQL Dialect = iota + 1
+	// MySQL assumes the NO_BACKSLASH_ESCAPES SQL mode is off and a UTF-8
+	// connection character set.
+	MySQL
+	// SQLite is SQLite 3.
+	SQLite
+)
+
+func (d Dialect) String() string {
+	switch d {
+	case PostgreSQL:
+		return "PostgreSQL"
+	case MySQL:
+		return "MySQL"
+	case SQLite:
+		return "SQLite"
+	default:
+		return fmt.Sprintf("Dialect(%d)", int(d))
+	}
+}
+
+// PlaceholderFormat returns the usual PlaceholderFormat of the dialect.
+func (d Dialect) PlaceholderFormat() PlaceholderFormat {
+	if d == PostgreSQL {
+		return Dollar
+	}
+	return Question
+}
+
+// appendLiteral writes v as a SQL literal of dialect d. It supports the types
+// accepted by database/sql drivers: nil, bools, numbers, strings, []byte,
+// time.Time, driver.Valuer and pointers to them.
+//
+// Values that can't be represented safely are refused with an error, e.g.
+// strings that aren't valid UTF-8 or contain NUL bytes.
+func (d Dialect) appendLiteral(buf *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteString("NULL")
+		return nil
+	case string:
+		return d.appendString(buf, v)
+	case []byte:
+		if v == nil {
+			buf.WriteString("NULL")
+			return nil
+		}
+		d.appendBytes(buf, v)
+		return nil
+	case time.Time:
+		d.appendTime(buf, v)
+		return nil
+	case driver.Valuer:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			buf.WriteString("NULL")
+			return nil
+		}
+		value, err := v.Value()
+		if err != nil {
+			return valuerError{err}
+		}
+		if _, ok := value.(driver.Valuer); ok {
+			return fmt.Errorf("driver.Valuer %T returned driver.Valuer %T", v, value)
+		}
+		return d.appendLiteral(buf, value)
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			buf.WriteString("NULL")
+			return nil
+		}
+		return d.appendLiteral(buf, rv.Elem().Interface())
+	case reflect.Bool:
+		d.appendBool(buf, rv.Bool())
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		appendNumber(buf, strconv.FormatInt(rv.Int(), 10))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		buf.WriteString(strconv.FormatUint(rv.Uint(), 10))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		return d.appendFloat(buf, rv.Float(), rv.Type().Bits())
+	case reflect.String:
+		return d.appendString(buf, rv.String())
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return d.appendLiteral(buf, rv.Bytes())
+		}
+	}
+	return fmt.Errorf("cannot encode value of type %T as a SQL literal", v)
+}
+
+func (d Dialect) appendBool(buf *bytes.Buffer, b bool) {
+	switch {
+	case d == SQLite && b:
+		buf.WriteString("1")
+	case d == SQLite:
+		buf.WriteString("0")
+	case b:
+		buf.WriteString("TRUE")
+	default:
+		buf.WriteString("FALSE")
+	}
+}
+
+func (d Dialect) appendFloat(buf *bytes.Buffer, f float64, bits int) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		if d != PostgreSQL {
+			return fmt.Errorf("cannot encode %v as a %s literal", f, d)
+		}
+		switch {
+		case math.IsNaN(f):
+			buf.WriteString("'NaN'::float8")
+		case f > 0:
+			buf.WriteString("'Infinity'::float8")
+		default:
+			buf.WriteString("'-Infinity'::float8")
+		}
+		return nil
+	}
+	appendNumber(buf, strconv.FormatFloat(f, 'g', -1, bits))
+	return nil
+}
+
+// appendNumber writes the number n, separated from a preceding minus sign
+// so that "a -?" with -1 doesn't become the comment "a --1".
+func appendNumber(buf *bytes.Buffer, n string) {
+	if n[0] == '-' && buf.Len() > 0 && buf.Bytes()[buf.Len()-1] == '-' {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString(n)
+}
+
+func (d Dialect) appendString(buf *bytes.Buffer, s string) error {
+	if !utf8.ValidString(s) {
+		return fmt.Errorf("cannot encode string that is not valid UTF-8 as a SQL literal")
+	}
+	if d != MySQL && strings.IndexByte(s, 0) >= 0 {
+		return fmt.Errorf("cannot encode string with NUL byte as a %s literal", d)
+	}
+
+	// Backslashes are escaped in Postgres escape strings, so that the
+	// literal means the same whatever standard_conforming_strings is.
+	escapeBackslash := d == MySQL
+	if d == PostgreSQL && strings.IndexByte(s, '\\') >= 0 {
+		escapeBackslash = true
+		buf.WriteByte('E')
+	}
+
+	buf.WriteByte('\'')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '\'':
+			buf.WriteString("''")
+		case c == '\\' && escapeBackslash:
+			buf.WriteString(`\\`)
+		case d != MySQL:
+			buf.WriteByte(c)
+		case c == 0:
+			buf.WriteString(`\0`)
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == 0x1a:
+			buf.WriteString(`\Z`)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+	buf.WriteByte('\'')
+	return nil
+}
+
+func (d Dialect) appendBytes(buf *bytes.Buffer, b []byte) {
+	if d == PostgreSQL {
+		buf.WriteString(`E'\\x`)
+		buf.WriteString(hex.EncodeToString(b))
+		buf.WriteString(`'::bytea`)
+		return
+	}
+	buf.WriteString("X'")
+	buf.WriteString(strings.ToUpper(hex.EncodeToString(b)))
+	buf.WriteString("'")
+}
+
+func (d Dialect) appendTime(buf *bytes.Buffer, t time.Time) {
+	switch d {
+	case PostgreSQL:
+		buf.WriteString("'")
+		buf.WriteString(t.Format("2006-01-02 15:04:05.999999999Z07:00"))
+		buf.WriteString("'::timestamptz")
+	case MySQL:
+		buf.WriteString("'")
+		buf.WriteString(t.UTC().Format("2006-01-02 15:04:05.999999"))
+		buf.WriteString("'")
+	default:
+		buf.WriteString("'")
+		buf.WriteString(t.Format("2006-01-02 15:04:05.999999999-07:00"))
+		buf.WriteString("'")
+	}
+}
+
+// valuerError is an error returned by driver.Valuer.Value.
+type valuerError struct {
+	err error
+}
+
+func (e valuerError) Error() string {
+	return e.err.Error()
+}
+
+// inlineArgs renders s with its args inlined as literals of dialect d.
+//
+// If lenient, values that can't be encoded are rendered as strings rather
+// than refused.
+func (d Dialect) inlineArgs(s Sqlizer, lenient bool) (string, error) {
+	if a, ok := s.(SqlAppender); ok {
+		// Render without placeholder format, so that ?? escapes are still
+		// known.
+		buf := getBuffer()
+		defer putBuffer(buf)
+
+		args, err := a.AppendSql(buf, nil)
+		if err != nil {
+			return "", err
+		}
+		return d.interpolate(buf.String(), args, replacePlaceholders, lenient)
+	}
+
+	query, args, err := s.ToSql()
+	if err != nil {
+		return "", err
+	}
+	replace := replacePlaceholders
+	if !strings.Contains(query, "?") && strings.Contains(query, "$1") {
+		replace = replaceDollarPlaceholders
+	}
+	return d.interpolate(query, args, replace, lenient)
+}
+
+// interpolate replaces the placeholders of query found by replace with args
+// encoded as literals of dialect d.
+//
+// Placeholders in string literals, quoted identifiers and comments are
+// refused: a literal inlined there would end them early.
+func (d Dialect) interpolate(query string, args []interface{}, replace placeholderReplacer, lenient bool) (string, error) {
+	if err := checkQuotedPlaceholders(query, replace); err != nil {
+		return "", err
+	}
+
+	n := 0
+	sql, err := replace(query, func(buf *bytes.Buffer, i int) error {
+		if i > len(args) {
+			return fmt.Errorf("not enough args for placeholder %d: %d given", i, len(args))
+		}
+		if i > n {
+			n = i
+		}
+
+		// Keep literals such as E'' and X'' apart from a preceding word.
+		if buf.Len() > 0 && isWordByte(buf.Bytes()[buf.Len()-1]) {
+			buf.WriteByte(' ')
+		}
+
+		arg := args[i-1]
+		err := d.appendLiteral(buf, arg)
+		if err != nil && lenient {
+			s := fmt.Sprint(arg)
+			if verr, ok := err.(valuerError); ok {
+				s = fmt.Sprintf("!(%v)", verr.err)
+			}
+			s = strings.ToValidUTF8(s, "\uFFFD")
+			d.appendString(buf, strings.Replace(s, "\x00", `\0`, -1))
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	if n != len(args) {
+		return "", fmt.Errorf("%d args given for %d placeholders", len(args), n)
+	}
+	return sql, nil
+}
+
+// checkQuotedPlaceholders returns an error if replace finds placeholders in
+// the string literals, quoted identifiers or comments of query.
+func checkQuotedPlaceholders(query string, replace placeholderReplacer) error {
+	for _, tok := range scanSql(query) {
+		var what string
+		switch tok.kind {
+		case sqlString:
+			what = "string literal"
+		case sqlQuoted:
+			what = "quoted identifier"
+		case sqlComment:
+			what = "comment"
+		default:
+			continue
+		}
+		_, err := replace(tok.text, func(*bytes.Buffer, int) error {
+			return fmt.Errorf("cannot interpolate placeholder in %s %s", what, tok.text)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isWordByte(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '$'
+}
+
+// placeholderReplacer calls replace for every placeholder of sql, numbered
+// from 1, and returns sql with the placeholders replaced.
+type placeholderReplacer func(sql string, replace func(buf *bytes.Buffer, i int) error) (string, error)
+
+// replaceQuestionPlaceholders is a placeholderReplacer for SQL that has
+// already been rendered: every question mark is a placeholder.
+func replaceQuestionPlaceholders(sql string, replace func(buf *bytes.Buffer, i int) error) (string, error) {
+	buf := &bytes.Buffer{}
+	for i := 1; ; i++ {
+		p := strings.IndexByte(sql, '?')
+		if p == -1 {
+			break
+		}
+		buf.WriteString(sql[:p])
+		if err := replace(buf, i); err != nil {
+			return "", err
+		}
+		sql = sql[p+1:]
+	}
+
+	buf.WriteString(sql)
+	return buf.String(), nil
+}
+
+// replaceDollarPlaceholders calls replace for every $n placeholder of sql.
+func replaceDollarPlaceholders(sql string, replace func(buf *bytes.Buffer, i int) error) (string, error) {
+	buf := &bytes.Buffer{}
+	for {
+		p := strings.IndexByte(sql, '$')
+		if p == -1 {
+			break
+		}
+
+		end := p + 1
+		for ; end < len(sql) && sql[end] >= '0' && sql[end] <= '9'; end++ {
+		}
+		buf.WriteString(sql[:p])
+		if end == p+1 {
+			buf.WriteByte('$')
+		} else {
+			i, err := strconv.Atoi(sql[p+1 : end])
+			if err != nil {
+				return "", err
+			}
+			if err := replace(buf, i); err != nil {
+				return "", err
+			}
+		}
+		sql = sql[end:]
+	}
+
+	buf.WriteString(sql)
+	return buf.String(), nil
+}
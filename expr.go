@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"database/sql/driver"
 	"fmt"
-	"io"
 	"reflect"
 	"sort"
 	"strings"
@@ -27,48 +26,87 @@ func (e expr) ToSql() (string, []interface{}, error) {
 	if !hasSqlizer(e.args) {
 		return e.sql, e.args, nil
 	}
+	return appenderToSql(e)
+}
+
+// AppendSql implements SqlAppender
+//
+// Placeholders bound to Sqlizer args are replaced with the SQL of the args,
+// and escaped ?? placeholders are unescaped like placeholders are replaced.
+// Exprs without Sqlizer args are written as is, their escaped placeholders
+// are unescaped by the placeholder format of the statement.
+func (e expr) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	if !hasSqlizer(e.args) {
+		buf.WriteString(e.sql)
+		return append(args, e.args...), nil
+	}
+
+	sql := e.sql
+	i := 0
+	for {
+		p := strings.IndexByte(sql, '?')
+		if p == -1 {
+			break
+		}
+
+		if len(sql[p:]) > 1 && sql[p+1] == '?' { // escape ?? => ?
+			buf.WriteString(sql[:p+1])
+			sql = sql[p+2:]
+			continue
+		}
 
-	args := make([]interface{}, 0, len(e.args))
-	sql, err := replacePlaceholders(e.sql, func(buf *bytes.Buffer, i int) error {
-		if i > len(e.args) {
-			buf.WriteRune('?')
-			return nil
+		buf.WriteString(sql[:p])
+		sql = sql[p+1:]
+		if i >= len(e.args) {
+			buf.WriteByte('?')
+			continue
 		}
-		switch arg := e.args[i-1].(type) {
+
+		switch arg := e.args[i].(type) {
 		case Sqlizer:
-			sql, vs, err := arg.ToSql()
+			var err error
+			args, err = appendSql(buf, arg, args)
 			if err != nil {
-				return err
+				return nil, err
 			}
-			args = append(args, vs...)
-			fmt.Fprintf(buf, sql)
 		default:
 			args = append(args, arg)
-			buf.WriteRune('?')
+			buf.WriteByte('?')
 		}
-		return nil
-	})
-	if err != nil {
-		return "", nil, err
+		i++
 	}
-	return sql, args, nil
+
+	buf.WriteString(sql)
+	return args, nil
+}
+
+func (e expr) clone() expr {
+	return expr{sql: e.sql, args: cloneValues(e.args)}
 }
 
 type exprs []expr
 
-func (es exprs) AppendToSql(w io.Writer, sep string, args []interface{}) ([]interface{}, error) {
+func (es exprs) clone() exprs {
+	if es == nil {
+		return nil
+	}
+	cloned := make(exprs, len(es))
+	for i, e := range es {
+		cloned[i] = e.clone()
+	}
+	return cloned
+}
+
+func (es exprs) AppendToSql(buf *bytes.Buffer, sep string, args []interface{}) ([]interface{}, error) {
 	for i, e := range es {
 		if i > 0 {
-			_, err := io.WriteString(w, sep)
-			if err != nil {
-				return nil, err
-			}
+			buf.WriteString(sep)
 		}
-		_, err := io.WriteString(w, e.sql)
+		var err error
+		args, err = e.AppendSql(buf, args)
 		if err != nil {
 			return nil, err
 		}
-		args = append(args, e.args...)
 	}
 	return args, nil
 }
@@ -88,11 +126,19 @@ func Alias(expr Sqlizer, alias string) aliasExpr {
 }
 
 func (e aliasExpr) ToSql() (sql string, args []interface{}, err error) {
-	sql, args, err = e.expr.ToSql()
-	if err == nil {
-		sql = fmt.Sprintf("(%s) AS %s", sql, e.alias)
+	return appenderToSql(e)
+}
+
+// AppendSql implements SqlAppender
+func (e aliasExpr) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	buf.WriteByte('(')
+	args, err := appendSql(buf, e.expr, args)
+	if err != nil {
+		return nil, err
 	}
-	return
+	buf.WriteString(") AS ")
+	buf.WriteString(e.alias)
+	return args, nil
 }
 
 // Eq is syntactic sugar for use with Where/Having/Set methods.
@@ -100,19 +146,18 @@ func (e aliasExpr) ToSql() (sql string, args []interface{}, err error) {
 //     .Where(Eq{"id": 1})
 type Eq map[string]interface{}
 
-func (eq Eq) toSql(useNotOpr bool) (sql string, args []interface{}, err error) {
+func (eq Eq) appendSql(buf *bytes.Buffer, args []interface{}, useNotOpr bool) ([]interface{}, error) {
 	var (
-		exprs      []string
-		equalOpr   = "="
-		inOpr      = "IN"
-		nullOpr    = "IS"
+		equalOpr    = " = ?"
+		inOpr       = " IN ("
+		nullOpr     = " IS NULL"
 		inEmptyExpr = "(1=0)" // Portable FALSE
 	)
 
 	if useNotOpr {
-		equalOpr = "<>"
-		inOpr = "NOT IN"
-		nullOpr = "IS NOT"
+		equalOpr = " <> ?"
+		inOpr = " NOT IN ("
+		nullOpr = " IS NOT NULL"
 		inEmptyExpr = "(1=1)" // Portable TRUE
 	}
 
@@ -121,42 +166,61 @@ func (eq Eq) toSql(useNotOpr bool) (sql string, args []interface{}, err error) {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
-	for _, key := range keys {
+	for i, key := range keys {
 		val := eq[key]
-		expr := ""
 
 		switch v := val.(type) {
 		case driver.Valuer:
+			var err error
 			if val, err = v.Value(); err != nil {
-				return
+				return nil, err
 			}
 		}
 
+		if i > 0 {
+			buf.WriteString(" AND ")
+		}
+
 		if val == nil {
-			expr = fmt.Sprintf("%s %s NULL", key, nullOpr)
-		} else {
-			if isListType(val) {
-				valVal := reflect.ValueOf(val)
-				if valVal.Len() == 0 {
-					expr = inEmptyExpr
-					if args == nil {
-						args = []interface{}{}
-					}
-				} else {
-					for i := 0; i < valVal.Len(); i++ {
-						args = append(args, valVal.Index(i).Interface())
-					}
-					expr = fmt.Sprintf("%s %s (%s)", key, inOpr, Placeholders(valVal.Len()))
+			buf.WriteString(key)
+			buf.WriteString(nullOpr)
+		} else if isListType(val) {
+			valVal := reflect.ValueOf(val)
+			if valVal.Len() == 0 {
+				buf.WriteString(inEmptyExpr)
+				if args == nil {
+					args = []interface{}{}
 				}
 			} else {
-				expr = fmt.Sprintf("%s %s ?", key, equalOpr)
-				args = append(args, val)
+				buf.WriteString(key)
+				buf.WriteString(inOpr)
+				for i := 0; i < valVal.Len(); i++ {
+					if i > 0 {
+						buf.WriteByte(',')
+					}
+					buf.WriteByte('?')
+					args = append(args, valVal.Index(i).Interface())
+				}
+				buf.WriteByte(')')
 			}
+		} else {
+			buf.WriteString(key)
+			buf.WriteString(equalOpr)
+			args = append(args, val)
 		}
-		exprs = append(exprs, expr)
 	}
-	sql = strings.Join(exprs, " AND ")
-	return
+	return args, nil
+}
+
+func (eq Eq) toSql(useNotOpr bool) (sql string, args []interface{}, err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	args, err = eq.appendSql(buf, nil, useNotOpr)
+	if err != nil {
+		return "", nil, err
+	}
+	return buf.String(), args, nil
 }
 
 // ToSql builds the query into a SQL string and bound args.
@@ -164,6 +228,11 @@ func (eq Eq) ToSql() (sql string, args []interface{}, err error) {
 	return eq.toSql(false)
 }
 
+// AppendSql implements SqlAppender
+func (eq Eq) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return eq.appendSql(buf, args, false)
+}
+
 // NotEq is syntactic sugar for use with Where/Having/Set methods.
 // Ex:
 //     .Where(NotEq{"id": 1}) == "id <> 1"
@@ -174,23 +243,25 @@ func (neq NotEq) ToSql() (sql string, args []interface{}, err error) {
 	return Eq(neq).toSql(true)
 }
 
+// AppendSql implements SqlAppender
+func (neq NotEq) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return Eq(neq).appendSql(buf, args, true)
+}
+
 // Lt is syntactic sugar for use with Where/Having/Set methods.
 // Ex:
 //     .Where(Lt{"id": 1})
 type Lt map[string]interface{}
 
-func (lt Lt) toSql(opposite, orEq bool) (sql string, args []interface{}, err error) {
-	var (
-		exprs []string
-		opr   string = "<"
-	)
+func (lt Lt) appendSql(buf *bytes.Buffer, args []interface{}, opposite, orEq bool) ([]interface{}, error) {
+	opr := " <"
 
 	if opposite {
-		opr = ">"
+		opr = " >"
 	}
 
 	if orEq {
-		opr = fmt.Sprintf("%s%s", opr, "=")
+		opr += "="
 	}
 
 	keys := make([]string, 0, len(lt))
@@ -198,39 +269,55 @@ func (lt Lt) toSql(opposite, orEq bool) (sql string, args []interface{}, err err
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
-	for _, key := range keys {
+	for i, key := range keys {
 		val := lt[key]
-		expr := ""
 
 		switch v := val.(type) {
 		case driver.Valuer:
+			var err error
 			if val, err = v.Value(); err != nil {
-				return
+				return nil, err
 			}
 		}
 
 		if val == nil {
-			err = fmt.Errorf("cannot use null with less than or greater than operators")
-			return
-		} else {
-			if isListType(val) {
-				err = fmt.Errorf("cannot use array or slice with less than or greater than operators")
-				return
-			} else {
-				expr = fmt.Sprintf("%s %s ?", key, opr)
-				args = append(args, val)
-			}
+			return nil, fmt.Errorf("cannot use null with less than or greater than operators")
+		}
+		if isListType(val) {
+			return nil, fmt.Errorf("cannot use array or slice with less than or greater than operators")
 		}
-		exprs = append(exprs, expr)
+
+		if i > 0 {
+			buf.WriteString(" AND ")
+		}
+		buf.WriteString(key)
+		buf.WriteString(opr)
+		buf.WriteString(" ?")
+		args = append(args, val)
 	}
-	sql = strings.Join(exprs, " AND ")
-	return
+	return args, nil
+}
+
+func (lt Lt) toSql(opposite, orEq bool) (sql string, args []interface{}, err error) {
+	buf := getBuffer()
+	defer putBuffer(buf)
+
+	args, err = lt.appendSql(buf, nil, opposite, orEq)
+	if err != nil {
+		return "", nil, err
+	}
+	return buf.String(), args, nil
 }
 
 func (lt Lt) ToSql() (sql string, args []interface{}, err error) {
 	return lt.toSql(false, false)
 }
 
+// AppendSql implements SqlAppender
+func (lt Lt) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return lt.appendSql(buf, args, false, false)
+}
+
 // LtOrEq is syntactic sugar for use with Where/Having/Set methods.
 // Ex:
 //     .Where(LtOrEq{"id": 1}) == "id <= 1"
@@ -240,6 +327,11 @@ func (ltOrEq LtOrEq) ToSql() (sql string, args []interface{}, err error) {
 	return Lt(ltOrEq).toSql(false, true)
 }
 
+// AppendSql implements SqlAppender
+func (ltOrEq LtOrEq) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return Lt(ltOrEq).appendSql(buf, args, false, true)
+}
+
 // Gt is syntactic sugar for use with Where/Having/Set methods.
 // Ex:
 //     .Where(Gt{"id": 1}) == "id > 1"
@@ -249,6 +341,11 @@ func (gt Gt) ToSql() (sql string, args []interface{}, err error) {
 	return Lt(gt).toSql(true, false)
 }
 
+// AppendSql implements SqlAppender
+func (gt Gt) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return Lt(gt).appendSql(buf, args, true, false)
+}
+
 // GtOrEq is syntactic sugar for use with Where/Having/Set methods.
 // Ex:
 //     .Where(GtOrEq{"id": 1}) == "id >= 1"
@@ -258,24 +355,26 @@ func (gtOrEq GtOrEq) ToSql() (sql string, args []interface{}, err error) {
 	return Lt(gtOrEq).toSql(true, true)
 }
 
+// AppendSql implements SqlAppender
+func (gtOrEq GtOrEq) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return Lt(gtOrEq).appendSql(buf, args, true, true)
+}
+
 type conj []Sqlizer
 
-func (c conj) join(sep string) (sql string, args []interface{}, err error) {
-	var sqlParts []string
-	for _, sqlizer := range c {
-		partSql, partArgs, err := sqlizer.ToSql()
-		if err != nil {
-			return "", nil, err
-		}
-		if partSql != "" {
-			sqlParts = append(sqlParts, partSql)
-			args = append(args, partArgs...)
-		}
+func (c conj) appendSql(buf *bytes.Buffer, args []interface{}, sep string) ([]interface{}, error) {
+	start := buf.Len()
+	buf.WriteByte('(')
+	args, err := appendToSql(c, buf, sep, args)
+	if err != nil {
+		return nil, err
 	}
-	if len(sqlParts) > 0 {
-		sql = fmt.Sprintf("(%s)", strings.Join(sqlParts, sep))
+	if buf.Len() == start+1 {
+		buf.Truncate(start)
+		return args, nil
 	}
-	return
+	buf.WriteByte(')')
+	return args, nil
 }
 
 // And is syntactic sugar that glues where/having parts with AND clause
@@ -285,7 +384,12 @@ type And conj
 
 // ToSql builds the query into a SQL string and bound args.
 func (a And) ToSql() (string, []interface{}, error) {
-	return conj(a).join(" AND ")
+	return appenderToSql(a)
+}
+
+// AppendSql implements SqlAppender
+func (a And) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return conj(a).appendSql(buf, args, " AND ")
 }
 
 // Or is syntactic sugar that glues where/having parts with OR clause
@@ -295,7 +399,12 @@ type Or conj
 
 // ToSql builds the query into a SQL string and bound args.
 func (o Or) ToSql() (string, []interface{}, error) {
-	return conj(o).join(" OR ")
+	return appenderToSql(o)
+}
+
+// AppendSql implements SqlAppender
+func (o Or) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	return conj(o).appendSql(buf, args, " OR ")
 }
 
 func isListType(val interface{}) bool {
@@ -1,5 +1,9 @@
 package sqrl
 
+// ExtractColumns returns the columns of an InsertBuilder or the SET columns of
+// an UpdateBuilder, nil for other Sqlizers.
+//
+// Deprecated: Use InsertBuilder.Clauses or UpdateBuilder.Clauses.
 func ExtractColumns(builder Sqlizer) []string {
 	switch b := builder.(type) {
 	case *UpdateBuilder:
@@ -11,10 +15,14 @@ func ExtractColumns(builder Sqlizer) []string {
 	case *InsertBuilder:
 		return b.columns
 	default:
-		panic("failed to extract columns")
+		return nil
 	}
 }
 
+// ExtractValues returns the rows of values of an InsertBuilder or the SET
+// values of an UpdateBuilder as a single row, nil for other Sqlizers.
+//
+// Deprecated: Use InsertBuilder.Clauses or UpdateBuilder.Clauses.
 func ExtractValues(builder Sqlizer) [][]interface{} {
 	switch b := builder.(type) {
 	case *UpdateBuilder:
@@ -26,10 +34,14 @@ func ExtractValues(builder Sqlizer) [][]interface{} {
 	case *InsertBuilder:
 		return b.values
 	default:
-		panic("failed to extract values")
+		return nil
 	}
 }
 
+// ExtractWhereParts returns the WHERE parts of a SelectBuilder, UpdateBuilder
+// or DeleteBuilder, nil for other Sqlizers.
+//
+// Deprecated: Use the Clauses method of the builder.
 func ExtractWhereParts(builder Sqlizer) []Sqlizer {
 	switch b := builder.(type) {
 	case *SelectBuilder:
@@ -38,33 +50,18 @@ func ExtractWhereParts(builder Sqlizer) []Sqlizer {
 		return b.whereParts
 	case *DeleteBuilder:
 		return b.whereParts
-	case *InsertBuilder:
-		return nil
 	default:
-		panic("failed to extract whereParts")
+		return nil
 	}
 }
 
+// ExtractTableNames returns the tables referenced by the statement built by
+// builder: the written tables followed by the read ones, as returned by
+// ExtractTableDependencies. It returns nil if the statement can't be built.
 func ExtractTableNames(builder Sqlizer) []string {
-	switch b := builder.(type) {
-	case *SelectBuilder:
-		tables := make([]string, 0, len(b.fromParts))
-		for _, from := range b.fromParts {
-			switch from := from.(type) {
-			case *part:
-				tables = append(tables, from.pred.(string))
-			case aliasExpr:
-				tables = append(tables, from.alias)
-			}
-		}
-		return tables
-	case *InsertBuilder:
-		return []string{b.into}
-	case *UpdateBuilder:
-		return []string{b.table}
-	case *DeleteBuilder:
-		return []string{b.from}
-	default:
+	deps, err := ExtractTableDependencies(builder)
+	if err != nil {
 		return nil
 	}
+	return deps.Tables()
 }
@@ -0,0 +1,182 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// fakeDB is an in-memory database/sql/driver implementation for tests. It
+// records every driver call in log and returns scripted results.
+type fakeDB struct {
+	mu  sync.Mutex
+	log []string
+
+	// prepareErr, execErr and commitErr, when set, are consulted before a
+	// statement is prepared or executed and before a transaction is committed.
+	prepareErr func(query string) error
+	execErr    func(query string) error
+	commitErr  func() error
+
+	columns []string
+	rows    [][]driver.Value
+}
+
+// newFakeDB returns a sql.DB backed by a single fake connection.
+func newFakeDB() (*sql.DB, *fakeDB) {
+	f := &fakeDB{}
+	db := sql.OpenDB(f)
+	db.SetMaxOpenConns(1)
+	return db, f
+}
+
+func (f *fakeDB) record(format string, args ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log = append(f.log, fmt.Sprintf(format, args...))
+}
+
+// Log returns the recorded driver calls.
+func (f *fakeDB) Log() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.log...)
+}
+
+// Count returns the number of recorded driver calls starting with prefix.
+func (f *fakeDB) Count(prefix string) int {
+	n := 0
+	for _, l := range f.Log() {
+		if strings.HasPrefix(l, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) {
+	return &fakeConn{db: f}, nil
+}
+
+func (f *fakeDB) Driver() driver.Driver {
+	return fakeDriver{f}
+}
+
+type fakeDriver struct {
+	db *fakeDB
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return &fakeConn{db: d.db}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	if c.db.prepareErr != nil {
+		if err := c.db.prepareErr(query); err != nil {
+			return nil, err
+		}
+	}
+	c.db.record("prepare %s", query)
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		c.db.record("begin %s", sql.IsolationLevel(opts.Isolation))
+	} else {
+		c.db.record("begin")
+	}
+	return &fakeTx{db: c.db}, nil
+}
+
+type fakeTx struct {
+	db *fakeDB
+}
+
+func (tx *fakeTx) Commit() error {
+	if tx.db.commitErr != nil {
+		if err := tx.db.commitErr(); err != nil {
+			tx.db.record("commit failed")
+			return err
+		}
+	}
+	tx.db.record("commit")
+	return nil
+}
+
+func (tx *fakeTx) Rollback() error {
+	tx.db.record("rollback")
+	return nil
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	s.db.record("close %s", s.query)
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.db.execErr != nil {
+		if err := s.db.execErr(s.query); err != nil {
+			return nil, err
+		}
+	}
+	s.db.record("exec %s %v", s.query, args)
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.db.execErr != nil {
+		if err := s.db.execErr(s.query); err != nil {
+			return nil, err
+		}
+	}
+	s.db.record("query %s %v", s.query, args)
+	return &fakeRows{columns: s.db.columns, rows: s.db.rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
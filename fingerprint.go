@@ -0,0 +1,143 @@
+package sqrl
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+)
+
+// Fingerprint returns the normalised SQL of s, see Normalize, and a short
+// hash of it. Both are the same for every execution of a query shape, whatever
+// its args, the number of values in IN lists or the literals of Expr
+// fragments, e.g. to key metrics by query.
+func Fingerprint(s Sqlizer) (hash string, normalized string, err error) {
+	sql, _, err := s.ToSql()
+	if err != nil {
+		return "", "", err
+	}
+	normalized = Normalize(sql)
+	return fingerprintHash(normalized), normalized, nil
+}
+
+func fingerprintHash(normalized string) string {
+	h := fnv.New64a()
+	h.Write([]byte(normalized))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Normalize returns sql normalised to its shape:
+//
+//   - comments are removed and whitespace is collapsed to single spaces,
+//     with no space inside parentheses or before commas and one after them
+//   - string, number, bit and dollar-quoted literals are replaced with "?"
+//   - "$1" placeholders are replaced with "?"
+//   - lists of placeholders such as "(?, ?, ?)" are collapsed to "(?)", and
+//     so are repeated lists such as "VALUES (?, ?), (?, ?)"
+//
+// Quoted identifiers and the case of keywords and identifiers are kept.
+// Backslashes in string literals are only escapes in escape strings such as
+// E'\n', so MySQL strings ending with an escaped quote may be cut short.
+//
+// Ex:
+//
+//	Normalize("SELECT * FROM a WHERE b IN ($1,$2) AND c = 'x' -- list")
+//	// SELECT * FROM a WHERE b IN (?) AND c = ?
+func Normalize(sql string) string {
+	tokens := collapsePlaceholderLists(tokenizeSql(sql))
+
+	var buf strings.Builder
+	for i, tok := range tokens {
+		if i > 0 {
+			prev := tokens[i-1].text
+			switch {
+			case prev == ",":
+				buf.WriteByte(' ')
+			case prev == "(" || prev == "[" || tok.text == ")" || tok.text == "]" || tok.text == ",":
+			case tok.space:
+				buf.WriteByte(' ')
+			}
+		}
+		buf.WriteString(tok.text)
+	}
+	return buf.String()
+}
+
+// tokenizeSql splits sql into tokens, replacing literals and placeholders with
+// "?" and dropping comments.
+func tokenizeSql(sql string) []sqlToken {
+	var tokens []sqlToken
+	space := false
+	for _, tok := range scanSql(sql) {
+		space = space || tok.space
+		switch tok.kind {
+		case sqlComment:
+			space = true
+			continue
+		case sqlString, sqlPlaceholder:
+			tok.text = "?"
+		case sqlNumber:
+			tok.text = "?"
+			// A sign belongs to the number unless it follows an operand.
+			if n := len(tokens); n > 0 && (tokens[n-1].text == "-" || tokens[n-1].text == "+") &&
+				!space && (n == 1 || !tokens[n-2].isOperand()) {
+				space = tokens[n-1].space
+				tokens = tokens[:n-1]
+			}
+		}
+		tok.space = space
+		tokens = append(tokens, tok)
+		space = false
+	}
+	return tokens
+}
+
+// collapsePlaceholderLists replaces lists of placeholders with a single one,
+// and drops lists that repeat the previous one.
+func collapsePlaceholderLists(tokens []sqlToken) []sqlToken {
+	out := tokens[:0:0]
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.text == "(" || tok.text == "[" {
+			if end := placeholderListEnd(tokens, i); end > 0 {
+				n := len(out)
+				if n >= 4 && out[n-1].text == "," && out[n-2].text == closing(tok.text) &&
+					out[n-3].text == "?" && out[n-4].text == tok.text {
+					// Repeated list: drop the comma too.
+					out = out[:n-1]
+				} else {
+					out = append(out, tok, sqlToken{kind: sqlPlaceholder, text: "?"}, sqlToken{kind: sqlPunct, text: closing(tok.text)})
+				}
+				i = end
+				continue
+			}
+		}
+		out = append(out, tok)
+	}
+	return out
+}
+
+func closing(open string) string {
+	if open == "[" {
+		return "]"
+	}
+	return ")"
+}
+
+// placeholderListEnd returns the index of the closing token of the list of
+// placeholders opened at tokens[i], or 0 if it isn't one.
+func placeholderListEnd(tokens []sqlToken, i int) int {
+	close := closing(tokens[i].text)
+	for j := i + 1; j+1 < len(tokens); j += 2 {
+		if tokens[j].text != "?" {
+			return 0
+		}
+		switch tokens[j+1].text {
+		case close:
+			return j + 1
+		case ",":
+		default:
+			return 0
+		}
+	}
+	return 0
+}
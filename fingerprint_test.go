@@ -0,0 +1,55 @@
+package sqrl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	testCases := map[string]string{
+		"SELECT * FROM a WHERE b IN ($1,$2, $3) AND c = $4":     "SELECT * FROM a WHERE b IN (?) AND c = ?",
+		"  INSERT INTO a (b,c) VALUES (?,?),(?,?), ( ?, ? )  ":  "INSERT INTO a (b, c) VALUES (?)",
+		"DELETE\nFROM a\nWHERE b = (?)":                         "DELETE FROM a WHERE b = (?)",
+		"SELECT 'it''s', E'\\'', X'00', N'x', $$a'b$$, $t$x$t$": "SELECT ?, ?, ?, ?, ?, ?",
+		"SELECT 1, -2.5, +3e-10, .5, 0xFF, a-1, (b) -1, c - 1":  "SELECT ?, ?, ?, ?, ?, a-?, (b) -?, c - ?",
+		"SELECT a FROM b WHERE c = -1 AND d <= -1":              "SELECT a FROM b WHERE c = ? AND d <= ?",
+		"SELECT a -- comment ?\nFROM b /* c /* nested */ */":    "SELECT a FROM b",
+		"SELECT a/*x*/FROM b /*action='list'*/":                 "SELECT a FROM b",
+		`SELECT "weird ""name""", ` + "`x``y`" + ` FROM t2`:     `SELECT "weird ""name""", ` + "`x``y`" + ` FROM t2`,
+		"SELECT a::text, b[1], ARRAY[1,2,3] FROM c":             "SELECT a::text, b[?], ARRAY[?] FROM c",
+		"SELECT * FROM a WHERE b IN (1, 'x', $1) AND c IN (?)":  "SELECT * FROM a WHERE b IN (?) AND c IN (?)",
+		"SELECT * FROM a WHERE (b, c) IN ((?, ?), (?, ?))":      "SELECT * FROM a WHERE (b, c) IN ((?))",
+		"SELECT f(a, ?) FROM b":                                 "SELECT f(a, ?) FROM b",
+		"SELECT $ FROM t WHERE x = 'unterminated":               "SELECT $ FROM t WHERE x = ?",
+		"SELECT données FROM t WHERE é = 'ü'":                   "SELECT données FROM t WHERE é = ?",
+	}
+	for sql, expected := range testCases {
+		assert.Equal(t, expected, Normalize(sql), sql)
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	q1 := Select("a").From("b").Where(Eq{"c": []int{1, 2, 3}}).Where("d > ? -- x", 1)
+	q2 := Select("a").From("b").Where(Eq{"c": []int{4}}).Where("d > 2").PlaceholderFormat(Dollar)
+	q3 := StatementBuilder.Interpolate(PostgreSQL).Select("a").From("b").Where(Eq{"c": []int{5, 6}}).Where("d > ?", "x")
+
+	hash1, sql1, err := Fingerprint(q1)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c IN (?) AND d > ?", sql1)
+	assert.Len(t, hash1, 16)
+
+	for _, q := range []Sqlizer{q2, q3} {
+		hash, sql, err := Fingerprint(q)
+		assert.NoError(t, err)
+		assert.Equal(t, sql1, sql)
+		assert.Equal(t, hash1, hash)
+	}
+
+	hash, _, err := Fingerprint(Select("a").From("b"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash1, hash)
+
+	_, _, err = Fingerprint(Update("a"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,384 @@
+package sqrl
+
+import "strings"
+
+// Format returns the SQL of s formatted by FormatSql, and its args.
+func Format(s Sqlizer) (string, []interface{}, error) {
+	sql, args, err := s.ToSql()
+	if err != nil {
+		return "", nil, err
+	}
+	return FormatSql(sql), args, nil
+}
+
+// FormatSql returns sql formatted for humans, e.g. for code review, test
+// failures and golden files:
+//
+//   - every clause starts a line, and the columns of SELECT, GROUP BY, ORDER BY,
+//     RETURNING and SET, the rows of VALUES, the tables of FROM and their joins
+//     and the AND and OR conditions of WHERE and HAVING are put on lines of
+//     their own, indented by two spaces
+//   - subqueries are indented one level deeper than the line they start on,
+//     and so are the WHEN and ELSE branches of CASE expressions
+//   - reserved keywords are upper-cased
+//   - whitespace is collapsed, with no space inside parentheses or before
+//     commas and one after them
+//
+// Literals, placeholders, quoted identifiers and comments are kept as they
+// are, and the same SQL is always formatted the same way.
+//
+// Ex:
+//
+//	FormatSql("select a, b from c join d using (e) where f = ? and g in (select h from i)")
+//	// SELECT
+//	//   a,
+//	//   b
+//	// FROM
+//	//   c
+//	//   JOIN d USING (e)
+//	// WHERE
+//	//   f = ?
+//	//   AND g IN (
+//	//     SELECT
+//	//       h
+//	//     FROM
+//	//       i
+//	//   )
+func FormatSql(sql string) string {
+	f := &sqlFormatter{
+		tokens:    scanSql(sql),
+		levels:    []formatLevel{{}},
+		lineStart: true,
+	}
+	for f.i < len(f.tokens) {
+		f.format()
+	}
+	return f.buf.String()
+}
+
+// clauseLayout is the layout of the body of a clause.
+type clauseLayout int
+
+const (
+	// inlineClause bodies follow their keyword on the same line.
+	inlineClause clauseLayout = iota
+	// listClause bodies are indented with one item per line.
+	listClause
+	// conditionClause bodies are indented with one AND or OR per line.
+	conditionClause
+	// joinClause is a join in the body of FROM, on a line of its own.
+	joinClause
+)
+
+type sqlClause struct {
+	words  []string
+	layout clauseLayout
+}
+
+// sqlClauses are the clauses of statements. Clauses starting with the same
+// word are listed longest first.
+var sqlClauses = []sqlClause{
+	{[]string{"WITH", "RECURSIVE"}, inlineClause},
+	{[]string{"WITH"}, inlineClause},
+	{[]string{"SELECT", "DISTINCT"}, listClause},
+	{[]string{"SELECT", "ALL"}, listClause},
+	{[]string{"SELECT"}, listClause},
+	{[]string{"INSERT", "IGNORE", "INTO"}, inlineClause},
+	{[]string{"INSERT", "INTO"}, inlineClause},
+	{[]string{"REPLACE", "INTO"}, inlineClause},
+	{[]string{"VALUES"}, listClause},
+	{[]string{"UPDATE"}, inlineClause},
+	{[]string{"SET"}, listClause},
+	{[]string{"DELETE", "FROM"}, inlineClause},
+	{[]string{"DELETE"}, inlineClause},
+	{[]string{"USING"}, inlineClause},
+	{[]string{"FROM"}, listClause},
+	{[]string{"NATURAL", "JOIN"}, joinClause},
+	{[]string{"INNER", "JOIN"}, joinClause},
+	{[]string{"CROSS", "JOIN"}, joinClause},
+	{[]string{"LEFT", "OUTER", "JOIN"}, joinClause},
+	{[]string{"LEFT", "JOIN"}, joinClause},
+	{[]string{"RIGHT", "OUTER", "JOIN"}, joinClause},
+	{[]string{"RIGHT", "JOIN"}, joinClause},
+	{[]string{"FULL", "OUTER", "JOIN"}, joinClause},
+	{[]string{"FULL", "JOIN"}, joinClause},
+	{[]string{"STRAIGHT_JOIN"}, joinClause},
+	{[]string{"JOIN"}, joinClause},
+	{[]string{"WHERE"}, conditionClause},
+	{[]string{"GROUP", "BY"}, listClause},
+	{[]string{"HAVING"}, conditionClause},
+	{[]string{"ORDER", "BY"}, listClause},
+	{[]string{"LIMIT"}, inlineClause},
+	{[]string{"OFFSET"}, inlineClause},
+	{[]string{"FETCH"}, inlineClause},
+	{[]string{"UNION", "ALL"}, inlineClause},
+	{[]string{"UNION"}, inlineClause},
+	{[]string{"INTERSECT"}, inlineClause},
+	{[]string{"EXCEPT"}, inlineClause},
+	{[]string{"ON", "CONFLICT"}, inlineClause},
+	{[]string{"ON", "DUPLICATE", "KEY", "UPDATE"}, listClause},
+	{[]string{"DO", "UPDATE"}, inlineClause},
+	{[]string{"DO", "NOTHING"}, inlineClause},
+	{[]string{"RETURNING"}, listClause},
+	{[]string{"FOR", "NO", "KEY", "UPDATE"}, inlineClause},
+	{[]string{"FOR", "KEY", "SHARE"}, inlineClause},
+	{[]string{"FOR", "UPDATE"}, inlineClause},
+	{[]string{"FOR", "SHARE"}, inlineClause},
+	{[]string{"LOCK", "IN", "SHARE", "MODE"}, inlineClause},
+}
+
+// reservedKeywords are upper-cased wherever they appear. Keywords that are
+// commonly used as identifiers are only upper-cased as part of clauses.
+var reservedKeywords = map[string]bool{
+	"ALL": true, "AND": true, "ANY": true, "AS": true, "ASC": true,
+	"BETWEEN": true, "BY": true, "CASE": true, "CAST": true, "CROSS": true,
+	"DEFAULT": true, "DELETE": true, "DESC": true, "DISTINCT": true,
+	"ELSE": true, "END": true, "EXCEPT": true, "EXISTS": true, "FALSE": true,
+	"FOR": true, "FROM": true, "FULL": true, "GROUP": true, "HAVING": true,
+	"ILIKE": true, "IN": true, "INNER": true, "INSERT": true, "INTERSECT": true,
+	"INTO": true, "IS": true, "JOIN": true, "LATERAL": true, "LEFT": true,
+	"LIKE": true, "LIMIT": true, "NATURAL": true, "NOT": true, "NULL": true,
+	"OFFSET": true, "ON": true, "OR": true, "ORDER": true, "OUTER": true,
+	"OVER": true, "PARTITION": true, "RETURNING": true, "RIGHT": true,
+	"SELECT": true, "SET": true, "SOME": true, "THEN": true, "TRUE": true,
+	"UNION": true, "UPDATE": true, "USING": true, "VALUES": true, "WHEN": true,
+	"WHERE": true, "WITH": true,
+}
+
+// formatLevel is the state of a statement or subquery being formatted.
+type formatLevel struct {
+	// indent is the indentation of the clauses.
+	indent int
+	clause []string
+	layout clauseLayout
+	// parens is the number of open parentheses that aren't subqueries.
+	parens int
+	cases  []openCase
+	// between is set after BETWEEN, whose AND doesn't start a line.
+	between bool
+}
+
+// openCase is a CASE expression being formatted.
+type openCase struct {
+	indent int
+	parens int
+}
+
+type sqlFormatter struct {
+	tokens []sqlToken
+	i      int
+	buf    strings.Builder
+	levels []formatLevel
+
+	// lineIndent is the indentation of the current line, written before its
+	// first token.
+	lineIndent int
+	lineStart  bool
+	prev       sqlToken
+}
+
+func (f *sqlFormatter) level() *formatLevel {
+	return &f.levels[len(f.levels)-1]
+}
+
+// format formats the tokens at f.i and advances f.i.
+func (f *sqlFormatter) format() {
+	tok := f.tokens[f.i]
+	lvl := f.level()
+	topLevel := lvl.parens == 0 && len(lvl.cases) == 0
+
+	if tok.kind == sqlWord && topLevel && f.formatClause(lvl) {
+		return
+	}
+	f.i++
+	if tok.kind == sqlWord && !f.qualified() && f.formatKeyword(lvl, tok, topLevel) {
+		return
+	}
+
+	switch {
+	case tok.kind == sqlComment:
+		f.write(sqlToken{kind: sqlComment, text: tok.text, space: true})
+		if strings.HasPrefix(tok.text, "--") {
+			f.newline(f.lineIndent)
+		}
+
+	case tok.text == "(":
+		f.write(tok)
+		if f.startsSubquery() {
+			f.levels = append(f.levels, formatLevel{indent: f.lineIndent + 1})
+			f.newline(f.lineIndent + 1)
+		} else {
+			lvl.parens++
+		}
+
+	case tok.text == ")":
+		if lvl.parens == 0 && len(f.levels) > 1 {
+			f.levels = f.levels[:len(f.levels)-1]
+			f.newline(lvl.indent - 1)
+		} else if lvl.parens > 0 {
+			lvl.parens--
+		}
+		f.write(tok)
+
+	case tok.text == "," && topLevel && lvl.layout != inlineClause:
+		f.write(tok)
+		f.newline(lvl.indent + 1)
+
+	case tok.text == ";" && len(f.levels) == 1:
+		f.write(tok)
+		f.levels[0] = formatLevel{}
+		f.newline(0)
+
+	default:
+		f.write(tok)
+	}
+}
+
+// formatClause formats the clause starting at f.i, if any.
+func (f *sqlFormatter) formatClause(lvl *formatLevel) bool {
+	clause := f.matchClause(lvl)
+	if clause == nil {
+		return false
+	}
+
+	if clause.layout == joinClause {
+		f.newline(lvl.indent + 1)
+	} else {
+		f.newline(lvl.indent)
+		lvl.clause = clause.words
+		lvl.layout = clause.layout
+		lvl.between = false
+	}
+
+	for i, word := range clause.words {
+		f.write(sqlToken{kind: sqlWord, text: word, space: i > 0})
+	}
+	f.i += len(clause.words)
+
+	if clause.layout == listClause || clause.layout == conditionClause {
+		f.newline(lvl.indent + 1)
+	}
+	return true
+}
+
+func (f *sqlFormatter) matchClause(lvl *formatLevel) *sqlClause {
+	// FROM of IS DISTINCT FROM and USING of joins aren't clauses.
+	if f.prev.text == "." || f.prev.kind == sqlWord && strings.EqualFold(f.prev.text, "DISTINCT") {
+		return nil
+	}
+	inDelete := len(lvl.clause) > 0 && lvl.clause[0] == "DELETE"
+
+	for i := range sqlClauses {
+		clause := &sqlClauses[i]
+		if clause.words[0] == "USING" && !inDelete {
+			continue
+		}
+		if f.matchWords(clause.words) {
+			return clause
+		}
+	}
+	return nil
+}
+
+func (f *sqlFormatter) matchWords(words []string) bool {
+	if f.i+len(words) > len(f.tokens) {
+		return false
+	}
+	for i, word := range words {
+		tok := f.tokens[f.i+i]
+		if tok.kind != sqlWord || !strings.EqualFold(tok.text, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatKeyword formats keywords that start lines outside of clauses: AND and
+// OR of conditions and the branches of CASE expressions.
+func (f *sqlFormatter) formatKeyword(lvl *formatLevel, tok sqlToken, topLevel bool) bool {
+	word := strings.ToUpper(tok.text)
+	switch {
+	case word == "CASE":
+		lvl.cases = append(lvl.cases, openCase{indent: f.lineIndent, parens: lvl.parens})
+		f.write(tok)
+		return true
+
+	case len(lvl.cases) > 0 && lvl.cases[len(lvl.cases)-1].parens == lvl.parens &&
+		(word == "WHEN" || word == "ELSE" || word == "END"):
+		c := lvl.cases[len(lvl.cases)-1]
+		if word == "END" {
+			lvl.cases = lvl.cases[:len(lvl.cases)-1]
+			f.newline(c.indent)
+		} else {
+			f.newline(c.indent + 1)
+		}
+		f.write(tok)
+		return true
+
+	case topLevel && lvl.layout == conditionClause && word == "BETWEEN":
+		lvl.between = true
+
+	case topLevel && lvl.layout == conditionClause && (word == "AND" || word == "OR"):
+		if word == "AND" && lvl.between {
+			lvl.between = false
+			return false
+		}
+		f.newline(lvl.indent + 1)
+		f.write(tok)
+		return true
+	}
+	return false
+}
+
+// startsSubquery reports whether the parenthesis before f.i opens a subquery.
+func (f *sqlFormatter) startsSubquery() bool {
+	for _, tok := range f.tokens[f.i:] {
+		if tok.kind == sqlComment {
+			continue
+		}
+		return tok.kind == sqlWord &&
+			(strings.EqualFold(tok.text, "SELECT") || strings.EqualFold(tok.text, "WITH"))
+	}
+	return false
+}
+
+// newline starts a new line with indent before the next token, unless the
+// current line is empty.
+func (f *sqlFormatter) newline(indent int) {
+	f.lineStart = true
+	f.lineIndent = indent
+}
+
+func (f *sqlFormatter) write(tok sqlToken) {
+	switch {
+	case f.lineStart:
+		if f.buf.Len() > 0 {
+			f.buf.WriteByte('\n')
+		}
+		for i := 0; i < f.lineIndent; i++ {
+			f.buf.WriteString("  ")
+		}
+	case f.prev.text == "," && f.prev.kind == sqlPunct:
+		f.buf.WriteByte(' ')
+	case f.prev.text == "(" || f.prev.text == "[" || tok.text == ")" || tok.text == "]" || tok.text == ",":
+	case tok.space:
+		f.buf.WriteByte(' ')
+	}
+
+	text := tok.text
+	if tok.kind == sqlWord && reservedKeywords[strings.ToUpper(text)] && !f.qualified() {
+		text = strings.ToUpper(text)
+	}
+	f.buf.WriteString(text)
+	f.lineStart = false
+	f.prev = tok
+}
+
+// qualified reports whether the word before f.i is part of a qualified name
+// such as a.end, so that it isn't a keyword.
+func (f *sqlFormatter) qualified() bool {
+	if f.prev.text == "." {
+		return true
+	}
+	return f.i < len(f.tokens) && f.tokens[f.i].text == "."
+}
@@ -0,0 +1,177 @@
+package sqrl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatSql(t *testing.T) {
+	testCases := []struct {
+		sql      string
+		expected string
+	}{
+		{
+			"select a, b from c join d using (e) where f = ? and g in (select h from i)",
+			`SELECT
+  a,
+  b
+FROM
+  c
+  JOIN d USING (e)
+WHERE
+  f = ?
+  AND g IN (
+    SELECT
+      h
+    FROM
+      i
+  )`,
+		},
+		{
+			"SELECT a, CASE WHEN b > 0 AND c THEN 'x' WHEN d BETWEEN 1 AND 2 THEN 'y' ELSE 'z' END AS g " +
+				"FROM h LEFT JOIN i ON i.id = h.i_id AND i.x = 1, j " +
+				"WHERE k BETWEEN $1 AND $2 AND (l = 1 OR m = 2) OR n IS DISTINCT FROM o " +
+				"GROUP BY a, b HAVING count(*) > 1 ORDER BY a DESC, b LIMIT 10 OFFSET 20 FOR UPDATE",
+			`SELECT
+  a,
+  CASE
+    WHEN b > 0 AND c THEN 'x'
+    WHEN d BETWEEN 1 AND 2 THEN 'y'
+    ELSE 'z'
+  END AS g
+FROM
+  h
+  LEFT JOIN i ON i.id = h.i_id AND i.x = 1,
+  j
+WHERE
+  k BETWEEN $1 AND $2
+  AND (l = 1 OR m = 2)
+  OR n IS DISTINCT FROM o
+GROUP BY
+  a,
+  b
+HAVING
+  count(*) > 1
+ORDER BY
+  a DESC,
+  b
+LIMIT 10
+OFFSET 20
+FOR UPDATE`,
+		},
+		{
+			"insert into a (b, c) values (?, ?), (?, 'x''y') on conflict (b) do update set c = excluded.c returning id, b",
+			`INSERT INTO a (b, c)
+VALUES
+  (?, ?),
+  (?, 'x''y')
+ON CONFLICT (b)
+DO UPDATE
+SET
+  c = excluded.c
+RETURNING
+  id,
+  b`,
+		},
+		{
+			"UPDATE a SET b = ?, c = (SELECT max(d) FROM e WHERE e.a = a.id) FROM f WHERE a.f_id = f.id -- comment",
+			`UPDATE a
+SET
+  b = ?,
+  c = (
+    SELECT
+      max(d)
+    FROM
+      e
+    WHERE
+      e.a = a.id
+  )
+FROM
+  f
+WHERE
+  a.f_id = f.id -- comment`,
+		},
+		{
+			"DELETE FROM a USING b WHERE a.b_id = b.id AND b.end = 1 /*action='x'*/",
+			`DELETE FROM a
+USING b
+WHERE
+  a.b_id = b.id
+  AND b.end = 1 /*action='x'*/`,
+		},
+		{
+			"WITH x AS (SELECT 1) SELECT * FROM x UNION ALL SELECT * FROM y; select 1",
+			`WITH x AS (
+  SELECT
+    1
+)
+SELECT
+  *
+FROM
+  x
+UNION ALL
+SELECT
+  *
+FROM
+  y;
+SELECT
+  1`,
+		},
+		{
+			"SELECT a -- where b\nFROM c WHERE d = 'and  or' AND e = E'\\' and' AND f = $$ where $$ AND \"select\" = -1",
+			`SELECT
+  a -- where b
+FROM
+  c
+WHERE
+  d = 'and  or'
+  AND e = E'\' and'
+  AND f = $$ where $$
+  AND "select" = -1`,
+		},
+	}
+
+	for _, tc := range testCases {
+		formatted := FormatSql(tc.sql)
+		assert.Equal(t, tc.expected, formatted, tc.sql)
+		assert.Equal(t, formatted, FormatSql(formatted), "formatting must be idempotent")
+		assert.True(t, strings.EqualFold(Normalize(tc.sql), Normalize(formatted)), "formatting must not change the query: %s", tc.sql)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	caseStmt := Case().
+		When(Eq{"b": 1}, "'one'").
+		Else(Expr("?", "other"))
+
+	q := Select("a").
+		Column(Alias(caseStmt, "c")).
+		From("d").
+		Join("e ON e.id = d.e_id").
+		Where(Eq{"f": []int{1, 2}}).
+		Where(Or{Eq{"g": nil}, Gt{"h": 3}}).
+		PlaceholderFormat(Dollar)
+
+	sql, args, err := Format(q)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"SELECT",
+		"  a,",
+		"  (CASE",
+		"    WHEN b = $1 THEN 'one'",
+		"    ELSE $2",
+		"  END) AS c",
+		"FROM",
+		"  d",
+		"  JOIN e ON e.id = d.e_id",
+		"WHERE",
+		"  f IN ($3, $4)",
+		"  AND (g IS NULL OR h > $5)",
+	}, "\n"), sql)
+	assert.Equal(t, []interface{}{1, "other", 1, 2, 3}, args)
+
+	_, _, err = Format(Update("a"))
+	assert.Error(t, err)
+}
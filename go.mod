@@ -2,6 +2,6 @@ module github.com/SharperShape/sqrl
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/stretchr/testify v1.2.2
 )
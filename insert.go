@@ -6,9 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"io"
 	"sort"
-	"strings"
 )
 
 // InsertBuilder builds SQL INSERT statements.
@@ -31,9 +29,52 @@ func NewInsertBuilder(b StatementBuilderType) *InsertBuilder {
 	return &InsertBuilder{StatementBuilderType: b}
 }
 
-// RunWith sets a Runner (like database/sql.DB) to be used with e.g. Exec.
-func (b *InsertBuilder) RunWith(runner BaseRunner) *InsertBuilder {
-	b.runWith = wrapRunner(runner)
+// Clone returns a deep copy of the builder.
+//
+// Modifying the clone, including the subquery set with Select, never
+// affects the original builder and vice versa.
+func (b *InsertBuilder) Clone() *InsertBuilder {
+	c := *b
+	c.returning = b.returning.clone()
+	c.prefixes = b.prefixes.clone()
+	c.options = cloneStrings(b.options)
+	c.columns = cloneStrings(b.columns)
+	if b.values != nil {
+		c.values = make([][]interface{}, len(b.values))
+		for i, row := range b.values {
+			c.values[i] = cloneValues(row)
+		}
+	}
+	c.suffixes = b.suffixes.clone()
+	if b.iselect != nil {
+		c.iselect = b.iselect.Clone()
+	}
+	return &c
+}
+
+// derive returns the builder that a modification should be applied to: b
+// itself, or a copy of b if the builder is immutable.
+//
+// The copy shares clause slices with b, but their capacity is capped so that
+// appending to them always allocates.
+func (b *InsertBuilder) derive() *InsertBuilder {
+	if !b.immutable {
+		return b
+	}
+	c := *b
+	c.returning = c.returning[:len(c.returning):len(c.returning)]
+	c.prefixes = c.prefixes[:len(c.prefixes):len(c.prefixes)]
+	c.options = c.options[:len(c.options):len(c.options)]
+	c.columns = c.columns[:len(c.columns):len(c.columns)]
+	c.values = c.values[:len(c.values):len(c.values)]
+	c.suffixes = c.suffixes[:len(c.suffixes):len(c.suffixes)]
+	return &c
+}
+
+// RunWith sets a Runner (like database/sql.DB or sql.Conn) to be used with e.g. Exec.
+func (b *InsertBuilder) RunWith(runner BaseRunnerContext) *InsertBuilder {
+	b = b.derive()
+	b.runWith = adaptRunner(runner)
 	return b
 }
 
@@ -88,32 +129,49 @@ func (b *InsertBuilder) Scan(dest ...interface{}) error {
 // PlaceholderFormat sets PlaceholderFormat (e.g. Question or Dollar) for the
 // query.
 func (b *InsertBuilder) PlaceholderFormat(f PlaceholderFormat) *InsertBuilder {
+	b = b.derive()
 	b.placeholderFormat = f
 	return b
 }
 
 // ToSql builds the query into a SQL string and bound args.
 func (b *InsertBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
+	return statementToSql(b, b.StatementBuilderType)
+}
+
+// Tag adds a key/value tag that ToSql appends to the query as a sqlcommenter
+// comment, e.g. "/*route='%2Fusers'*/". Keys and values are URL-encoded.
+//
+// Tags are only rendered for top-level statements, not for subqueries.
+func (b *InsertBuilder) Tag(key, value string) *InsertBuilder {
+	b = b.derive()
+	b.tags = b.tags.with(key, value)
+	return b
+}
+
+// AppendSql implements SqlAppender
+func (b *InsertBuilder) AppendSql(sql *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	var err error
+
 	if len(b.into) == 0 {
-		err = fmt.Errorf("insert statements must specify a table")
-		return
+		return nil, fmt.Errorf("insert statements must specify a table")
 	}
 	if len(b.values) == 0 && b.iselect == nil {
-		err = fmt.Errorf("insert statements must have at least one set of values or select clause")
-		return
+		return nil, fmt.Errorf("insert statements must have at least one set of values or select clause")
 	}
 
-	sql := &bytes.Buffer{}
-
 	if len(b.prefixes) > 0 {
-		args, _ = b.prefixes.AppendToSql(sql, " ", args)
+		args, err = b.prefixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 		sql.WriteString(" ")
 	}
 
 	sql.WriteString("INSERT ")
 
 	if len(b.options) > 0 {
-		sql.WriteString(strings.Join(b.options, " "))
+		writeStrings(sql, b.options, " ")
 		sql.WriteString(" ")
 	}
 
@@ -123,7 +181,7 @@ func (b *InsertBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 
 	if len(b.columns) > 0 {
 		sql.WriteString("(")
-		sql.WriteString(strings.Join(b.columns, ","))
+		writeStrings(sql, b.columns, ",")
 		sql.WriteString(") ")
 	}
 
@@ -133,108 +191,101 @@ func (b *InsertBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 		args, err = b.appendValuesToSQL(sql, args)
 	}
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	if len(b.returning) > 0 {
 		args, err = b.returning.AppendToSql(sql, args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.suffixes) > 0 {
 		sql.WriteString(" ")
-		args, _ = b.suffixes.AppendToSql(sql, " ", args)
+		args, err = b.suffixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	sqlStr, err = b.placeholderFormat.ReplacePlaceholders(sql.String())
-	return
+	return args, nil
 }
 
-func (b *InsertBuilder) appendValuesToSQL(w io.Writer, args []interface{}) ([]interface{}, error) {
+func (b *InsertBuilder) appendValuesToSQL(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
 	if len(b.values) == 0 {
 		return args, errors.New("values for insert statements are not set")
 	}
 
-	io.WriteString(w, "VALUES ")
+	buf.WriteString("VALUES ")
 
-	valuesStrings := make([]string, len(b.values))
 	for r, row := range b.values {
-		valueStrings := make([]string, len(row))
+		if r > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('(')
 		for v, val := range row {
+			if v > 0 {
+				buf.WriteByte(',')
+			}
 
 			switch typedVal := val.(type) {
-			case expr:
-				valueStrings[v] = typedVal.sql
-				args = append(args, typedVal.args...)
 			case Sqlizer:
-				var valSql string
-				var valArgs []interface{}
 				var err error
-
-				valSql, valArgs, err = typedVal.ToSql()
+				args, err = appendSql(buf, typedVal, args)
 				if err != nil {
 					return nil, err
 				}
-
-				valueStrings[v] = valSql
-				args = append(args, valArgs...)
 			default:
-				valueStrings[v] = "?"
+				buf.WriteByte('?')
 				args = append(args, val)
 			}
 		}
-		valuesStrings[r] = fmt.Sprintf("(%s)", strings.Join(valueStrings, ","))
+		buf.WriteByte(')')
 	}
 
-	io.WriteString(w, strings.Join(valuesStrings, ","))
-
 	return args, nil
 }
 
-func (b *InsertBuilder) appendSelectToSQL(w io.Writer, args []interface{}) ([]interface{}, error) {
+func (b *InsertBuilder) appendSelectToSQL(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
 	if b.iselect == nil {
 		return args, errors.New("select clause for insert statements are not set")
 	}
 
-	selectClause, sArgs, err := b.iselect.ToSql()
-	if err != nil {
-		return args, err
-	}
-
-	io.WriteString(w, selectClause)
-	args = append(args, sArgs...)
-
-	return args, nil
+	return b.iselect.AppendSql(buf, args)
 }
 
 // Prefix adds an expression to the beginning of the query
 func (b *InsertBuilder) Prefix(sql string, args ...interface{}) *InsertBuilder {
+	b = b.derive()
 	b.prefixes = append(b.prefixes, Expr(sql, args...))
 	return b
 }
 
 // Options adds keyword options before the INTO clause of the query.
 func (b *InsertBuilder) Options(options ...string) *InsertBuilder {
+	b = b.derive()
 	b.options = append(b.options, options...)
 	return b
 }
 
 // Into sets the INTO clause of the query.
 func (b *InsertBuilder) Into(into string) *InsertBuilder {
+	b = b.derive()
 	b.into = into
 	return b
 }
 
 // Columns adds insert columns to the query.
 func (b *InsertBuilder) Columns(columns ...string) *InsertBuilder {
+	b = b.derive()
 	b.columns = append(b.columns, columns...)
 	return b
 }
 
 // Values adds a single row's values to the query.
 func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
+	b = b.derive()
 	b.values = append(b.values, values)
 	return b
 }
@@ -243,6 +294,7 @@ func (b *InsertBuilder) Values(values ...interface{}) *InsertBuilder {
 //
 // INSERT ... RETURNING is PostgreSQL specific extension
 func (b *InsertBuilder) Returning(columns ...string) *InsertBuilder {
+	b = b.derive()
 	b.returning.Returning(columns...)
 	return b
 }
@@ -251,12 +303,14 @@ func (b *InsertBuilder) Returning(columns ...string) *InsertBuilder {
 //
 // INSERT ... RETURNING is PostgreSQL specific extension
 func (b *InsertBuilder) ReturningSelect(from *SelectBuilder, alias string) *InsertBuilder {
+	b = b.derive()
 	b.returning.ReturningSelect(from, alias)
 	return b
 }
 
 // Suffix adds an expression to the end of the query
 func (b *InsertBuilder) Suffix(sql string, args ...interface{}) *InsertBuilder {
+	b = b.derive()
 	b.suffixes = append(b.suffixes, Expr(sql, args...))
 	return b
 }
@@ -264,6 +318,7 @@ func (b *InsertBuilder) Suffix(sql string, args ...interface{}) *InsertBuilder {
 // SetMap set columns and values for insert builder from a map of column name and value
 // note that it will reset all previous columns and values was set if any
 func (b *InsertBuilder) SetMap(clauses map[string]interface{}) *InsertBuilder {
+	b = b.derive()
 	// TODO: replace resetting previous values with extending existing ones?
 	cols := make([]string, 0, len(clauses))
 	vals := make([]interface{}, 0, len(clauses))
@@ -298,6 +353,7 @@ func (x clauseSlice) Swap(i, j int) {
 // Select set Select clause for insert query
 // If Values and Select are used, then Select has higher priority
 func (b *InsertBuilder) Select(sb *SelectBuilder) *InsertBuilder {
+	b = b.derive()
 	b.iselect = sb
 	return b
 }
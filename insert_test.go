@@ -144,3 +144,33 @@ func TestInsertBuilderSelect(t *testing.T) {
 	expectedArgs := []interface{}{1}
 	assert.Equal(t, expectedArgs, args)
 }
+
+func TestInsertBuilderClone(t *testing.T) {
+	base := Insert("a").Columns("b", "c").Values(1, 2)
+
+	clone := base.Clone().Columns("d").Values(3, 4, 5)
+	base.Values(6, 7)
+
+	sql, args, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO a (b,c) VALUES (?,?),(?,?)", sql)
+	assert.Equal(t, []interface{}{1, 2, 6, 7}, args)
+
+	sql, args, err = clone.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO a (b,c,d) VALUES (?,?),(?,?,?)", sql)
+	assert.Equal(t, []interface{}{1, 2, 3, 4, 5}, args)
+}
+
+func TestInsertBuilderCloneSelect(t *testing.T) {
+	sb := Select("b").From("c")
+	base := Insert("a").Columns("b").Select(sb)
+
+	clone := base.Clone()
+	sb.Where("d = ?", 1)
+
+	sql, args, err := clone.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO a (b) SELECT b FROM c", sql)
+	assert.Empty(t, args)
+}
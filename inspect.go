@@ -0,0 +1,378 @@
+package sqrl
+
+// SelectClauses are the clauses of a SelectBuilder, see
+// SelectBuilder.Clauses.
+type SelectClauses struct {
+	Prefixes []Sqlizer
+	Distinct bool
+	Options  []string
+	Columns  []Sqlizer
+	From     []Sqlizer
+	Joins    []Sqlizer
+	Where    []Sqlizer
+	GroupBy  []string
+	Having   []Sqlizer
+	OrderBy  []string
+
+	Limit     uint64
+	HasLimit  bool
+	Offset    uint64
+	HasOffset bool
+
+	Suffixes []Sqlizer
+}
+
+// InsertClauses are the clauses of an InsertBuilder, see
+// InsertBuilder.Clauses.
+type InsertClauses struct {
+	Prefixes  []Sqlizer
+	Options   []string
+	Into      string
+	Columns   []string
+	Values    [][]interface{}
+	Select    *SelectBuilder
+	Returning []Sqlizer
+	Suffixes  []Sqlizer
+}
+
+// SetClause is a SET clause of an UpdateBuilder.
+type SetClause struct {
+	Column string
+	Value  interface{}
+}
+
+// UpdateClauses are the clauses of an UpdateBuilder, see
+// UpdateBuilder.Clauses.
+type UpdateClauses struct {
+	Prefixes []Sqlizer
+	Table    string
+	Set      []SetClause
+	From     []Sqlizer
+	Where    []Sqlizer
+	OrderBy  []string
+
+	Limit     uint64
+	HasLimit  bool
+	Offset    uint64
+	HasOffset bool
+
+	Returning []Sqlizer
+	Suffixes  []Sqlizer
+}
+
+// DeleteClauses are the clauses of a DeleteBuilder, see
+// DeleteBuilder.Clauses.
+type DeleteClauses struct {
+	Prefixes []Sqlizer
+	What     []string
+	From     string
+	Joins    []Sqlizer
+	Using    []Sqlizer
+	Where    []Sqlizer
+	OrderBy  []string
+
+	Limit     uint64
+	HasLimit  bool
+	Offset    uint64
+	HasOffset bool
+
+	Returning []Sqlizer
+	Suffixes  []Sqlizer
+}
+
+// CaseWhen is a WHEN ... THEN ... part of a CaseBuilder.
+type CaseWhen struct {
+	When Sqlizer
+	Then Sqlizer
+}
+
+// CaseClauses are the parts of a CaseBuilder, see CaseBuilder.Clauses.
+type CaseClauses struct {
+	// What is the value of CASE value WHEN ..., nil if there is none.
+	What Sqlizer
+	When []CaseWhen
+	// Else is nil if there is no ELSE.
+	Else Sqlizer
+}
+
+// Clauses returns a copy of the clauses of the query, which can be inspected
+// without affecting the builder.
+//
+// Expressions given as strings, e.g. to Where or Prefix, are returned as
+// Exprs; maps given to Where are returned as Eq.
+func (b *SelectBuilder) Clauses() SelectClauses {
+	return SelectClauses{
+		Prefixes:  exprSqlizers(b.prefixes.clone()),
+		Distinct:  b.distinct,
+		Options:   cloneStrings(b.options),
+		Columns:   inspectSqlizers(cloneSqlizers(b.columns)),
+		From:      inspectSqlizers(cloneSqlizers(b.fromParts)),
+		Joins:     inspectSqlizers(cloneSqlizers(b.joins)),
+		Where:     inspectSqlizers(cloneSqlizers(b.whereParts)),
+		GroupBy:   cloneStrings(b.groupBys),
+		Having:    inspectSqlizers(cloneSqlizers(b.havingParts)),
+		OrderBy:   cloneStrings(b.orderBys),
+		Limit:     b.limit,
+		HasLimit:  b.limitValid,
+		Offset:    b.offset,
+		HasOffset: b.offsetValid,
+		Suffixes:  exprSqlizers(b.suffixes.clone()),
+	}
+}
+
+// Clauses returns a copy of the clauses of the query, see
+// SelectBuilder.Clauses.
+func (b *InsertBuilder) Clauses() InsertClauses {
+	c := InsertClauses{
+		Prefixes:  exprSqlizers(b.prefixes.clone()),
+		Options:   cloneStrings(b.options),
+		Into:      b.into,
+		Columns:   cloneStrings(b.columns),
+		Returning: inspectSqlizers(cloneSqlizers(b.returning)),
+		Suffixes:  exprSqlizers(b.suffixes.clone()),
+	}
+	if b.values != nil {
+		c.Values = make([][]interface{}, len(b.values))
+		for i, row := range b.values {
+			c.Values[i] = cloneValues(row)
+		}
+	}
+	if b.iselect != nil {
+		c.Select = b.iselect.Clone()
+	}
+	return c
+}
+
+// Clauses returns a copy of the clauses of the query, see
+// SelectBuilder.Clauses.
+func (b *UpdateBuilder) Clauses() UpdateClauses {
+	c := UpdateClauses{
+		Prefixes:  exprSqlizers(b.prefixes.clone()),
+		Table:     b.table,
+		From:      inspectSqlizers(cloneSqlizers(b.fromParts)),
+		Where:     inspectSqlizers(cloneSqlizers(b.whereParts)),
+		OrderBy:   cloneStrings(b.orderBys),
+		Limit:     b.limit,
+		HasLimit:  b.limitValid,
+		Offset:    b.offset,
+		HasOffset: b.offsetValid,
+		Returning: inspectSqlizers(cloneSqlizers(b.returning)),
+		Suffixes:  exprSqlizers(b.suffixes.clone()),
+	}
+	if b.setClauses != nil {
+		c.Set = make([]SetClause, len(b.setClauses))
+		for i, set := range b.setClauses {
+			c.Set[i] = SetClause{Column: set.column, Value: cloneValue(set.value)}
+		}
+	}
+	return c
+}
+
+// Clauses returns a copy of the clauses of the query, see
+// SelectBuilder.Clauses.
+func (b *DeleteBuilder) Clauses() DeleteClauses {
+	c := DeleteClauses{
+		Prefixes:  exprSqlizers(b.prefixes.clone()),
+		What:      cloneStrings(b.what),
+		From:      b.from,
+		Using:     inspectSqlizers(cloneSqlizers(b.usingParts)),
+		Where:     inspectSqlizers(cloneSqlizers(b.whereParts)),
+		OrderBy:   cloneStrings(b.orderBys),
+		Limit:     b.limit,
+		HasLimit:  b.limitValid,
+		Offset:    b.offset,
+		HasOffset: b.offsetValid,
+		Returning: inspectSqlizers(cloneSqlizers(b.returning)),
+		Suffixes:  exprSqlizers(b.suffixes.clone()),
+	}
+	if b.joins != nil {
+		c.Joins = make([]Sqlizer, len(b.joins))
+		for i, join := range b.joins {
+			c.Joins[i] = Expr(join)
+		}
+	}
+	return c
+}
+
+// Clauses returns a copy of the parts of the expression, see
+// SelectBuilder.Clauses.
+func (b *CaseBuilder) Clauses() CaseClauses {
+	c := b.Clone()
+	clauses := CaseClauses{
+		What: inspectSqlizer(c.whatPart),
+		Else: inspectSqlizer(c.elsePart),
+	}
+	for _, p := range c.whenParts {
+		clauses.When = append(clauses.When, CaseWhen{When: inspectSqlizer(p.when), Then: inspectSqlizer(p.then)})
+	}
+	return clauses
+}
+
+// Sql returns the SQL of the expression, with a placeholder for every arg.
+func (e expr) Sql() string {
+	return e.sql
+}
+
+// Args returns the args of the expression. Args that are Sqlizers are
+// written in place of their placeholders.
+func (e expr) Args() []interface{} {
+	return e.args
+}
+
+// Expr returns the aliased expression.
+func (e aliasExpr) Expr() Sqlizer {
+	return e.expr
+}
+
+// Alias returns the alias of the expression.
+func (e aliasExpr) Alias() string {
+	return e.alias
+}
+
+// Walk calls visit for s and, if visit returns true, walks the Sqlizers
+// nested in s, in the order of their SQL: the clauses of builders, as
+// returned by their Clauses methods, the parts of And, Or and CaseBuilder,
+// the expression of Alias, the Sqlizer values of Set and Values, and the
+// Sqlizer args of Expr.
+//
+// Exprs have Sql and Args methods, and Aliases have Expr and Alias methods,
+// so that tooling can inspect them without reflection. Other Sqlizers, such
+// as Eq, are leaves.
+func Walk(s Sqlizer, visit func(s Sqlizer) bool) {
+	if s == nil || !visit(s) {
+		return
+	}
+	for _, child := range children(s) {
+		Walk(child, visit)
+	}
+}
+
+// children returns the Sqlizers nested in s. Unlike Clauses, it doesn't copy
+// them.
+func children(s Sqlizer) []Sqlizer {
+	var c []Sqlizer
+	switch s := s.(type) {
+	case *SelectBuilder:
+		c = append(c, exprSqlizers(s.prefixes)...)
+		c = append(c, inspectSqlizers(s.columns)...)
+		c = append(c, inspectSqlizers(s.fromParts)...)
+		c = append(c, inspectSqlizers(s.joins)...)
+		c = append(c, inspectSqlizers(s.whereParts)...)
+		c = append(c, inspectSqlizers(s.havingParts)...)
+		c = append(c, exprSqlizers(s.suffixes)...)
+	case *InsertBuilder:
+		c = append(c, exprSqlizers(s.prefixes)...)
+		for _, row := range s.values {
+			c = appendSqlizerValues(c, row...)
+		}
+		if s.iselect != nil {
+			c = append(c, s.iselect)
+		}
+		c = append(c, inspectSqlizers(s.returning)...)
+		c = append(c, exprSqlizers(s.suffixes)...)
+	case *UpdateBuilder:
+		c = append(c, exprSqlizers(s.prefixes)...)
+		for _, set := range s.setClauses {
+			c = appendSqlizerValues(c, set.value)
+		}
+		c = append(c, inspectSqlizers(s.fromParts)...)
+		c = append(c, inspectSqlizers(s.whereParts)...)
+		c = append(c, inspectSqlizers(s.returning)...)
+		c = append(c, exprSqlizers(s.suffixes)...)
+	case *DeleteBuilder:
+		c = append(c, exprSqlizers(s.prefixes)...)
+		c = append(c, inspectSqlizers(s.usingParts)...)
+		c = append(c, inspectSqlizers(s.whereParts)...)
+		c = append(c, inspectSqlizers(s.returning)...)
+		c = append(c, exprSqlizers(s.suffixes)...)
+	case *CaseBuilder:
+		c = appendSqlizers(c, inspectSqlizer(s.whatPart))
+		for _, p := range s.whenParts {
+			c = appendSqlizers(c, inspectSqlizer(p.when), inspectSqlizer(p.then))
+		}
+		c = appendSqlizers(c, inspectSqlizer(s.elsePart))
+	case aliasExpr:
+		c = appendSqlizers(c, s.expr)
+	case expr:
+		c = appendSqlizerValues(c, s.args...)
+	case And:
+		c = append(c, inspectSqlizers(s)...)
+	case Or:
+		c = append(c, inspectSqlizers(s)...)
+	case *part, *WherePart:
+		if inner := inspectSqlizer(s); inner != s {
+			c = appendSqlizers(c, inner)
+		}
+	}
+	return c
+}
+
+// inspectSqlizer returns the Sqlizer that s stands for: the Sqlizer wrapped
+// by a part, or an Expr for a string.
+func inspectSqlizer(s Sqlizer) Sqlizer {
+	var pred interface{}
+	var args []interface{}
+	switch p := s.(type) {
+	case *part:
+		pred, args = p.pred, p.args
+	case *WherePart:
+		pred, args = p.pred, p.args
+		if eq, ok := pred.(map[string]interface{}); ok {
+			return Eq(eq)
+		}
+	default:
+		return s
+	}
+
+	switch pred := pred.(type) {
+	case Sqlizer:
+		return pred
+	case string:
+		return Expr(pred, args...)
+	default:
+		// Parts that fail to build are kept, so that their error isn't lost.
+		return s
+	}
+}
+
+func inspectSqlizers(parts []Sqlizer) []Sqlizer {
+	if parts == nil {
+		return nil
+	}
+	inspected := make([]Sqlizer, len(parts))
+	for i, p := range parts {
+		inspected[i] = inspectSqlizer(p)
+	}
+	return inspected
+}
+
+func exprSqlizers(es exprs) []Sqlizer {
+	if es == nil {
+		return nil
+	}
+	sqlizers := make([]Sqlizer, len(es))
+	for i, e := range es {
+		sqlizers[i] = e
+	}
+	return sqlizers
+}
+
+// appendSqlizers appends the Sqlizers of parts that aren't nil.
+func appendSqlizers(c []Sqlizer, parts ...Sqlizer) []Sqlizer {
+	for _, p := range parts {
+		if p != nil {
+			c = append(c, p)
+		}
+	}
+	return c
+}
+
+// appendSqlizerValues appends the values that are Sqlizers.
+func appendSqlizerValues(c []Sqlizer, values ...interface{}) []Sqlizer {
+	for _, v := range values {
+		if s, ok := v.(Sqlizer); ok {
+			c = append(c, inspectSqlizer(s))
+		}
+	}
+	return c
+}
@@ -0,0 +1,199 @@
+package sqrl
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type exprSqlizer interface {
+	Sql() string
+	Args() []interface{}
+}
+
+func TestSelectClauses(t *testing.T) {
+	sub := Select("id").From("c")
+	b := Select("a").Distinct().Options("SQL_NO_CACHE").Column("b + ?", 1).
+		Prefix("WITH x AS (?)", sub).
+		From("t").FromSelect(sub, "s").
+		Join("u ON u.id = t.id").
+		Where("d = ?", 2).Where(map[string]interface{}{"e": 3}).Where(Or{Eq{"f": 4}, Expr("g")}).
+		GroupBy("a").Having("count(*) > ?", 5).OrderBy("a DESC").
+		Limit(10).Offset(0).
+		Suffix("FOR UPDATE")
+
+	c := b.Clauses()
+	assert.Len(t, c.Prefixes, 1)
+	assert.Equal(t, "WITH x AS (?)", c.Prefixes[0].(exprSqlizer).Sql())
+	assert.True(t, c.Distinct)
+	assert.Equal(t, []string{"SQL_NO_CACHE"}, c.Options)
+	assert.Equal(t, []Sqlizer{Expr("a"), Expr("b + ?", 1)}, c.Columns)
+	assert.Equal(t, Expr("t"), c.From[0])
+	assert.Equal(t, "s", c.From[1].(interface{ Alias() string }).Alias())
+	assert.Equal(t, []Sqlizer{Expr("JOIN u ON u.id = t.id")}, c.Joins)
+	assert.Equal(t, []Sqlizer{Expr("d = ?", 2), Eq{"e": 3}, Or{Eq{"f": 4}, Expr("g")}}, c.Where)
+	assert.Equal(t, []string{"a"}, c.GroupBy)
+	assert.Equal(t, []Sqlizer{Expr("count(*) > ?", 5)}, c.Having)
+	assert.Equal(t, []string{"a DESC"}, c.OrderBy)
+	assert.Equal(t, uint64(10), c.Limit)
+	assert.True(t, c.HasLimit)
+	assert.Equal(t, uint64(0), c.Offset)
+	assert.True(t, c.HasOffset)
+	assert.Equal(t, []Sqlizer{Expr("FOR UPDATE")}, c.Suffixes)
+
+	// Clauses are copies.
+	sql := sqlOf(t, b)
+	c.From[1].(aliasExpr).Expr().(*SelectBuilder).Where("h")
+	c.Prefixes[0].(exprSqlizer).Args()[0].(*SelectBuilder).Where("h")
+	c.GroupBy[0] = "z"
+	assert.Equal(t, sql, sqlOf(t, b))
+	assert.Equal(t, "SELECT id FROM c", sqlOf(t, sub))
+}
+
+func TestInsertClauses(t *testing.T) {
+	b := Insert("a").Prefix("/* x */").Options("IGNORE").Columns("b", "c").
+		Values(1, Expr("now()")).Values(2, 3).
+		Returning("id").Suffix("ON CONFLICT DO NOTHING")
+
+	c := b.Clauses()
+	assert.Equal(t, []Sqlizer{Expr("/* x */")}, c.Prefixes)
+	assert.Equal(t, []string{"IGNORE"}, c.Options)
+	assert.Equal(t, "a", c.Into)
+	assert.Equal(t, []string{"b", "c"}, c.Columns)
+	assert.Equal(t, [][]interface{}{{1, Expr("now()")}, {2, 3}}, c.Values)
+	assert.Nil(t, c.Select)
+	assert.Equal(t, []Sqlizer{Expr("id")}, c.Returning)
+	assert.Equal(t, []Sqlizer{Expr("ON CONFLICT DO NOTHING")}, c.Suffixes)
+
+	sub := Select("b").From("c")
+	c = Insert("a").Columns("b").Select(sub).Clauses()
+	assert.Equal(t, sqlOf(t, sub), sqlOf(t, c.Select))
+	c.Select.Where("d")
+	assert.Equal(t, "SELECT b FROM c", sqlOf(t, sub))
+}
+
+func TestUpdateClauses(t *testing.T) {
+	b := Update("a").Prefix("/* x */").Set("b", 1).Set("c", Expr("c + 1")).
+		From("d").Where(Eq{"e": 2}).OrderBy("f").Limit(3).
+		Returning("id").Suffix("-- y")
+
+	c := b.Clauses()
+	assert.Equal(t, []Sqlizer{Expr("/* x */")}, c.Prefixes)
+	assert.Equal(t, "a", c.Table)
+	assert.Equal(t, []SetClause{{"b", 1}, {"c", Expr("c + 1")}}, c.Set)
+	assert.Equal(t, []Sqlizer{Expr("d")}, c.From)
+	assert.Equal(t, []Sqlizer{Eq{"e": 2}}, c.Where)
+	assert.Equal(t, []string{"f"}, c.OrderBy)
+	assert.Equal(t, uint64(3), c.Limit)
+	assert.True(t, c.HasLimit)
+	assert.False(t, c.HasOffset)
+	assert.Equal(t, []Sqlizer{Expr("id")}, c.Returning)
+	assert.Equal(t, []Sqlizer{Expr("-- y")}, c.Suffixes)
+}
+
+func TestDeleteClauses(t *testing.T) {
+	b := Delete("a1", "a2").From("z1 AS a1").Join("a2 ON a2.id = a1.id").
+		Using("u").Where("b = ?", 1).OrderBy("c").Offset(4).
+		ReturningSelect(Select("1"), "one").Suffix("-- y")
+
+	c := b.Clauses()
+	assert.Equal(t, []string{"a1", "a2"}, c.What)
+	assert.Equal(t, "z1 AS a1", c.From)
+	assert.Equal(t, []Sqlizer{Expr("JOIN a2 ON a2.id = a1.id")}, c.Joins)
+	assert.Equal(t, []Sqlizer{Expr("u")}, c.Using)
+	assert.Equal(t, []Sqlizer{Expr("b = ?", 1)}, c.Where)
+	assert.Equal(t, []string{"c"}, c.OrderBy)
+	assert.False(t, c.HasLimit)
+	assert.Equal(t, uint64(4), c.Offset)
+	assert.True(t, c.HasOffset)
+	assert.Equal(t, "one", c.Returning[0].(aliasExpr).Alias())
+	assert.Equal(t, []Sqlizer{Expr("-- y")}, c.Suffixes)
+}
+
+func TestCaseClauses(t *testing.T) {
+	c := Case("a").When("1", Expr("?", "one")).When(Eq{"b": 2}, "'two'").Else("NULL").Clauses()
+	assert.Equal(t, Expr("a"), c.What)
+	assert.Equal(t, []CaseWhen{
+		{When: Expr("1"), Then: Expr("?", "one")},
+		{When: Eq{"b": 2}, Then: Expr("'two'")},
+	}, c.When)
+	assert.Equal(t, Expr("NULL"), c.Else)
+
+	c = Case().When("a", "b").Clauses()
+	assert.Nil(t, c.What)
+	assert.Nil(t, c.Else)
+}
+
+// describe returns the kinds of the Sqlizers walked from s, with the SQL of
+// Exprs.
+func describe(s Sqlizer) []string {
+	var nodes []string
+	Walk(s, func(s Sqlizer) bool {
+		switch s := s.(type) {
+		case exprSqlizer:
+			nodes = append(nodes, "expr "+s.Sql())
+		case interface{ Alias() string }:
+			nodes = append(nodes, "alias "+s.Alias())
+		default:
+			nodes = append(nodes, fmt.Sprintf("%T", s))
+		}
+		return true
+	})
+	return nodes
+}
+
+func TestWalk(t *testing.T) {
+	caseExpr := Case().When(Eq{"a": 1}, "'one'").Else(Expr("b"))
+	b := Select("a").
+		Column(Alias(caseExpr, "c")).
+		FromSelect(Select("d").From("e"), "f").
+		Where(And{Expr("g IN (?)", Select("g").From("h")), Or{Eq{"i": 1}, Lt{"j": 2}}})
+
+	assert.Equal(t, []string{
+		"*sqrl.SelectBuilder",
+		"expr a",
+		"alias c", "*sqrl.CaseBuilder", "sqrl.Eq", "expr 'one'", "expr b",
+		"alias f", "*sqrl.SelectBuilder", "expr d", "expr e",
+		"sqrl.And",
+		"expr g IN (?)", "*sqrl.SelectBuilder", "expr g", "expr h",
+		"sqrl.Or", "sqrl.Eq", "sqrl.Lt",
+	}, describe(b))
+}
+
+func TestWalkWrites(t *testing.T) {
+	assert.Equal(t, []string{
+		"*sqrl.InsertBuilder", "expr now()",
+		"*sqrl.SelectBuilder", "expr b", "expr c",
+		"expr id",
+	}, describe(Insert("a").Values(1, Expr("now()")).Select(Select("b").From("c")).Returning("id")))
+
+	assert.Equal(t, []string{
+		"*sqrl.UpdateBuilder", "*sqrl.SelectBuilder", "expr max(b)", "expr c",
+		"expr d", "sqrl.Eq",
+	}, describe(Update("a").Set("b", Select("max(b)").From("c")).Set("e", 1).From("d").Where(Eq{"f": 2})))
+
+	assert.Equal(t, []string{
+		"*sqrl.DeleteBuilder", "expr WITH x AS (?)", "*sqrl.SelectBuilder", "expr 1",
+		"expr u", "expr b = ?",
+	}, describe(Delete("a").Prefix("WITH x AS (?)", Select("1")).Using("u").Where("b = ?", 1)))
+}
+
+func TestWalkSkip(t *testing.T) {
+	var selects int
+	Walk(Select("a").FromSelect(Select("b").FromSelect(Select("c"), "x"), "y"), func(s Sqlizer) bool {
+		if _, ok := s.(*SelectBuilder); ok {
+			selects++
+			return selects < 2
+		}
+		return true
+	})
+	assert.Equal(t, 2, selects)
+}
+
+func TestExtractDeprecated(t *testing.T) {
+	assert.Nil(t, ExtractColumns(Select("a")))
+	assert.Nil(t, ExtractValues(Delete("a")))
+	assert.Nil(t, ExtractWhereParts(Insert("a")))
+	assert.Equal(t, []string{"b"}, ExtractColumns(Update("a").Set("b", 1)))
+}
@@ -0,0 +1,55 @@
+package sqrl
+
+import (
+	"context"
+	"strings"
+)
+
+// Interpolate returns the SQL of s with its args inlined as literals of
+// dialect d, for connection poolers and proxies that don't support prepared
+// statements, such as PgBouncer in transaction mode.
+//
+// Literals are escaped following the rules of d. Values that can't be encoded
+// safely are refused with an error: types other than nil, bools, numbers,
+// strings, []byte, time.Time, driver.Valuer and pointers to them, strings that
+// aren't valid UTF-8, strings with NUL bytes except on MySQL, and NaN or
+// infinite floats except on PostgreSQL. So are placeholders in string
+// literals, quoted identifiers and comments.
+//
+// MySQL literals assume that the NO_BACKSLASH_ESCAPES SQL mode is off and that
+// the connection character set is UTF-8.
+//
+// Prefer args whenever possible: they never depend on the server
+// configuration.
+func Interpolate(s Sqlizer, d Dialect) (string, error) {
+	return d.inlineArgs(s, false)
+}
+
+// InterpolateStatements returns a Middleware that inlines the args of every
+// statement into its SQL, see Interpolate.
+//
+// Placeholders of SQL not rendered by a builder are "$1" placeholders for
+// PostgreSQL and question marks otherwise; question marks that aren't
+// placeholders make the statement fail. For statements rendered by builders,
+// use the Interpolate mode of StatementBuilder instead.
+func InterpolateStatements(d Dialect) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			if len(stmt.Args) == 0 {
+				return next(ctx, stmt)
+			}
+
+			replace := replaceQuestionPlaceholders
+			if d == PostgreSQL && !strings.Contains(stmt.SQL, "?") {
+				replace = replaceDollarPlaceholders
+			}
+			sql, err := d.interpolate(stmt.SQL, stmt.Args, replace, false)
+			if err != nil {
+				return err
+			}
+
+			stmt.SQL, stmt.Args = sql, nil
+			return next(ctx, stmt)
+		}
+	}
+}
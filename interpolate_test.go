@@ -0,0 +1,332 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var dialects = []Dialect{PostgreSQL, MySQL, SQLite}
+
+// escapingCorpus are strings that must survive a round trip through a string
+// literal of every dialect.
+var escapingCorpus = []string{
+	"",
+	"plain",
+	"O'Brien",
+	"'",
+	"''",
+	"'''",
+	`\`,
+	`\\`,
+	`\'`,
+	`'\`,
+	`\\'`,
+	`\''`,
+	`'; DROP TABLE users; --`,
+	`\'; DROP TABLE users; --`,
+	`\\'; DROP TABLE users; --`,
+	`' OR '1'='1`,
+	`" OR "1"="1`,
+	"/* comment",
+	"*/ DROP TABLE users; /*",
+	"-- comment",
+	"# comment",
+	"\n\r\t\b\f\v",
+	"\x1a",
+	"\\0",
+	"\\x41",
+	"\\u0041",
+	"?",
+	"??",
+	"$1",
+	"$$",
+	"$tag$",
+	"%_",
+	"ü",
+	"日本語",
+	"😀",
+	"  ",
+	"\ufeff",
+	"ʼ OR 1=1", // U+02BC, a quote lookalike
+	"＇ OR 1=1", // U+FF07, a fullwidth quote
+	strings.Repeat("'\\", 100),
+}
+
+// parseStringLiteral parses a string literal of dialect d from the start of
+// lit and returns its value and the remainder of lit.
+//
+// For PostgreSQL and MySQL it parses as if standard_conforming_strings were
+// off or NO_BACKSLASH_ESCAPES were on when alt is true.
+func parseStringLiteral(t *testing.T, d Dialect, lit string, alt bool) (string, string) {
+	backslash := false
+	switch d {
+	case PostgreSQL:
+		if strings.HasPrefix(lit, "E") {
+			backslash = true
+			lit = lit[1:]
+		} else {
+			backslash = alt
+		}
+	case MySQL:
+		backslash = !alt
+	}
+
+	if !strings.HasPrefix(lit, "'") {
+		t.Fatalf("%s literal doesn't start with a quote: %s", d, lit)
+	}
+
+	var value strings.Builder
+	for i := 1; i < len(lit); i++ {
+		c := lit[i]
+		switch {
+		case c == '\'' && i+1 < len(lit) && lit[i+1] == '\'':
+			value.WriteByte('\'')
+			i++
+		case c == '\'':
+			return value.String(), lit[i+1:]
+		case c == '\\' && backslash && i+1 < len(lit):
+			i++
+			switch lit[i] {
+			case '0':
+				value.WriteByte(0)
+			case 'n':
+				value.WriteByte('\n')
+			case 'r':
+				value.WriteByte('\r')
+			case 'Z':
+				value.WriteByte(0x1a)
+			default:
+				value.WriteByte(lit[i])
+			}
+		default:
+			value.WriteByte(c)
+		}
+	}
+	t.Fatalf("unterminated %s literal: %s", d, lit)
+	return "", ""
+}
+
+func TestInterpolateEscapingCorpus(t *testing.T) {
+	for _, d := range dialects {
+		for _, s := range escapingCorpus {
+			lit, err := Interpolate(Expr("?", s), d)
+			assert.NoError(t, err, "%q as %s", s, d)
+
+			value, rest := parseStringLiteral(t, d, lit, false)
+			assert.Equal(t, s, value, "%q as %s: %s", s, d, lit)
+			assert.Empty(t, rest, "%q as %s: %s", s, d, lit)
+
+			// The literal must not end early in any server configuration.
+			if d != SQLite {
+				_, rest = parseStringLiteral(t, d, lit, true)
+				assert.Empty(t, rest, "%q as %s in alternative mode: %s", s, d, lit)
+			}
+		}
+	}
+}
+
+func TestInterpolateRefusedStrings(t *testing.T) {
+	for _, s := range []string{"\xff", "\xbf'", "\xbf\\'", "a\xc0\xafb", "\xed\xa0\x80"} {
+		for _, d := range dialects {
+			_, err := Interpolate(Expr("?", s), d)
+			assert.Error(t, err, "%q as %s", s, d)
+		}
+	}
+
+	for _, d := range []Dialect{PostgreSQL, SQLite} {
+		_, err := Interpolate(Expr("?", "a\x00b"), d)
+		assert.Error(t, err, "NUL as %s", d)
+	}
+	lit, err := Interpolate(Expr("?", "a\x00b"), MySQL)
+	assert.NoError(t, err)
+	assert.Equal(t, `'a\0b'`, lit)
+}
+
+func TestInterpolateBytes(t *testing.T) {
+	values := [][]byte{{}, {0}, []byte("'\\"), {0xde, 0xad, 0xbe, 0xef}, []byte("*/--")}
+	for _, b := range values {
+		for _, d := range dialects {
+			lit, err := Interpolate(Expr("?", b), d)
+			assert.NoError(t, err)
+
+			var encoded string
+			if d == PostgreSQL {
+				assert.True(t, strings.HasSuffix(lit, "::bytea"), lit)
+				value, rest := parseStringLiteral(t, d, lit, false)
+				assert.Equal(t, "::bytea", rest)
+				assert.True(t, strings.HasPrefix(value, `\x`), lit)
+				encoded = value[2:]
+			} else {
+				assert.True(t, strings.HasPrefix(lit, "X'") && strings.HasSuffix(lit, "'"), lit)
+				encoded = lit[2 : len(lit)-1]
+			}
+
+			decoded, err := hex.DecodeString(encoded)
+			assert.NoError(t, err)
+			assert.Equal(t, b, decoded, "%x as %s: %s", b, d, lit)
+		}
+	}
+}
+
+type jsonValuer struct{}
+
+func (jsonValuer) Value() (driver.Value, error) {
+	return struct{}{}, nil
+}
+
+func TestInterpolateValues(t *testing.T) {
+	ts := time.Date(1999, 12, 31, 23, 59, 59, 123456789, time.UTC)
+	one := 1
+	var nilPtr *int
+	var nilValuer *sql.NullString
+
+	testCases := []struct {
+		value  interface{}
+		pg     string
+		mysql  string
+		sqlite string
+	}{
+		{nil, "NULL", "NULL", "NULL"},
+		{nilPtr, "NULL", "NULL", "NULL"},
+		{nilValuer, "NULL", "NULL", "NULL"},
+		{true, "TRUE", "TRUE", "1"},
+		{int64(math.MinInt64), "-9223372036854775808", "-9223372036854775808", "-9223372036854775808"},
+		{int64(math.MaxInt64), "9223372036854775807", "9223372036854775807", "9223372036854775807"},
+		{uint8(255), "255", "255", "255"},
+		{&one, "1", "1", "1"},
+		{1e100, "1e+100", "1e+100", "1e+100"},
+		{-0.5, "-0.5", "-0.5", "-0.5"},
+		{math.NaN(), "'NaN'::float8", "", ""},
+		{math.Inf(1), "'Infinity'::float8", "", ""},
+		{ts, "'1999-12-31 23:59:59.123456789Z'::timestamptz", "'1999-12-31 23:59:59.123456'", "'1999-12-31 23:59:59.123456789+00:00'"},
+		{sql.NullBool{Bool: true, Valid: true}, "TRUE", "TRUE", "1"},
+		{sql.NullFloat64{}, "NULL", "NULL", "NULL"},
+		{valuerStub{value: "x'"}, "'x'''", "'x'''", "'x'''"},
+		{valuerStub{err: errors.New("boom")}, "", "", ""},
+		{jsonValuer{}, "", "", ""},
+		{struct{}{}, "", "", ""},
+		{map[string]int{}, "", "", ""},
+		{[]int{1}, "", "", ""},
+		{[]string{"a"}, "", "", ""},
+		{make(chan int), "", "", ""},
+		{func() {}, "", "", ""},
+		{complex(1, 2), "", "", ""},
+	}
+
+	for _, tc := range testCases {
+		for d, expected := range map[Dialect]string{PostgreSQL: tc.pg, MySQL: tc.mysql, SQLite: tc.sqlite} {
+			lit, err := Interpolate(Expr("?", tc.value), d)
+			if expected == "" {
+				assert.Error(t, err, "%#v as %s must be refused", tc.value, d)
+			} else {
+				assert.NoError(t, err, "%#v as %s", tc.value, d)
+				assert.Equal(t, expected, lit, "%#v as %s", tc.value, d)
+			}
+		}
+	}
+}
+
+func TestInterpolateSeparatesLiterals(t *testing.T) {
+	sql, err := Interpolate(Expr("a -? AND b=?", -1, "\\"), PostgreSQL)
+	assert.NoError(t, err)
+	assert.Equal(t, `a - -1 AND b=E'\\'`, sql)
+
+	sql, err = Interpolate(Expr("x?", []byte{1}), MySQL)
+	assert.NoError(t, err)
+	assert.Equal(t, "x X'01'", sql)
+}
+
+func TestInterpolate(t *testing.T) {
+	s := Select("*").
+		From("users").
+		Where(Eq{"id": []int{1, 2}, "deleted_at": nil}).
+		Where("data ?? 'key' AND name = ?", "?").
+		PlaceholderFormat(Dollar)
+
+	sql, err := Interpolate(s, PostgreSQL)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE deleted_at IS NULL AND id IN (1,2) AND data ? 'key' AND name = '?'", sql)
+
+	_, err = Interpolate(Select("*").From("a").Where("b = ?", struct{}{}), PostgreSQL)
+	assert.EqualError(t, err, "cannot encode value of type struct {} as a SQL literal")
+}
+
+func TestInterpolateQuotedPlaceholders(t *testing.T) {
+	for _, sql := range []string{
+		"a = '?' AND b = ?",
+		"a = ? -- ?",
+		"a = /* ? */ ?",
+		`"?" = ?`,
+		"a = E'\\'' || ? || '?'",
+	} {
+		_, err := Interpolate(Expr(sql, "x", "' OR 1=1 --"), PostgreSQL)
+		assert.Error(t, err, sql)
+	}
+
+	_, _, err := StatementBuilder.Interpolate(MySQL).Select("*").From("a").Where("b = '?'", "x").ToSql()
+	assert.EqualError(t, err, "cannot interpolate placeholder in string literal '?'")
+
+	runner := WrapRunner(&DBStub{}, InterpolateStatements(PostgreSQL))
+	_, err = runner.Exec("SELECT $1 /* $2 */", 1, 2)
+	assert.EqualError(t, err, "cannot interpolate placeholder in comment /* $2 */")
+
+	sql, err := Interpolate(Expr("a = '??' AND b = ?", 1), MySQL)
+	assert.NoError(t, err)
+	assert.Equal(t, "a = '?' AND b = 1", sql)
+}
+
+func TestInterpolateBuilderMode(t *testing.T) {
+	sb := StatementBuilder.Interpolate(MySQL).Tag("action", "list")
+
+	sql, args, err := sb.Select("*").From("users").Where("name = ? AND flags ?? 1", "it's").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM users WHERE name = 'it''s' AND flags ? 1 /*action='list'*/", sql)
+	assert.Nil(t, args)
+
+	sql, args, err = sb.Insert("a").Values(1, "x").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO a VALUES (1,'x') /*action='list'*/", sql)
+	assert.Nil(t, args)
+
+	_, _, err = sb.Update("a").Set("b", []int{1}).ToSql()
+	assert.Error(t, err)
+
+	db := &DBStub{}
+	sb.Delete("a").Where("b = ?", 1).RunWith(db).Exec()
+	assert.Equal(t, "DELETE FROM a WHERE b = 1 /*action='list'*/", db.LastExecSql)
+	assert.Empty(t, db.LastExecArgs)
+}
+
+func TestInterpolateStatements(t *testing.T) {
+	db := &DBStub{}
+	runner := WrapRunner(db, InterpolateStatements(PostgreSQL))
+
+	Update("a").Set("b", "x").Where("c = ?", 1).PlaceholderFormat(Dollar).RunWith(runner).Exec()
+	assert.Equal(t, "UPDATE a SET b = 'x' WHERE c = 1", db.LastExecSql)
+	assert.Empty(t, db.LastExecArgs)
+
+	runner.ExecContext(context.Background(), "SELECT $2, $1, $$x$$", 1, "a")
+	assert.Equal(t, "SELECT 'a', 1, $$x$$", db.LastExecSql)
+
+	runner.Exec("SELECT '?'")
+	assert.Equal(t, "SELECT '?'", db.LastExecSql, "statements without args are not changed")
+
+	runner = WrapRunner(db, InterpolateStatements(MySQL))
+	_, err := runner.Exec("SELECT 'what?', ?", 1)
+	assert.EqualError(t, err, "cannot interpolate placeholder in string literal 'what?'")
+
+	_, err = runner.Exec("SELECT ?, ?", 1)
+	assert.EqualError(t, err, "not enough args for placeholder 2: 1 given")
+
+	_, err = runner.Exec("SELECT ?", struct{}{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,266 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// RunMethod is the runner method that executes a Statement.
+type RunMethod int
+
+const (
+	// MethodExec executes a statement with ExecContext.
+	MethodExec RunMethod = iota
+	// MethodQuery executes a statement with QueryContext.
+	MethodQuery
+	// MethodQueryRow executes a statement with QueryRowContext.
+	MethodQueryRow
+)
+
+func (m RunMethod) String() string {
+	switch m {
+	case MethodExec:
+		return "Exec"
+	case MethodQuery:
+		return "Query"
+	case MethodQueryRow:
+		return "QueryRow"
+	default:
+		return fmt.Sprintf("RunMethod(%d)", int(m))
+	}
+}
+
+// Statement is a statement executed by a runner wrapped with WrapRunner.
+//
+// Middlewares may change SQL and Args before calling the next RunFunc.
+// Duration and RowsAffected are set once the statement has been executed.
+type Statement struct {
+	Method RunMethod
+	SQL    string
+	Args   []interface{}
+
+	// Sqlizer is the builder that rendered SQL when the statement is executed
+	// by a builder or by ExecWith and friends, nil otherwise.
+	Sqlizer Sqlizer
+
+	// Duration is the time spent in the wrapped runner. For QueryRow it
+	// includes scanning the row.
+	Duration time.Duration
+
+	// RowsAffected is the number of rows affected by Exec, -1 if unknown.
+	RowsAffected int64
+
+	result sql.Result
+	rows   *sql.Rows
+	dest   []interface{}
+}
+
+// RunFunc executes a Statement.
+type RunFunc func(ctx context.Context, stmt *Statement) error
+
+// Middleware wraps the execution of every statement of a runner, see
+// WrapRunner.
+type Middleware func(next RunFunc) RunFunc
+
+// WrapRunner returns a runner that executes every statement through
+// middlewares, so that queries can be logged, traced or measured in a single
+// place. The first middleware is the outermost one.
+//
+// The returned runner implements QueryRowerContext and Preparer if runner
+// does. Statements executed with prepared statements don't pass through the
+// middlewares.
+//
+// QueryRow of the returned runner executes the statement when the row is
+// scanned, so that middlewares see the error of the query.
+//
+// runner may have only context methods, such as *sql.Conn; the returned
+// runner always has both.
+func WrapRunner(runner BaseRunnerContext, middlewares ...Middleware) BaseRunner {
+	w := &wrappedRunner{runner: adaptRunner(runner)}
+	w.run = w.exec
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		w.run = middlewares[i](w.run)
+	}
+
+	_, queryRower := w.runner.(QueryRowerContext)
+	_, preparer := w.runner.(Preparer)
+	switch {
+	case queryRower && preparer:
+		return &wrappedQueryRowerPreparer{w}
+	case queryRower:
+		return &wrappedQueryRower{w}
+	case preparer:
+		return &wrappedPreparer{w}
+	default:
+		return w
+	}
+}
+
+// statementRunner is implemented by wrapped and routing runners, so that
+// ExecWith and friends can pass the Sqlizer of a statement to the middlewares
+// and routes.
+type statementRunner interface {
+	execStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (sql.Result, error)
+	queryStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (*sql.Rows, error)
+	queryRowStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) RowScanner
+}
+
+type wrappedRunner struct {
+	runner BaseRunner
+	run    RunFunc
+}
+
+// exec is the innermost RunFunc that executes stmt with the wrapped runner.
+func (w *wrappedRunner) exec(ctx context.Context, stmt *Statement) error {
+	start := time.Now()
+
+	// Pass the Sqlizer on to runners that use it, such as other wrapped
+	// runners.
+	sr, _ := w.runner.(statementRunner)
+
+	var err error
+	switch stmt.Method {
+	case MethodExec:
+		if sr != nil {
+			stmt.result, err = sr.execStatement(ctx, stmt.Sqlizer, stmt.SQL, stmt.Args)
+		} else {
+			stmt.result, err = w.runner.ExecContext(ctx, stmt.SQL, stmt.Args...)
+		}
+		if err == nil && stmt.result != nil {
+			if n, rerr := stmt.result.RowsAffected(); rerr == nil {
+				stmt.RowsAffected = n
+			}
+		}
+	case MethodQuery:
+		if sr != nil {
+			stmt.rows, err = sr.queryStatement(ctx, stmt.Sqlizer, stmt.SQL, stmt.Args)
+		} else {
+			stmt.rows, err = w.runner.QueryContext(ctx, stmt.SQL, stmt.Args...)
+		}
+	case MethodQueryRow:
+		if sr != nil {
+			err = sr.queryRowStatement(ctx, stmt.Sqlizer, stmt.SQL, stmt.Args).Scan(stmt.dest...)
+		} else if qr, ok := w.runner.(QueryRowerContext); ok {
+			err = qr.QueryRowContext(ctx, stmt.SQL, stmt.Args...).Scan(stmt.dest...)
+		} else {
+			err = ErrRunnerNotQueryRunnerContext
+		}
+	default:
+		err = fmt.Errorf("unknown run method %v", stmt.Method)
+	}
+
+	stmt.Duration = time.Since(start)
+	return err
+}
+
+func (w *wrappedRunner) runStatement(ctx context.Context, stmt *Statement) error {
+	stmt.RowsAffected = -1
+	return w.run(ctx, stmt)
+}
+
+func (w *wrappedRunner) execStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (sql.Result, error) {
+	stmt := &Statement{Method: MethodExec, SQL: query, Args: args, Sqlizer: s}
+	if err := w.runStatement(ctx, stmt); err != nil {
+		return nil, err
+	}
+	return stmt.result, nil
+}
+
+func (w *wrappedRunner) queryStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (*sql.Rows, error) {
+	stmt := &Statement{Method: MethodQuery, SQL: query, Args: args, Sqlizer: s}
+	if err := w.runStatement(ctx, stmt); err != nil {
+		return nil, err
+	}
+	return stmt.rows, nil
+}
+
+func (w *wrappedRunner) queryRowStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) RowScanner {
+	return &wrappedRow{ctx: ctx, w: w, stmt: Statement{Method: MethodQueryRow, SQL: query, Args: args, Sqlizer: s}}
+}
+
+func (w *wrappedRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return w.execStatement(ctx, nil, query, args)
+}
+
+func (w *wrappedRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return w.queryStatement(ctx, nil, query, args)
+}
+
+func (w *wrappedRunner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return w.ExecContext(context.Background(), query, args...)
+}
+
+func (w *wrappedRunner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return w.QueryContext(context.Background(), query, args...)
+}
+
+// wrappedRow runs the statement when it is first scanned. Like *sql.Row, it
+// can only be scanned once: further scans return the error of the first one,
+// or sql.ErrNoRows if it succeeded.
+type wrappedRow struct {
+	ctx     context.Context
+	w       *wrappedRunner
+	stmt    Statement
+	scanned bool
+	err     error
+}
+
+func (r *wrappedRow) Scan(dest ...interface{}) error {
+	if r.scanned {
+		if r.err != nil {
+			return r.err
+		}
+		return sql.ErrNoRows
+	}
+	r.scanned = true
+	r.stmt.dest = dest
+	r.err = r.w.runStatement(r.ctx, &r.stmt)
+	r.stmt.dest = nil
+	return r.err
+}
+
+type wrappedQueryRower struct {
+	*wrappedRunner
+}
+
+func (w *wrappedQueryRower) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return w.queryRowStatement(ctx, nil, query, args)
+}
+
+func (w *wrappedQueryRower) QueryRow(query string, args ...interface{}) RowScanner {
+	return w.QueryRowContext(context.Background(), query, args...)
+}
+
+type wrappedPreparer struct {
+	*wrappedRunner
+}
+
+func (w *wrappedPreparer) Prepare(query string) (*sql.Stmt, error) {
+	return w.runner.(Preparer).Prepare(query)
+}
+
+func (w *wrappedPreparer) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return w.runner.(Preparer).PrepareContext(ctx, query)
+}
+
+type wrappedQueryRowerPreparer struct {
+	*wrappedRunner
+}
+
+func (w *wrappedQueryRowerPreparer) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return w.queryRowStatement(ctx, nil, query, args)
+}
+
+func (w *wrappedQueryRowerPreparer) QueryRow(query string, args ...interface{}) RowScanner {
+	return w.QueryRowContext(context.Background(), query, args...)
+}
+
+func (w *wrappedQueryRowerPreparer) Prepare(query string) (*sql.Stmt, error) {
+	return w.runner.(Preparer).Prepare(query)
+}
+
+func (w *wrappedQueryRowerPreparer) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	return w.runner.(Preparer).PrepareContext(ctx, query)
+}
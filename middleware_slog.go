@@ -0,0 +1,60 @@
+//go:build go1.21
+// +build go1.21
+
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+// LogStatements returns a Middleware that logs every statement to logger:
+// successful ones at debug level, failed ones at error level.
+//
+// sql.ErrNoRows returned by QueryRow is not logged as an error.
+func LogStatements(logger *slog.Logger) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			err := next(ctx, stmt)
+			if err != nil && err != sql.ErrNoRows {
+				logger.LogAttrs(ctx, slog.LevelError, "sql statement failed", statementAttrs(stmt, err)...)
+			} else {
+				logger.LogAttrs(ctx, slog.LevelDebug, "sql statement", statementAttrs(stmt, nil)...)
+			}
+			return err
+		}
+	}
+}
+
+// LogSlowStatements returns a Middleware that logs statements taking at least
+// threshold to logger at warning level.
+func LogSlowStatements(logger *slog.Logger, threshold time.Duration) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			err := next(ctx, stmt)
+			if stmt.Duration >= threshold {
+				attrs := append(statementAttrs(stmt, err), slog.Duration("threshold", threshold))
+				logger.LogAttrs(ctx, slog.LevelWarn, "slow sql statement", attrs...)
+			}
+			return err
+		}
+	}
+}
+
+func statementAttrs(stmt *Statement, err error) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", stmt.Method.String()),
+		slog.String("sql", stmt.SQL),
+		slog.Any("args", stmt.Args),
+		slog.Duration("duration", stmt.Duration),
+	}
+	if stmt.RowsAffected >= 0 {
+		attrs = append(attrs, slog.Int64("rows_affected", stmt.RowsAffected))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	return attrs
+}
@@ -0,0 +1,73 @@
+//go:build go1.21
+// +build go1.21
+
+package sqrl
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLogger(buf *bytes.Buffer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+func TestLogStatements(t *testing.T) {
+	db, fake := newFakeDB()
+	var buf bytes.Buffer
+	runner := WrapRunner(db, LogStatements(newTestLogger(&buf)))
+
+	_, err := Update("a").Set("b", 1).RunWith(runner).Exec()
+	assert.NoError(t, err)
+
+	fake.execErr = func(string) error { return errors.New("boom") }
+	_, err = runner.Exec("DELETE FROM a")
+	assert.Error(t, err)
+
+	fake.execErr = nil
+	err = Select("a").From("b").RunWith(runner).Scan()
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	assert.Equal(t, []string{
+		`level=DEBUG msg="sql statement" method=Exec sql="UPDATE a SET b = ?" args=[1] rows_affected=1`,
+		`level=ERROR msg="sql statement failed" method=Exec sql="DELETE FROM a" args=[] error=boom`,
+		`level=DEBUG msg="sql statement" method=QueryRow sql="SELECT a FROM b" args=[]`,
+	}, strings.Split(strings.TrimSpace(buf.String()), "\n"))
+}
+
+func TestLogSlowStatements(t *testing.T) {
+	db := &DBStub{}
+	var buf bytes.Buffer
+	slow := func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			err := next(ctx, stmt)
+			if strings.Contains(stmt.SQL, "slow") {
+				stmt.Duration = time.Second
+			}
+			return err
+		}
+	}
+	runner := WrapRunner(db, LogSlowStatements(newTestLogger(&buf), 100*time.Millisecond), slow)
+
+	runner.Exec("SELECT fast")
+	runner.Query("SELECT slow")
+
+	assert.Equal(t, `level=WARN msg="slow sql statement" method=Query sql="SELECT slow" args=[] threshold=100ms`,
+		strings.TrimSpace(buf.String()))
+}
@@ -0,0 +1,174 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordStatements returns a Middleware that appends the statements it sees
+// to stmts, prefixed with name.
+func recordStatements(name string, log *[]string, stmts *[]Statement) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			*log = append(*log, name+" before")
+			err := next(ctx, stmt)
+			*log = append(*log, name+" after")
+			if stmts != nil {
+				*stmts = append(*stmts, *stmt)
+			}
+			return err
+		}
+	}
+}
+
+func TestWrapRunner(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.columns = []string{"a"}
+	fake.rows = [][]driver.Value{{int64(1)}}
+
+	var log []string
+	var stmts []Statement
+	runner := WrapRunner(db,
+		recordStatements("outer", &log, nil),
+		recordStatements("inner", &log, &stmts))
+
+	update := Update("a").Set("b", 1).Where("c = ?", 2).PlaceholderFormat(Dollar)
+	_, err := update.RunWith(runner).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"outer before", "inner before", "inner after", "outer after"}, log)
+
+	assert.Len(t, stmts, 1)
+	assert.Equal(t, MethodExec, stmts[0].Method)
+	assert.Equal(t, "UPDATE a SET b = $1 WHERE c = $2", stmts[0].SQL)
+	assert.Equal(t, []interface{}{1, 2}, stmts[0].Args)
+	assert.Equal(t, update, stmts[0].Sqlizer)
+	assert.Equal(t, int64(1), stmts[0].RowsAffected)
+	assert.NotZero(t, stmts[0].Duration)
+
+	var a int
+	err = Select("a").From("b").RunWith(runner).QueryRowContext(context.Background()).Scan(&a)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+	assert.Equal(t, MethodQueryRow, stmts[1].Method)
+	assert.Equal(t, int64(-1), stmts[1].RowsAffected)
+
+	rows, err := runner.Query("SELECT a FROM b")
+	assert.NoError(t, err)
+	rows.Close()
+	assert.Equal(t, MethodQuery, stmts[2].Method)
+	assert.Nil(t, stmts[2].Sqlizer)
+}
+
+func TestWrapRunnerErrors(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.execErr = func(query string) error {
+		return errors.New("boom")
+	}
+
+	var errs []error
+	runner := WrapRunner(db, func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			err := next(ctx, stmt)
+			errs = append(errs, err)
+			return err
+		}
+	})
+
+	_, err := runner.Exec("DELETE FROM a")
+	assert.EqualError(t, err, "boom")
+
+	fake.execErr = nil
+	err = runner.(QueryRowerContext).QueryRowContext(context.Background(), "SELECT a FROM b").Scan()
+	assert.Equal(t, sql.ErrNoRows, err)
+
+	assert.Equal(t, []error{errors.New("boom"), sql.ErrNoRows}, errs)
+}
+
+func TestWrapRunnerQueryRowRunsOnce(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.columns = []string{"a"}
+	fake.rows = [][]driver.Value{{int64(1)}}
+
+	n := 0
+	runner := WrapRunner(db, func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			n++
+			return next(ctx, stmt)
+		}
+	})
+
+	row := runner.(QueryRowerContext).QueryRowContext(context.Background(), "SELECT a FROM b")
+	var a int
+	assert.NoError(t, row.Scan(&a))
+	assert.Equal(t, 1, a)
+	assert.Equal(t, sql.ErrNoRows, row.Scan(&a))
+	assert.Equal(t, 1, n)
+	assert.Equal(t, 1, fake.Count("query SELECT a FROM b"))
+
+	fake.execErr = func(query string) error {
+		return errors.New("boom")
+	}
+	row = runner.(QueryRowerContext).QueryRowContext(context.Background(), "SELECT a FROM b")
+	assert.EqualError(t, row.Scan(&a), "boom")
+	assert.EqualError(t, row.Scan(&a), "boom")
+	assert.Equal(t, 2, n)
+}
+
+func TestWrapRunnerRewritesStatement(t *testing.T) {
+	db := &DBStub{}
+	runner := WrapRunner(db, func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			stmt.SQL += " /* tagged */"
+			return next(ctx, stmt)
+		}
+	})
+
+	Delete("a").RunWith(runner).Exec()
+	assert.Equal(t, "DELETE FROM a /* tagged */", db.LastExecSql)
+
+	Select("a").From("b").RunWith(runner).QueryRow().Scan()
+	assert.Equal(t, "SELECT a FROM b /* tagged */", db.LastQueryRowSql)
+}
+
+type baseRunnerStub struct {
+	BaseRunner
+}
+
+func TestWrapRunnerCapabilities(t *testing.T) {
+	runner := WrapRunner(&DBStub{})
+	_, ok := runner.(QueryRowerContext)
+	assert.True(t, ok)
+	_, ok = runner.(Preparer)
+	assert.True(t, ok)
+
+	db, _ := newFakeDB()
+	runner = WrapRunner(db)
+	_, ok = runner.(QueryRowerContext)
+	assert.True(t, ok, "*sql.DB QueryRowContext must be preserved")
+	_, ok = runner.(Preparer)
+	assert.True(t, ok)
+
+	runner = WrapRunner(baseRunnerStub{&DBStub{}})
+	_, ok = runner.(QueryRowerContext)
+	assert.False(t, ok)
+	_, ok = runner.(Preparer)
+	assert.False(t, ok)
+	assert.Equal(t, ErrRunnerNotQueryRunnerContext, Select("a").RunWith(runner).Scan())
+
+	conn, err := db.Conn(context.Background())
+	assert.NoError(t, err)
+	defer conn.Close()
+	runner = WrapRunner(conn)
+	_, ok = runner.(QueryRowerContext)
+	assert.True(t, ok, "*sql.Conn QueryRowContext must be preserved")
+	var a int
+	assert.Equal(t, sql.ErrNoRows, Select("a").From("b").RunWith(runner).Scan(&a))
+
+	w := &wrappedRunner{runner: adaptRunner(baseRunnerStub{&DBStub{}})}
+	assert.Equal(t, ErrRunnerNotQueryRunnerContext, w.exec(context.Background(), &Statement{Method: MethodQueryRow}))
+}
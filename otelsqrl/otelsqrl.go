@@ -0,0 +1,107 @@
+// Package otelsqrl adapts OpenTelemetry tracing to sqrl.Tracer.
+//
+//	runner := sqrl.WrapRunner(db, sqrl.TraceStatements(otelsqrl.NewTracer()))
+package otelsqrl
+
+import (
+	"context"
+	"strings"
+
+	"github.com/SharperShape/sqrl"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/SharperShape/sqrl/otelsqrl"
+
+// Span attributes set for every statement.
+const (
+	OperationKey   = attribute.Key("db.operation")
+	TablesKey      = attribute.Key("db.sql.tables")
+	FingerprintKey = attribute.Key("db.statement")
+	MethodKey      = attribute.Key("sqrl.method")
+)
+
+type config struct {
+	provider trace.TracerProvider
+	attrs    []attribute.KeyValue
+}
+
+// Option configures the Tracer returned by NewTracer.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider. The global one is used by
+// default.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// WithAttributes adds attributes to every span, e.g. semconv.DBSystemPostgreSQL.
+func WithAttributes(attrs ...attribute.KeyValue) Option {
+	return func(c *config) {
+		c.attrs = append(c.attrs, attrs...)
+	}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+	attrs  []attribute.KeyValue
+}
+
+// NewTracer returns a sqrl.Tracer that starts an OpenTelemetry client span for
+// every statement.
+//
+// Spans are named after the kind of the statement and its first table, e.g.
+// "SELECT users". The db.statement attribute holds the fingerprint of the
+// statement rather than its SQL, so that no argument ends up in traces.
+func NewTracer(opts ...Option) sqrl.Tracer {
+	c := &config{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.provider == nil {
+		c.provider = otel.GetTracerProvider()
+	}
+	return &tracer{
+		tracer: c.provider.Tracer(instrumentationName),
+		attrs:  c.attrs,
+	}
+}
+
+func (t *tracer) StartSpan(ctx context.Context, info sqrl.SpanInfo) (context.Context, sqrl.Span) {
+	name := info.Kind
+	if len(info.Tables) > 0 {
+		name += " " + info.Tables[0]
+	}
+
+	attrs := make([]attribute.KeyValue, 0, len(t.attrs)+4)
+	attrs = append(attrs, t.attrs...)
+	attrs = append(attrs,
+		OperationKey.String(info.Kind),
+		FingerprintKey.String(info.Fingerprint),
+		MethodKey.String(info.Method.String()))
+	if len(info.Tables) > 0 {
+		attrs = append(attrs, TablesKey.StringSlice(info.Tables))
+	}
+
+	ctx, span := t.tracer.Start(ctx, strings.TrimSpace(name),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...))
+	return ctx, &otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s *otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,75 @@
+package otelsqrl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/SharperShape/sqrl"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type execerStub struct {
+	sqrl.BaseRunner
+	err error
+}
+
+func (e *execerStub) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return nil, e.err
+}
+
+func newTestTracer() (sqrl.Tracer, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	return NewTracer(
+		WithTracerProvider(provider),
+		WithAttributes(attribute.String("db.system", "postgresql"))), exporter
+}
+
+func TestTracer(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	runner := sqrl.WrapRunner(&execerStub{}, sqrl.TraceStatements(tracer))
+
+	_, err := sqrl.Update("users").
+		Set("name", "moe").
+		Where(sqrl.Eq{"id": []int{1, 2}}).
+		PlaceholderFormat(sqrl.Dollar).
+		RunWith(runner).
+		Exec()
+	assert.NoError(t, err)
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	span := spans[0]
+	assert.Equal(t, "UPDATE users", span.Name)
+	assert.Equal(t, trace.SpanKindClient, span.SpanKind)
+	assert.Equal(t, codes.Unset, span.Status.Code)
+	assert.Equal(t, []attribute.KeyValue{
+		attribute.String("db.system", "postgresql"),
+		OperationKey.String("UPDATE"),
+		FingerprintKey.String("UPDATE users SET name = ? WHERE id IN (?)"),
+		MethodKey.String("Exec"),
+		TablesKey.StringSlice([]string{"users"}),
+	}, span.Attributes)
+}
+
+func TestTracerError(t *testing.T) {
+	tracer, exporter := newTestTracer()
+	runner := sqrl.WrapRunner(&execerStub{err: errors.New("boom")}, sqrl.TraceStatements(tracer))
+
+	_, err := runner.ExecContext(context.Background(), "DELETE FROM users")
+	assert.EqualError(t, err, "boom")
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "DELETE users", spans[0].Name)
+	assert.Equal(t, codes.Error, spans[0].Status.Code)
+	assert.Equal(t, "boom", spans[0].Status.Description)
+	assert.Len(t, spans[0].Events, 1, "error must be recorded")
+}
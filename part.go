@@ -1,8 +1,8 @@
 package sqrl
 
 import (
+	"bytes"
 	"fmt"
-	"io"
 )
 
 type part struct {
@@ -29,27 +29,18 @@ func (p part) ToSql() (sql string, args []interface{}, err error) {
 	return
 }
 
-func appendToSql(parts []Sqlizer, w io.Writer, sep string, args []interface{}) ([]interface{}, error) {
-	for i, p := range parts {
-		partSql, partArgs, err := p.ToSql()
-		if err != nil {
-			return nil, err
-		} else if len(partSql) == 0 {
-			continue
-		}
-
-		if i > 0 {
-			_, err := io.WriteString(w, sep)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		_, err = io.WriteString(w, partSql)
-		if err != nil {
-			return nil, err
-		}
-		args = append(args, partArgs...)
+// AppendSql implements SqlAppender
+func (p part) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	switch pred := p.pred.(type) {
+	case nil:
+		// no-op
+	case Sqlizer:
+		return appendSql(buf, pred, args)
+	case string:
+		buf.WriteString(pred)
+		args = append(args, p.args...)
+	default:
+		return nil, fmt.Errorf("expected string or Sqlizer, not %T", pred)
 	}
 	return args, nil
 }
@@ -30,6 +30,18 @@ func (a array) ToSql() (string, []interface{}, error) {
 	return "?", []interface{}{buf.String()}, nil
 }
 
+// AppendSql implements sqrl.SqlAppender
+func (a array) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	if err := checkArrayType(a.value); err != nil {
+		return nil, err
+	}
+
+	var value bytes.Buffer
+	marshalArray(reflect.ValueOf(a.value), &value)
+	buf.WriteByte('?')
+	return append(args, value.String()), nil
+}
+
 type marshaler func(reflect.Value, *bytes.Buffer)
 
 var marshalers = map[reflect.Kind]marshaler{
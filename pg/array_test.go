@@ -1,6 +1,7 @@
 package pg_test
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -72,3 +73,22 @@ func ExampleArray() {
 	// INSERT INTO posts (content,tags) VALUES ($1,$2)
 	// [Lorem Ipsum {"foo","bar"}]
 }
+
+func TestArrayAppendSql(t *testing.T) {
+	buf := &bytes.Buffer{}
+	args, err := pg.Array([]int{1, 2}).(sqrl.SqlAppender).AppendSql(buf, []interface{}{"foo"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "?", buf.String())
+	assert.Equal(t, []interface{}{"foo", "{1,2}"}, args)
+
+	_, err = pg.Array(42).(sqrl.SqlAppender).AppendSql(buf, nil)
+	assert.Error(t, err)
+}
+
+func TestArrayDebugSql(t *testing.T) {
+	s := sqrl.Insert("posts").Columns("tags").Values(pg.Array([]string{"it's", `"quoted"`}))
+	assert.Equal(t, "-- sqrl.DebugSql: for debugging only, do not execute\n"+
+		`INSERT INTO posts (tags) VALUES (E'{"it''s","\\"quoted\\""}')`,
+		sqrl.DebugSql(s, sqrl.PostgreSQL))
+}
@@ -1,6 +1,7 @@
 package pg
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 
@@ -37,3 +38,15 @@ func (jo jsonOp) ToSql() (string, []interface{}, error) {
 
 	return fmt.Sprintf("?::%s", jo.tpe), []interface{}{string(v)}, nil
 }
+
+// AppendSql implements sqrl.SqlAppender
+func (jo jsonOp) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	v, err := json.Marshal(jo.value)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to serialize %s value: %v", jo.tpe, err)
+	}
+
+	buf.WriteString("?::")
+	buf.WriteString(jo.tpe)
+	return append(args, string(v)), nil
+}
@@ -1,6 +1,7 @@
 package pg_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"testing"
@@ -71,3 +72,22 @@ func ExampleJSONB() {
 	// INSERT INTO posts (content,tags) VALUES ($1,$2::jsonb)
 	// [Lorem Ipsum ["foo","bar"]]
 }
+
+func TestJSONAppendSql(t *testing.T) {
+	buf := &bytes.Buffer{}
+	args, err := pg.JSONB([]int{1, 2}).(sqrl.SqlAppender).AppendSql(buf, []interface{}{"foo"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "?::jsonb", buf.String())
+	assert.Equal(t, []interface{}{"foo", "[1,2]"}, args)
+
+	_, err = pg.JSON(invalidValue{}).(sqrl.SqlAppender).AppendSql(buf, nil)
+	assert.Error(t, err)
+}
+
+func TestJSONDebugSql(t *testing.T) {
+	s := sqrl.Update("posts").Set("meta", pg.JSONB(map[string]string{"author": "O'Brien"}))
+	assert.Equal(t, "-- sqrl.DebugSql: for debugging only, do not execute\n"+
+		`UPDATE posts SET meta = '{"author":"O''Brien"}'::jsonb`,
+		sqrl.DebugSql(s, sqrl.PostgreSQL))
+}
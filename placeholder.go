@@ -47,6 +47,63 @@ func (_ dollarFormat) ReplacePlaceholdersMixed(sql string, args []interface{}) (
 	})
 }
 
+// replaceBytes is ReplacePlaceholders for a SQL statement that is still in a
+// buffer. It copies sql into the resulting string in a single pass.
+func (_ dollarFormat) replaceBytes(sql []byte) string {
+	n := bytes.Count(sql, questionMark)
+	if n == 0 {
+		return string(sql)
+	}
+
+	digits := 1
+	for m := n; m >= 10; m /= 10 {
+		digits++
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(sql) + n*digits)
+
+	var num [20]byte
+	i := 0
+	for {
+		p := bytes.IndexByte(sql, '?')
+		if p == -1 {
+			break
+		}
+
+		sb.Write(sql[:p])
+		if p+1 < len(sql) && sql[p+1] == '?' { // escape ?? => ?
+			sb.WriteByte('?')
+			sql = sql[p+2:]
+			continue
+		}
+
+		i++
+		sb.WriteByte('$')
+		sb.Write(strconv.AppendInt(num[:0], int64(i), 10))
+		sql = sql[p+1:]
+	}
+
+	sb.Write(sql)
+	return sb.String()
+}
+
+var questionMark = []byte{'?'}
+
+// replacePlaceholdersBytes applies placeholder format f to a SQL statement
+// that is still in a buffer. Question and Dollar formats don't need any
+// intermediate copies of sql, other formats fall back to ReplacePlaceholders.
+func replacePlaceholdersBytes(f PlaceholderFormat, sql []byte) (string, error) {
+	switch f := f.(type) {
+	case nil, questionFormat:
+		return string(sql), nil
+	case dollarFormat:
+		return f.replaceBytes(sql), nil
+	default:
+		return f.ReplacePlaceholders(string(sql))
+	}
+}
+
 // Placeholders returns a string with count ? placeholders joined with commas.
 func Placeholders(count int) string {
 	if count < 1 {
@@ -0,0 +1,669 @@
+package sqrl
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueryCache is a Runner that caches the rows of selects, see NewQueryCache.
+type QueryCache interface {
+	Runner
+
+	// BeginTx begins a transaction with the runner of the cache. Selects of
+	// the transaction aren't cached, and the tables it writes are invalidated
+	// when it ends.
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (TxRunner, error)
+
+	// Stats returns a snapshot of the cache statistics.
+	Stats() QueryCacheStats
+
+	// Invalidate removes the cached rows of selects that read any of tables,
+	// e.g. after they were written without the cache.
+	Invalidate(tables ...string)
+
+	// InvalidateAll removes all cached rows.
+	InvalidateAll()
+}
+
+// QueryCacheStats holds statistics of a QueryCache.
+type QueryCacheStats struct {
+	Hits   uint64
+	Misses uint64
+
+	// Invalidations is the number of writes and calls of Invalidate and
+	// InvalidateAll that removed cached rows.
+	Invalidations uint64
+}
+
+// HitRate returns the share of cacheable selects answered by the cache, 0 if
+// there were none.
+func (s QueryCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// CachedRows are the rows of a select held by a QueryCacheStore.
+type CachedRows struct {
+	Columns []string
+	Rows    [][]interface{}
+
+	// Tables are the tables read by the select, in lower case.
+	Tables []string
+}
+
+// QueryCacheStore holds the rows of a QueryCache. It must be safe for
+// concurrent use.
+type QueryCacheStore interface {
+	// Get returns the rows stored under key.
+	Get(key string) (*CachedRows, bool)
+
+	// Set stores rows under key.
+	Set(key string, rows *CachedRows)
+
+	// Invalidate removes the rows that read any of tables, given in lower
+	// case.
+	Invalidate(tables []string)
+
+	// InvalidateAll removes all rows.
+	InvalidateAll()
+}
+
+// QueryCacheOption configures a QueryCache.
+type QueryCacheOption func(*queryCache)
+
+// QueryCacheStorage makes the cache keep its rows in store instead of an
+// in-memory store of up to 1000 results that expire after a minute.
+func QueryCacheStorage(store QueryCacheStore) QueryCacheOption {
+	return func(c *queryCache) {
+		c.store = store
+	}
+}
+
+// NewQueryCache returns a Runner that caches the rows returned by the selects
+// of SelectBuilders run with it, keyed by their SQL and args.
+//
+// Rows are cached with the tables the select reads, as reported by
+// ExtractTableDependencies. Selects that read no table, lock rows or write
+// are run with runner every time, and statements that aren't built by a
+// SelectBuilder are never cached.
+//
+// Other statements run with the cache invalidate the rows of the tables they
+// write as reported by SqlTableDependencies, rather than all the tables of
+// ExtractTableNames, so that the tables a write only reads stay cached and
+// SQL not built by a builder is handled too. Statements that write no known
+// table invalidate every row, e.g. CALL, unless they are selects, e.g. with
+// FOR UPDATE. Writes made without the cache aren't seen, nor are writes to
+// the tables of views; call Invalidate after them, or use a store with a
+// short TTL.
+//
+// Rows are read completely when a select is first run, so the cache must not
+// be used for large results.
+func NewQueryCache(runner BaseRunnerContext, opts ...QueryCacheOption) QueryCache {
+	c := &queryCache{db: runner, runner: adaptRunner(runner)}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.store == nil {
+		c.store = NewLRUQueryCacheStore(1000, time.Minute)
+	}
+	return c
+}
+
+type queryCache struct {
+	db     BaseRunnerContext
+	runner BaseRunner
+	store  QueryCacheStore
+
+	hits          uint64
+	misses        uint64
+	invalidations uint64
+
+	// mu orders invalidations and stores, so that rows read before a write
+	// aren't stored after it; generation counts the invalidations.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func (c *queryCache) Stats() QueryCacheStats {
+	return QueryCacheStats{
+		Hits:          atomic.LoadUint64(&c.hits),
+		Misses:        atomic.LoadUint64(&c.misses),
+		Invalidations: atomic.LoadUint64(&c.invalidations),
+	}
+}
+
+func (c *queryCache) Invalidate(tables ...string) {
+	c.invalidate(normalizeTableNames(tables), false)
+}
+
+func (c *queryCache) InvalidateAll() {
+	c.invalidate(nil, true)
+}
+
+func (c *queryCache) invalidate(tables []string, all bool) {
+	atomic.AddUint64(&c.invalidations, 1)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+	if all {
+		c.store.InvalidateAll()
+	} else {
+		c.store.Invalidate(tables)
+	}
+}
+
+// written invalidates the rows of the tables written by query.
+func (c *queryCache) written(query string) {
+	tables, all := writtenTables(query)
+	if all || len(tables) > 0 {
+		c.invalidate(tables, all)
+	}
+}
+
+func (c *queryCache) query(ctx context.Context, s Sqlizer, query string, args []interface{}) (*CachedRows, error) {
+	tables, ok := cacheableTables(s, query)
+	if !ok {
+		return nil, nil
+	}
+
+	key := queryCacheKey(query, args)
+	if cached, ok := c.store.Get(key); ok {
+		atomic.AddUint64(&c.hits, 1)
+		return cached, nil
+	}
+	atomic.AddUint64(&c.misses, 1)
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	rows, err := queryRunner(ctx, c.runner, s, query, args)
+	if err != nil {
+		return nil, err
+	}
+	cached, err := readCachedRows(rows, tables)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if c.generation == generation {
+		c.store.Set(key, cached)
+	}
+	c.mu.Unlock()
+	return cached, nil
+}
+
+func (c *queryCache) execStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (sql.Result, error) {
+	defer c.written(query)
+	if sr, ok := c.runner.(statementRunner); ok {
+		return sr.execStatement(ctx, s, query, args)
+	}
+	return c.runner.ExecContext(ctx, query, args...)
+}
+
+func (c *queryCache) queryStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (*sql.Rows, error) {
+	cached, err := c.query(ctx, s, query, args)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		return cachedRowsDB().QueryContext(ctx, "", cached)
+	}
+
+	defer c.written(query)
+	return queryRunner(ctx, c.runner, s, query, args)
+}
+
+func (c *queryCache) queryRowStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) RowScanner {
+	cached, err := c.query(ctx, s, query, args)
+	if err != nil {
+		return &Row{err: err}
+	}
+	if cached != nil {
+		rows, err := cachedRowsDB().QueryContext(ctx, "", cached)
+		if err != nil {
+			return &Row{err: err}
+		}
+		return &rowsScanner{rows}
+	}
+
+	queryRower, ok := c.runner.(QueryRowerContext)
+	if !ok {
+		return &Row{err: ErrRunnerNotQueryRunnerContext}
+	}
+	defer c.written(query)
+	if sr, ok := c.runner.(statementRunner); ok {
+		return sr.queryRowStatement(ctx, s, query, args)
+	}
+	return queryRower.QueryRowContext(ctx, query, args...)
+}
+
+func (c *queryCache) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return c.execStatement(ctx, nil, query, args)
+}
+
+func (c *queryCache) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return c.queryStatement(ctx, nil, query, args)
+}
+
+func (c *queryCache) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return c.queryRowStatement(ctx, nil, query, args)
+}
+
+func (c *queryCache) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.ExecContext(context.Background(), query, args...)
+}
+
+func (c *queryCache) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.QueryContext(context.Background(), query, args...)
+}
+
+func (c *queryCache) QueryRow(query string, args ...interface{}) RowScanner {
+	return c.QueryRowContext(context.Background(), query, args...)
+}
+
+func (c *queryCache) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxRunner, error) {
+	tx, err := beginTx(ctx, c.db, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &queryCacheTx{TxRunner: tx, c: c}, nil
+}
+
+// queryCacheTx runs the statements of a transaction without the cache, and
+// invalidates the tables they write when it ends. The tables are invalidated
+// after a rollback too, as selects run during the transaction may have seen
+// its writes, e.g. with READ UNCOMMITTED.
+type queryCacheTx struct {
+	TxRunner
+	c *queryCache
+
+	mu     sync.Mutex
+	tables []string
+	all    bool
+}
+
+func (tx *queryCacheTx) written(query string) {
+	tables, all := writtenTables(query)
+	tx.mu.Lock()
+	tx.tables = append(tx.tables, tables...)
+	tx.all = tx.all || all
+	tx.mu.Unlock()
+}
+
+func (tx *queryCacheTx) end(err error) error {
+	tx.mu.Lock()
+	tables, all := tx.tables, tx.all
+	tx.mu.Unlock()
+	if all || len(tables) > 0 {
+		tx.c.invalidate(tables, all)
+	}
+	return err
+}
+
+func (tx *queryCacheTx) Commit() error {
+	return tx.end(tx.TxRunner.Commit())
+}
+
+func (tx *queryCacheTx) Rollback() error {
+	return tx.end(tx.TxRunner.Rollback())
+}
+
+func (tx *queryCacheTx) execStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (sql.Result, error) {
+	tx.written(query)
+	if sr, ok := tx.TxRunner.(statementRunner); ok {
+		return sr.execStatement(ctx, s, query, args)
+	}
+	return tx.TxRunner.ExecContext(ctx, query, args...)
+}
+
+func (tx *queryCacheTx) queryStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (*sql.Rows, error) {
+	tx.written(query)
+	return queryRunner(ctx, tx.TxRunner, s, query, args)
+}
+
+func (tx *queryCacheTx) queryRowStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) RowScanner {
+	tx.written(query)
+	if sr, ok := tx.TxRunner.(statementRunner); ok {
+		return sr.queryRowStatement(ctx, s, query, args)
+	}
+	return tx.TxRunner.QueryRowContext(ctx, query, args...)
+}
+
+func (tx *queryCacheTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return tx.execStatement(ctx, nil, query, args)
+}
+
+func (tx *queryCacheTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.queryStatement(ctx, nil, query, args)
+}
+
+func (tx *queryCacheTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return tx.queryRowStatement(ctx, nil, query, args)
+}
+
+func (tx *queryCacheTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return tx.ExecContext(context.Background(), query, args...)
+}
+
+func (tx *queryCacheTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(context.Background(), query, args...)
+}
+
+func (tx *queryCacheTx) QueryRow(query string, args ...interface{}) RowScanner {
+	return tx.QueryRowContext(context.Background(), query, args...)
+}
+
+// queryRunner Querys query with runner, passing s on if runner uses it.
+func queryRunner(ctx context.Context, runner BaseRunner, s Sqlizer, query string, args []interface{}) (*sql.Rows, error) {
+	if sr, ok := runner.(statementRunner); ok {
+		return sr.queryStatement(ctx, s, query, args)
+	}
+	return runner.QueryContext(ctx, query, args...)
+}
+
+// cacheableTables returns the tables read by query, built by s, if its rows
+// can be cached.
+func cacheableTables(s Sqlizer, query string) ([]string, bool) {
+	if !isReadOnlySelect(s, query) {
+		return nil, false
+	}
+	deps := SqlTableDependencies(query)
+	if len(deps.Read) == 0 || len(deps.Written) > 0 || deps.Unknown {
+		return nil, false
+	}
+	return normalizeTableNames(deps.Read), true
+}
+
+// writtenTables returns the tables written by query, or true if they aren't
+// known. Selects write no table unless they write into one, even if they
+// lock rows.
+func writtenTables(query string) ([]string, bool) {
+	deps := SqlTableDependencies(query)
+	if len(deps.Written) == 0 || deps.Unknown {
+		return nil, !isSelectSql(query)
+	}
+	return normalizeTableNames(deps.Written), false
+}
+
+func normalizeTableNames(tables []string) []string {
+	normalized := make([]string, len(tables))
+	for i, table := range tables {
+		normalized[i] = normalizeTableName(table)
+	}
+	return normalized
+}
+
+// normalizeTableName returns table without quotes, in lower case.
+func normalizeTableName(table string) string {
+	fields := strings.Fields(table)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToLower(strings.NewReplacer(`"`, "", "`", "").Replace(fields[0]))
+}
+
+// isSelectSql reports whether query is a select, possibly with a WITH
+// clause.
+func isSelectSql(query string) bool {
+	tokens := scanSql(query)
+	for len(tokens) > 0 && tokens[0].kind == sqlComment {
+		tokens = tokens[1:]
+	}
+	return len(tokens) > 0 && tokens[0].kind == sqlWord &&
+		(strings.EqualFold(tokens[0].text, "SELECT") || strings.EqualFold(tokens[0].text, "WITH"))
+}
+
+// queryCacheKey returns the key of the rows of query with args. Pointers are
+// followed and driver.Valuers are valued, so that the key depends on the
+// values used by the database.
+func queryCacheKey(query string, args []interface{}) string {
+	h := sha256.New()
+	io.WriteString(h, query)
+	for _, arg := range args {
+		if valuer, ok := arg.(driver.Valuer); ok {
+			if v, err := valuer.Value(); err == nil {
+				arg = v
+			}
+		}
+		v := reflect.ValueOf(arg)
+		for v.Kind() == reflect.Ptr && !v.IsNil() {
+			v = v.Elem()
+		}
+		if v.IsValid() {
+			arg = v.Interface()
+		}
+		fmt.Fprintf(h, "\x00%T\x00%#v", arg, arg)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// readCachedRows reads and closes rows.
+func readCachedRows(rows *sql.Rows, tables []string) (*CachedRows, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	cached := &CachedRows{Columns: columns, Tables: tables}
+	for rows.Next() {
+		row := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(columns))
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		cached.Rows = append(cached.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return cached, rows.Close()
+}
+
+// NewLRUQueryCacheStore returns an in-memory QueryCacheStore that holds up to
+// capacity results, evicting the least recently used one, for up to ttl. A
+// capacity or ttl of 0 means unlimited.
+func NewLRUQueryCacheStore(capacity int, ttl time.Duration) QueryCacheStore {
+	return &lruQueryCacheStore{
+		capacity: capacity,
+		ttl:      ttl,
+		now:      time.Now,
+		entries:  make(map[string]*list.Element),
+		tables:   make(map[string]map[string]struct{}),
+	}
+}
+
+type lruQueryCacheStore struct {
+	capacity int
+	ttl      time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	lru     list.List
+	entries map[string]*list.Element
+	// tables holds the keys of the entries of every table.
+	tables map[string]map[string]struct{}
+}
+
+type lruQueryCacheEntry struct {
+	key     string
+	rows    *CachedRows
+	expires time.Time
+}
+
+func (s *lruQueryCacheStore) Get(key string) (*CachedRows, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	elem, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruQueryCacheEntry)
+	if s.ttl > 0 && !s.now().Before(entry.expires) {
+		s.remove(elem)
+		return nil, false
+	}
+	s.lru.MoveToFront(elem)
+	return entry.rows, true
+}
+
+func (s *lruQueryCacheStore) Set(key string, rows *CachedRows) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.entries[key]; ok {
+		s.remove(elem)
+	}
+	entry := &lruQueryCacheEntry{key: key, rows: rows}
+	if s.ttl > 0 {
+		entry.expires = s.now().Add(s.ttl)
+	}
+	s.entries[key] = s.lru.PushFront(entry)
+	for _, table := range rows.Tables {
+		keys := s.tables[table]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			s.tables[table] = keys
+		}
+		keys[key] = struct{}{}
+	}
+
+	for s.capacity > 0 && s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *lruQueryCacheStore) Invalidate(tables []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, table := range tables {
+		for key := range s.tables[table] {
+			s.remove(s.entries[key])
+		}
+	}
+}
+
+func (s *lruQueryCacheStore) InvalidateAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lru.Init()
+	s.entries = make(map[string]*list.Element)
+	s.tables = make(map[string]map[string]struct{})
+}
+
+// remove removes the entry of elem. s.mu must be held.
+func (s *lruQueryCacheStore) remove(elem *list.Element) {
+	entry := s.lru.Remove(elem).(*lruQueryCacheEntry)
+	delete(s.entries, entry.key)
+	for _, table := range entry.rows.Tables {
+		keys := s.tables[table]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(s.tables, table)
+		}
+	}
+}
+
+var (
+	cachedRowsOnce sync.Once
+	cachedRowsPool *sql.DB
+)
+
+// cachedRowsDB returns the database that serves CachedRows as sql.Rows: its
+// queries return the rows passed as their only arg.
+func cachedRowsDB() *sql.DB {
+	cachedRowsOnce.Do(func() {
+		cachedRowsPool = sql.OpenDB(cachedRowsConnector{})
+	})
+	return cachedRowsPool
+}
+
+type cachedRowsConnector struct{}
+
+func (c cachedRowsConnector) Connect(context.Context) (driver.Conn, error) {
+	return cachedRowsConn{}, nil
+}
+
+func (c cachedRowsConnector) Driver() driver.Driver {
+	return cachedRowsDriver{}
+}
+
+type cachedRowsDriver struct{}
+
+func (cachedRowsDriver) Open(string) (driver.Conn, error) {
+	return cachedRowsConn{}, nil
+}
+
+type cachedRowsConn struct{}
+
+func (cachedRowsConn) Prepare(string) (driver.Stmt, error) {
+	return nil, fmt.Errorf("cached rows cannot be prepared")
+}
+
+func (cachedRowsConn) Close() error {
+	return nil
+}
+
+func (cachedRowsConn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("cached rows have no transactions")
+}
+
+func (cachedRowsConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (cachedRowsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return &cachedRowsIter{rows: args[0].Value.(*CachedRows)}, nil
+}
+
+type cachedRowsIter struct {
+	rows *CachedRows
+	i    int
+}
+
+func (r *cachedRowsIter) Columns() []string {
+	return r.rows.Columns
+}
+
+func (r *cachedRowsIter) Close() error {
+	return nil
+}
+
+func (r *cachedRowsIter) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows.Rows) {
+		return io.EOF
+	}
+	row := r.rows.Rows[r.i]
+	r.i++
+	for i := range dest {
+		// Copy bytes, so that scanning into sql.RawBytes can't modify the
+		// cached rows.
+		if b, ok := row[i].([]byte); ok {
+			dest[i] = append([]byte(nil), b...)
+		} else {
+			dest[i] = row[i]
+		}
+	}
+	return nil
+}
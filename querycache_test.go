@@ -0,0 +1,244 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newCachedFakeDB() (QueryCache, *fakeDB) {
+	db, fake := newFakeDB()
+	fake.columns = []string{"id", "name"}
+	fake.rows = [][]driver.Value{{int64(1), []byte("moe")}, {int64(2), []byte("larry")}}
+	return NewQueryCache(db), fake
+}
+
+func scanNames(t *testing.T, b *SelectBuilder) []string {
+	rows, err := b.Query()
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var id int64
+		var name string
+		assert.NoError(t, rows.Scan(&id, &name))
+		names = append(names, name)
+	}
+	assert.NoError(t, rows.Err())
+	return names
+}
+
+func TestQueryCache(t *testing.T) {
+	cache, fake := newCachedFakeDB()
+	users := StatementBuilder.Immutable().RunWith(cache).Select("id", "name").From("users")
+
+	assert.Equal(t, []string{"moe", "larry"}, scanNames(t, users))
+	assert.Equal(t, []string{"moe", "larry"}, scanNames(t, users))
+	assert.Equal(t, 1, fake.Count("query SELECT id, name FROM users"))
+
+	var name string
+	assert.NoError(t, users.Where(Eq{"id": 1}).QueryRow().Scan(new(int64), &name))
+	assert.NoError(t, users.Where(Eq{"id": 1}).QueryRow().Scan(new(int64), &name))
+	assert.Equal(t, "moe", name)
+	assert.Equal(t, 1, fake.Count("query SELECT id, name FROM users WHERE id = ?"))
+
+	users.Where(Eq{"id": 2}).QueryRow().Scan(new(int64), &name)
+	assert.Equal(t, 2, fake.Count("query SELECT id, name FROM users WHERE id = ?"))
+
+	stats := cache.Stats()
+	assert.Equal(t, QueryCacheStats{Hits: 2, Misses: 3}, stats)
+	assert.InDelta(t, 0.4, stats.HitRate(), 1e-9)
+}
+
+func TestQueryCacheInvalidation(t *testing.T) {
+	cache, fake := newCachedFakeDB()
+	users := Select("id", "name").From("users u").RunWith(cache)
+	groups := Select("id", "name").From("groups").RunWith(cache)
+
+	scanNames(t, users)
+	scanNames(t, groups)
+
+	_, err := Update(`"Users"`).Set("name", "curly").Where(Eq{"id": 1}).RunWith(cache).Exec()
+	assert.NoError(t, err)
+
+	scanNames(t, users)
+	scanNames(t, groups)
+	assert.Equal(t, 2, fake.Count("query SELECT id, name FROM users u"))
+	assert.Equal(t, 1, fake.Count("query SELECT id, name FROM groups"))
+
+	cache.Exec("TRUNCATE groups")
+	scanNames(t, users)
+	scanNames(t, groups)
+	assert.Equal(t, 2, fake.Count("query SELECT id, name FROM users u"))
+	assert.Equal(t, 2, fake.Count("query SELECT id, name FROM groups"))
+
+	cache.Exec("CALL reset()")
+	scanNames(t, users)
+	scanNames(t, groups)
+	assert.Equal(t, 3, fake.Count("query SELECT id, name FROM users u"))
+	assert.Equal(t, 3, fake.Count("query SELECT id, name FROM groups"))
+
+	cache.Invalidate("GROUPS")
+	scanNames(t, users)
+	scanNames(t, groups)
+	assert.Equal(t, 3, fake.Count("query SELECT id, name FROM users u"))
+	assert.Equal(t, 4, fake.Count("query SELECT id, name FROM groups"))
+
+	assert.Equal(t, uint64(4), cache.Stats().Invalidations)
+}
+
+func TestQueryCacheDependencies(t *testing.T) {
+	testCases := map[string]struct {
+		q     *SelectBuilder
+		write Sqlizer
+	}{
+		"join": {
+			Select("id", "name").From("users").Join("emails USING (id)"),
+			Update("emails").Set("verified", true),
+		},
+		"subquery": {
+			Select("id", "name").From("users").Where(Expr("id IN (SELECT id FROM admins)")),
+			Insert("admins").Values(1),
+		},
+		"from select": {
+			Select("id", "name").FromSelect(Select("id", "name").From("users"), "u"),
+			Delete("users").Where(Eq{"id": 1}),
+		},
+		"joined in parentheses": {
+			Select("id", "name").From("(users JOIN emails USING (id))"),
+			Update("emails").Set("verified", true),
+		},
+		"listed after join": {
+			Select("id", "name").From("users").Join("teams t ON t.id = users.team_id, emails"),
+			Update("emails").Set("verified", true),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cache, fake := newCachedFakeDB()
+			scanNames(t, tc.q.RunWith(cache))
+			scanNames(t, tc.q.RunWith(cache))
+			assert.Equal(t, 1, fake.Count("query "))
+
+			Update("groups").Set("name", "stooges").RunWith(cache).Exec()
+			scanNames(t, tc.q.RunWith(cache))
+			assert.Equal(t, 1, fake.Count("query "))
+
+			_, err := ExecWith(cache, tc.write)
+			assert.NoError(t, err)
+			scanNames(t, tc.q.RunWith(cache))
+			assert.Equal(t, 2, fake.Count("query "))
+		})
+	}
+}
+
+func TestQueryCacheUncacheable(t *testing.T) {
+	testCases := map[string]*SelectBuilder{
+		"for update":    Select("id", "name").From("users").Suffix("FOR UPDATE"),
+		"no table":      Select("1, 'moe'"),
+		"unknown table": Select("id", "name").From("users").Join("? e ON e.id = users.id", "emails"),
+	}
+
+	for name, q := range testCases {
+		t.Run(name, func(t *testing.T) {
+			cache, fake := newCachedFakeDB()
+			groups := Select("id", "name").From("groups").RunWith(cache)
+			scanNames(t, groups)
+
+			scanNames(t, q.RunWith(cache))
+			scanNames(t, q.RunWith(cache))
+			scanNames(t, groups)
+			assert.Equal(t, 3, fake.Count("query "))
+			assert.Equal(t, uint64(1), cache.Stats().Hits, "uncacheable selects must not invalidate")
+			assert.Equal(t, uint64(1), cache.Stats().Misses)
+			assert.Zero(t, cache.Stats().Invalidations)
+		})
+	}
+}
+
+func TestQueryCacheRawQueries(t *testing.T) {
+	cache, fake := newCachedFakeDB()
+	users := Select("id", "name").From("users").RunWith(cache)
+
+	scanNames(t, users)
+	rows, err := cache.Query("SELECT id, name FROM users")
+	assert.NoError(t, err)
+	rows.Close()
+	scanNames(t, users)
+	assert.Equal(t, 2, fake.Count("query SELECT id, name FROM users"))
+
+	rows, err = cache.Query("DELETE FROM users RETURNING id, name")
+	assert.NoError(t, err)
+	rows.Close()
+	scanNames(t, users)
+	assert.Equal(t, 3, fake.Count("query SELECT id, name FROM users"))
+}
+
+func TestQueryCacheInTx(t *testing.T) {
+	cache, fake := newCachedFakeDB()
+	users := StatementBuilder.Immutable().RunWith(cache).Select("id", "name").From("users")
+
+	scanNames(t, users)
+	err := InTx(context.Background(), cache, nil, func(tx Runner) error {
+		scanNames(t, users.RunWith(tx))
+		_, err := Delete("users").Where(Eq{"id": 1}).RunWith(tx).Exec()
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.Count("query SELECT id, name FROM users"))
+	assert.Equal(t, 1, fake.Count("commit"))
+
+	scanNames(t, users)
+	assert.Equal(t, 3, fake.Count("query SELECT id, name FROM users"))
+}
+
+func TestQueryCacheArgs(t *testing.T) {
+	a, b := 1, 1
+	assert.Equal(t, queryCacheKey("q", []interface{}{&a}), queryCacheKey("q", []interface{}{&b}))
+	assert.Equal(t, queryCacheKey("q", []interface{}{1}), queryCacheKey("q", []interface{}{&a}))
+
+	b = 2
+	assert.NotEqual(t, queryCacheKey("q", []interface{}{&a}), queryCacheKey("q", []interface{}{&b}))
+	assert.NotEqual(t, queryCacheKey("q", []interface{}{1}), queryCacheKey("q", []interface{}{"1"}))
+	assert.NotEqual(t, queryCacheKey("q", []interface{}{1}), queryCacheKey("q ", []interface{}{1}))
+}
+
+func TestLRUQueryCacheStore(t *testing.T) {
+	store := NewLRUQueryCacheStore(2, time.Minute).(*lruQueryCacheStore)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	a := &CachedRows{Tables: []string{"a"}}
+	b := &CachedRows{Tables: []string{"b"}}
+	ab := &CachedRows{Tables: []string{"a", "b"}}
+
+	store.Set("a", a)
+	store.Set("b", b)
+	_, ok := store.Get("a")
+	assert.True(t, ok)
+
+	// b is the least recently used.
+	store.Set("ab", ab)
+	_, ok = store.Get("b")
+	assert.False(t, ok)
+
+	store.Invalidate([]string{"b"})
+	_, ok = store.Get("ab")
+	assert.False(t, ok)
+	rows, ok := store.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, a, rows)
+
+	now = now.Add(time.Minute)
+	_, ok = store.Get("a")
+	assert.False(t, ok)
+	assert.Empty(t, store.entries)
+	assert.Empty(t, store.tables)
+}
@@ -0,0 +1,172 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync/atomic"
+)
+
+// ReadWriteOption configures a runner returned by NewReadWriteRunner.
+type ReadWriteOption func(*readWriteRunner)
+
+// ReadWriteReplicaSelector registers a function that picks the replica of
+// every statement sent to replicas, given their number n. The statement is
+// sent to the primary if the returned index is negative or not less than n,
+// e.g. when every replica is unhealthy. Replicas are picked round-robin by
+// default.
+func ReadWriteReplicaSelector(selector func(ctx context.Context, n int) int) ReadWriteOption {
+	return func(r *readWriteRunner) {
+		r.selector = selector
+	}
+}
+
+type primaryKey struct{}
+
+// WithPrimary returns a copy of ctx that sends every statement of runners
+// returned by NewReadWriteRunner to the primary, e.g. to read rows written
+// just before without replication lag.
+func WithPrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// NewReadWriteRunner returns a Runner that sends the queries of
+// SelectBuilders to replicas and every other statement to primary.
+//
+// Selects are sent to the primary if they lock rows (FOR UPDATE, FOR SHARE,
+// LOCK IN SHARE MODE), if they contain INTO, RETURNING or data-modifying
+// statements, if they're executed with Exec, or if their context comes from
+// WithPrimary. Statements that aren't built by a SelectBuilder, such as raw
+// SQL and Templates, are always sent to the primary. Selects that call
+// functions with side effects must be run with WithPrimary.
+//
+// Transactions are never run on replicas: the returned runner has a BeginTx
+// method that begins transactions on primary, so that it can be passed to
+// InTx, and if primary is itself a transaction every statement is sent to it.
+//
+// Statements of builders are routed by the builder that is run; wrap the
+// returned runner with WrapRunner rather than the runners passed to it to
+// keep middlewares in front of the routing.
+func NewReadWriteRunner(primary BaseRunnerContext, replicas []BaseRunnerContext, opts ...ReadWriteOption) Runner {
+	r := &readWriteRunner{db: primary, primary: adaptRunner(primary)}
+	switch primary.(type) {
+	case TxRunner, *sql.Tx:
+		// Never leave a transaction.
+	default:
+		for _, replica := range replicas {
+			r.replicas = append(r.replicas, adaptRunner(replica))
+		}
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+type readWriteRunner struct {
+	db       BaseRunnerContext
+	primary  BaseRunner
+	replicas []BaseRunner
+	selector func(ctx context.Context, n int) int
+	next     uint64
+}
+
+// route returns the runner of the query of s.
+func (r *readWriteRunner) route(ctx context.Context, s Sqlizer, query string) BaseRunner {
+	if len(r.replicas) == 0 || ctx.Value(primaryKey{}) != nil || !isReadOnlySelect(s, query) {
+		return r.primary
+	}
+
+	n := len(r.replicas)
+	var i int
+	if r.selector != nil {
+		i = r.selector(ctx, n)
+	} else {
+		i = int((atomic.AddUint64(&r.next, 1) - 1) % uint64(n))
+	}
+	if i < 0 || i >= n {
+		return r.primary
+	}
+	return r.replicas[i]
+}
+
+// isReadOnlySelect reports whether query, built by s, is a select that can
+// be run on a replica.
+func isReadOnlySelect(s Sqlizer, query string) bool {
+	if _, ok := s.(*SelectBuilder); !ok {
+		return false
+	}
+	return !hasWriteKeywords(scanSql(query))
+}
+
+// hasWriteKeywords reports whether tokens contain keywords of locking
+// clauses or writes, even if the keywords are used otherwise, e.g. in
+// SUBSTRING(a FOR 2).
+func hasWriteKeywords(tokens []sqlToken) bool {
+	for _, tok := range tokens {
+		if tok.kind != sqlWord {
+			continue
+		}
+		switch strings.ToUpper(tok.text) {
+		case "FOR", "LOCK", "INTO", "RETURNING", "INSERT", "UPDATE", "DELETE", "MERGE":
+			return true
+		}
+	}
+	return false
+}
+
+// BeginTx begins a transaction on the primary.
+func (r *readWriteRunner) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxRunner, error) {
+	return beginTx(ctx, r.db, opts)
+}
+
+func (r *readWriteRunner) execStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (sql.Result, error) {
+	if sr, ok := r.primary.(statementRunner); ok {
+		return sr.execStatement(ctx, s, query, args)
+	}
+	return r.primary.ExecContext(ctx, query, args...)
+}
+
+func (r *readWriteRunner) queryStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) (*sql.Rows, error) {
+	runner := r.route(ctx, s, query)
+	if sr, ok := runner.(statementRunner); ok {
+		return sr.queryStatement(ctx, s, query, args)
+	}
+	return runner.QueryContext(ctx, query, args...)
+}
+
+func (r *readWriteRunner) queryRowStatement(ctx context.Context, s Sqlizer, query string, args []interface{}) RowScanner {
+	runner := r.route(ctx, s, query)
+	queryRower, ok := runner.(QueryRowerContext)
+	if !ok {
+		return &Row{err: ErrRunnerNotQueryRunnerContext}
+	}
+	if sr, ok := runner.(statementRunner); ok {
+		return sr.queryRowStatement(ctx, s, query, args)
+	}
+	return queryRower.QueryRowContext(ctx, query, args...)
+}
+
+func (r *readWriteRunner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.execStatement(ctx, nil, query, args)
+}
+
+func (r *readWriteRunner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.queryStatement(ctx, nil, query, args)
+}
+
+func (r *readWriteRunner) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return r.queryRowStatement(ctx, nil, query, args)
+}
+
+func (r *readWriteRunner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.ExecContext(context.Background(), query, args...)
+}
+
+func (r *readWriteRunner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+func (r *readWriteRunner) QueryRow(query string, args ...interface{}) RowScanner {
+	return r.QueryRowContext(context.Background(), query, args...)
+}
@@ -0,0 +1,165 @@
+package sqrl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWriteRunner(t *testing.T) {
+	primary, replica1, replica2 := &DBStub{}, &DBStub{}, &DBStub{}
+	db := NewReadWriteRunner(primary, []BaseRunnerContext{replica1, replica2})
+
+	Select("a").From("b").RunWith(db).Query()
+	assert.Equal(t, "SELECT a FROM b", replica1.LastQuerySql)
+
+	Select("c").From("d").RunWith(db).QueryRow()
+	assert.Equal(t, "SELECT c FROM d", replica2.LastQueryRowSql)
+
+	Select("e").From("f").RunWith(db).Query()
+	assert.Equal(t, "SELECT e FROM f", replica1.LastQuerySql)
+
+	Insert("a").Values(1).RunWith(db).Exec()
+	assert.Equal(t, "INSERT INTO a VALUES (?)", primary.LastExecSql)
+
+	Update("a").Set("b", 1).Returning("id").RunWith(db).QueryRow()
+	assert.Equal(t, "UPDATE a SET b = ? RETURNING id", primary.LastQueryRowSql)
+
+	db.Query("SELECT raw FROM b")
+	assert.Equal(t, "SELECT raw FROM b", primary.LastQuerySql)
+
+	assert.Empty(t, replica1.LastExecSql)
+	assert.Empty(t, replica2.LastExecSql)
+}
+
+func TestReadWriteRunnerPrimarySelects(t *testing.T) {
+	testCases := map[string]*SelectBuilder{
+		"for update":      Select("a").From("b").Suffix("FOR UPDATE"),
+		"for share":       Select("a").From("b").Suffix("FOR SHARE SKIP LOCKED"),
+		"lock in share":   Select("a").From("b").Suffix("LOCK IN SHARE MODE"),
+		"into":            Select("a").Suffix("INTO c"),
+		"writing cte":     Select("a").From("d").Prefix("WITH d AS (DELETE FROM b RETURNING a)"),
+		"subquery clause": Select("a").From("b").Where(Expr("a IN (SELECT a FROM c FOR UPDATE)")),
+	}
+
+	for name, q := range testCases {
+		t.Run(name, func(t *testing.T) {
+			primary, replica := &DBStub{}, &DBStub{}
+			db := NewReadWriteRunner(primary, []BaseRunnerContext{replica})
+
+			q.RunWith(db).Query()
+			assert.NotEmpty(t, primary.LastQuerySql)
+			assert.Empty(t, replica.LastQuerySql)
+		})
+	}
+
+	t.Run("string literal", func(t *testing.T) {
+		primary, replica := &DBStub{}, &DBStub{}
+		db := NewReadWriteRunner(primary, []BaseRunnerContext{replica})
+
+		Select("a").From("b").Where("c = 'FOR UPDATE'").RunWith(db).Query()
+		assert.Empty(t, primary.LastQuerySql)
+		assert.Equal(t, "SELECT a FROM b WHERE c = 'FOR UPDATE'", replica.LastQuerySql)
+	})
+
+	t.Run("exec", func(t *testing.T) {
+		primary, replica := &DBStub{}, &DBStub{}
+		db := NewReadWriteRunner(primary, []BaseRunnerContext{replica})
+
+		Select("pg_advisory_lock(1)").RunWith(db).Exec()
+		assert.Equal(t, "SELECT pg_advisory_lock(1)", primary.LastExecSql)
+	})
+}
+
+func TestReadWriteRunnerWithPrimary(t *testing.T) {
+	primary, replica := &DBStub{}, &DBStub{}
+	db := NewReadWriteRunner(primary, []BaseRunnerContext{replica})
+
+	ctx := WithPrimary(context.Background())
+	Select("a").From("b").RunWith(db).QueryContext(ctx)
+	assert.Equal(t, "SELECT a FROM b", primary.LastQuerySql)
+	assert.Empty(t, replica.LastQuerySql)
+}
+
+func TestReadWriteRunnerSelector(t *testing.T) {
+	primary, replica1, replica2 := &DBStub{}, &DBStub{}, &DBStub{}
+	healthy := true
+	db := NewReadWriteRunner(primary, []BaseRunnerContext{replica1, replica2},
+		ReadWriteReplicaSelector(func(ctx context.Context, n int) int {
+			assert.Equal(t, 2, n)
+			if !healthy {
+				return -1
+			}
+			return 1
+		}))
+
+	Select("a").From("b").RunWith(db).Query()
+	assert.Equal(t, "SELECT a FROM b", replica2.LastQuerySql)
+
+	healthy = false
+	Select("c").From("d").RunWith(db).Query()
+	assert.Equal(t, "SELECT c FROM d", primary.LastQuerySql)
+	assert.Empty(t, replica1.LastQuerySql)
+}
+
+func TestReadWriteRunnerNoReplicas(t *testing.T) {
+	primary := &DBStub{}
+	db := NewReadWriteRunner(primary, nil)
+
+	Select("a").From("b").RunWith(db).Query()
+	assert.Equal(t, "SELECT a FROM b", primary.LastQuerySql)
+}
+
+func TestReadWriteRunnerInTx(t *testing.T) {
+	primary, fake := newFakeDB()
+	replica := &DBStub{}
+	db := NewReadWriteRunner(primary, []BaseRunnerContext{replica})
+
+	err := InTx(context.Background(), db, nil, func(tx Runner) error {
+		rows, err := Select("a").From("b").RunWith(tx).Query()
+		if err != nil {
+			return err
+		}
+		return rows.Close()
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.Count("begin"))
+	assert.Equal(t, 1, fake.Count("query SELECT a FROM b"))
+	assert.Equal(t, 1, fake.Count("commit"))
+	assert.Empty(t, replica.LastQuerySql)
+}
+
+func TestReadWriteRunnerTxPrimary(t *testing.T) {
+	primary, fake := newFakeDB()
+	replica := &DBStub{}
+
+	tx, err := primary.Begin()
+	assert.NoError(t, err)
+	db := NewReadWriteRunner(tx, []BaseRunnerContext{replica})
+
+	rows, err := Select("a").From("b").RunWith(db).Query()
+	assert.NoError(t, err)
+	rows.Close()
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, 1, fake.Count("query SELECT a FROM b"))
+	assert.Empty(t, replica.LastQuerySql)
+}
+
+func TestReadWriteRunnerWrapped(t *testing.T) {
+	primary, replica := &DBStub{}, &DBStub{}
+	var seen []Sqlizer
+	db := WrapRunner(NewReadWriteRunner(primary, []BaseRunnerContext{replica}), func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			seen = append(seen, stmt.Sqlizer)
+			return next(ctx, stmt)
+		}
+	})
+
+	q := Select("a").From("b")
+	q.RunWith(db).Query()
+	assert.Equal(t, "SELECT a FROM b", replica.LastQuerySql)
+	assert.Empty(t, primary.LastQuerySql)
+	assert.Len(t, seen, 1)
+}
@@ -1,6 +1,6 @@
 package sqrl
 
-import "io"
+import "bytes"
 
 type returning []Sqlizer
 
@@ -16,8 +16,12 @@ func (r *returning) ReturningSelect(from *SelectBuilder, alias string) {
 	*r = append(*r, Alias(from, alias))
 }
 
-func (r *returning) AppendToSql(w io.Writer, args []interface{}) ([]interface{}, error) {
-	io.WriteString(w, " RETURNING ")
-	return appendToSql(*r, w, ", ", args)
+func (r returning) clone() returning {
+	return returning(cloneSqlizers(r))
+}
+
+func (r *returning) AppendToSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	buf.WriteString(" RETURNING ")
+	return appendToSql(*r, buf, ", ", args)
 
 }
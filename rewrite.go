@@ -0,0 +1,477 @@
+package sqrl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrCannotRewrite is wrapped by the errors of statements that can't be
+// rewritten because some of their tables are unknown, see
+// StatementBuilderType.Rewrite.
+var ErrCannotRewrite = fmt.Errorf("cannot rewrite statement")
+
+// Rewriter rewrites a statement before it is rendered, see
+// StatementBuilderType.Rewrite. An error fails the statement.
+type Rewriter func(stmt *RewriteStatement) error
+
+// TableRef is a table referenced by a statement.
+type TableRef struct {
+	// Table is the name of the table without quotes, as in
+	// TableDependencies, e.g. "public.Orders" for public."Orders".
+	Table string
+
+	// Name is the name of the table without schema, in lower case, e.g.
+	// "orders" for public."Orders". Compare tables by Name, so that
+	// qualified references aren't missed.
+	Name string
+
+	// Alias is the alias of the table in the statement, "" if none.
+	Alias string
+
+	// Outer is true if the table is on the nullable side of an outer join,
+	// e.g. b of a LEFT JOIN b. WHERE predicates on its columns turn the
+	// join into an inner join.
+	Outer bool
+
+	// sql is the alias of the table, or its name if it has none, as spelled
+	// in the statement.
+	sql string
+}
+
+// Column returns column qualified with the alias of the table, or with its
+// name if it has no alias, quoted as in the statement.
+func (t TableRef) Column(column string) string {
+	switch {
+	case t.sql != "":
+		return t.sql + "." + column
+	case t.Alias != "":
+		return t.Alias + "." + column
+	}
+	return t.Table + "." + column
+}
+
+// RewriteStatement is a statement being rewritten: the statement that is
+// rendered or one of its subqueries.
+type RewriteStatement struct {
+	builder Sqlizer
+	kind    string
+	tables  []TableRef
+	nested  bool
+
+	// grouped is true once the existing WHERE predicates are grouped, so that
+	// added predicates apply to all of them.
+	grouped bool
+}
+
+// Kind returns the kind of the statement: "SELECT", "INSERT", "UPDATE" or
+// "DELETE".
+func (s *RewriteStatement) Kind() string {
+	return s.kind
+}
+
+// Tables returns the tables of the FROM, JOIN and USING clauses of the
+// statement, the table inserted into or the table updated. Tables of
+// subqueries are not included, subqueries are rewritten on their own.
+func (s *RewriteStatement) Tables() []TableRef {
+	return s.tables
+}
+
+// Nested reports whether the statement is a subquery of the statement that
+// is rendered.
+func (s *RewriteStatement) Nested() bool {
+	return s.nested
+}
+
+// Builder returns the builder of the statement, e.g. to look at its Clauses.
+// It is a copy private to the rendering; use Where and Column to modify it.
+func (s *RewriteStatement) Builder() Sqlizer {
+	return s.builder
+}
+
+// Where adds a WHERE predicate to a SELECT, UPDATE or DELETE statement, like
+// the Where method of its builder.
+//
+// The predicates of the statement are grouped in parentheses before the
+// first predicate is added, so that an OR among them can't bypass it.
+func (s *RewriteStatement) Where(pred interface{}, args ...interface{}) error {
+	var where *[]Sqlizer
+	switch b := s.builder.(type) {
+	case *SelectBuilder:
+		where = &b.whereParts
+	case *UpdateBuilder:
+		where = &b.whereParts
+	case *DeleteBuilder:
+		where = &b.whereParts
+	default:
+		return fmt.Errorf("cannot add WHERE predicates to %s statements", s.kind)
+	}
+
+	if !s.grouped && len(*where) > 0 {
+		*where = []Sqlizer{And(*where)}
+	}
+	s.grouped = true
+	*where = append(*where, NewWherePart(pred, args...))
+	return nil
+}
+
+// Column sets column to value in every row of an INSERT statement. The
+// column is added unless the statement has it already, in which case its
+// values are replaced. Statements inserting the rows of a select get value
+// as an additional column of the select.
+func (s *RewriteStatement) Column(column string, value interface{}) error {
+	b, ok := s.builder.(*InsertBuilder)
+	if !ok {
+		return fmt.Errorf("cannot add columns to %s statements", s.kind)
+	}
+	if len(b.columns) == 0 {
+		return fmt.Errorf("%w: INSERT INTO %s has no column list", ErrCannotRewrite, b.into)
+	}
+
+	for i, c := range b.columns {
+		if !strings.EqualFold(c, column) {
+			continue
+		}
+		if b.iselect != nil {
+			return fmt.Errorf("%w: column %s of INSERT INTO %s is selected", ErrCannotRewrite, column, b.into)
+		}
+		for _, row := range b.values {
+			if i < len(row) {
+				row[i] = value
+			}
+		}
+		return nil
+	}
+
+	b.columns = append(b.columns, column)
+	for i, row := range b.values {
+		b.values[i] = append(row, value)
+	}
+	if b.iselect != nil {
+		b.iselect.columns = append(b.iselect.columns, newPart("?", value))
+	}
+	return nil
+}
+
+// Rewrite adds rewriters that child builders run before they are rendered,
+// e.g. to add a tenant predicate to every statement reading a tenant-scoped
+// table:
+//
+//	tenants := StatementBuilder.Rewrite(func(stmt *RewriteStatement) error {
+//		for _, t := range stmt.Tables() {
+//			if scoped[t.Name] && stmt.Kind() != "INSERT" {
+//				stmt.Where(Eq{t.Column("tenant_id"): tenantID})
+//			}
+//		}
+//		return nil
+//	})
+//
+// The rewriters are called with the rendered statement and each of its
+// subqueries, in the order they appear. They rewrite a copy of the
+// statement, the builders are left intact. Predicates and values added by
+// rewriters are not rewritten themselves. Rewriters of subqueries are
+// ignored: the rewriters of the statement that is rendered apply to all of
+// it.
+//
+// Rewriting fails closed. Statements with tables that rewriters can't see,
+// such as subqueries and CTEs in SQL strings, tables joined in parentheses or
+// listed after a join condition, or table clauses given as custom Sqlizers,
+// fail to render with an error wrapping ErrCannotRewrite.
+func (b StatementBuilderType) Rewrite(rewriters ...Rewriter) StatementBuilderType {
+	for _, r := range rewriters {
+		b = b.addRewriter(statementRewriter{rewrite: r})
+	}
+	return b
+}
+
+// statementRewriter is a rewriter of a StatementBuilderType.
+type statementRewriter struct {
+	rewrite Rewriter
+
+	// table is the table rewritten by a scope, as in TableRef.Name: the
+	// statement only fails if the table may be hidden. Rewriters of any
+	// table have none.
+	table string
+}
+
+func (b StatementBuilderType) addRewriter(r statementRewriter) StatementBuilderType {
+	b.rewriters = append(b.rewriters[:len(b.rewriters):len(b.rewriters)], r)
+	return b
+}
+
+// rewrite returns a copy of the statement s rewritten by the rewriters of
+// sb. Soft deletes are turned into updates first.
+func rewrite(s Sqlizer, sb StatementBuilderType) (Sqlizer, error) {
+	s = cloneSqlizer(s)
+	if d, ok := s.(*DeleteBuilder); ok {
+		u, err := softDeleteUpdate(d)
+		if err != nil {
+			return nil, err
+		}
+		if u != nil {
+			s = u
+		}
+	}
+
+	var stmts []*RewriteStatement
+	var hidden []string
+	var err error
+	Walk(s, func(n Sqlizer) bool {
+		if err != nil {
+			return false
+		}
+		var stmt *RewriteStatement
+		var h []string
+		stmt, h, err = rewriteStatement(n)
+		if stmt != nil {
+			stmt.nested = n != s
+			stmts = append(stmts, stmt)
+		}
+		hidden = append(hidden, h...)
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHiddenTables(sb.rewriters, hidden); err != nil {
+		return nil, err
+	}
+
+	for _, stmt := range stmts {
+		for _, r := range sb.rewriters {
+			if err := r.rewrite(stmt); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// checkHiddenTables returns an error if tables of the hidden SQL may have to
+// be rewritten: any table for rewriters of any table, and their table for
+// scopes.
+func checkHiddenTables(rewriters []statementRewriter, hidden []string) error {
+	for _, sql := range hidden {
+		var tables map[string]bool
+		for _, r := range rewriters {
+			if r.table == "" {
+				return fmt.Errorf("%w: hidden tables in SQL %q", ErrCannotRewrite, sql)
+			}
+			if tables == nil {
+				tables = make(map[string]bool)
+				addSqlTableNames(tables, sql)
+			}
+			if tables[r.table] {
+				return fmt.Errorf("%w: table %s in SQL %q", ErrCannotRewrite, r.table, sql)
+			}
+		}
+	}
+	return nil
+}
+
+// addSqlTableNames adds the names of the tables referenced by sql, including
+// its executable comments, to tables.
+func addSqlTableNames(tables map[string]bool, sql string) {
+	for _, ref := range sqlTableRefs(sql) {
+		tables[ref.Name] = true
+	}
+	for _, tok := range scanSql(sql) {
+		if tok.kind != sqlComment {
+			continue
+		}
+		if body := executableComment(tok.text); body != "" {
+			addSqlTableNames(tables, body)
+		}
+	}
+}
+
+// rewriteStatement returns the statement of s if it is a builder, and the
+// SQL of s that hides tables from rewriters.
+func rewriteStatement(s Sqlizer) (*RewriteStatement, []string, error) {
+	stmt := &RewriteStatement{builder: s}
+	// tables is SQL with the table clauses of the statement.
+	var tables string
+	// sqls are SQL strings of the statement that are not visited by Walk.
+	var sqls []string
+	// hidden is SQL of table clauses given as custom Sqlizers.
+	var hidden []string
+
+	switch s := s.(type) {
+	case *SelectBuilder:
+		stmt.kind = "SELECT"
+		from, err := tableClauseSql(s.fromParts, ", ", &hidden)
+		if err != nil {
+			return nil, nil, err
+		}
+		joins, err := tableClauseSql(s.joins, " ", &hidden)
+		if err != nil {
+			return nil, nil, err
+		}
+		if from != "" {
+			tables = "FROM " + from
+		}
+		tables += " " + joins
+		sqls = append(sqls, s.options...)
+		sqls = append(sqls, s.groupBys...)
+		sqls = append(sqls, s.orderBys...)
+	case *InsertBuilder:
+		stmt.kind = "INSERT"
+		tables = "INSERT INTO " + s.into
+		sqls = append(sqls, s.into)
+		sqls = append(sqls, s.options...)
+		sqls = append(sqls, s.columns...)
+	case *UpdateBuilder:
+		stmt.kind = "UPDATE"
+		from, err := tableClauseSql(s.fromParts, ", ", &hidden)
+		if err != nil {
+			return nil, nil, err
+		}
+		tables = "UPDATE " + s.table
+		if from != "" {
+			tables += " FROM " + from
+		}
+		sqls = append(sqls, s.table)
+		for _, set := range s.setClauses {
+			sqls = append(sqls, set.column)
+		}
+		sqls = append(sqls, s.orderBys...)
+	case *DeleteBuilder:
+		stmt.kind = "DELETE"
+		using, err := tableClauseSql(s.usingParts, ", ", &hidden)
+		if err != nil {
+			return nil, nil, err
+		}
+		tables = "DELETE FROM " + s.from + " " + strings.Join(s.joins, " ")
+		if using != "" {
+			tables += " USING " + using
+		}
+		sqls = append(sqls, s.from)
+		sqls = append(sqls, s.what...)
+		sqls = append(sqls, s.joins...)
+		sqls = append(sqls, s.orderBys...)
+	case expr:
+		return nil, subquerySql(s.sql), nil
+	case Eq:
+		return nil, subqueryKeys(s), nil
+	case NotEq:
+		return nil, subqueryKeys(s), nil
+	case Lt:
+		return nil, subqueryKeys(s), nil
+	case LtOrEq:
+		return nil, subqueryKeys(s), nil
+	case Gt:
+		return nil, subqueryKeys(s), nil
+	case GtOrEq:
+		return nil, subqueryKeys(s), nil
+	case *CaseBuilder, aliasExpr, And, Or, *part, *WherePart:
+		// Their Sqlizers are visited by Walk.
+		return nil, nil, nil
+	default:
+		// Sqlizers unknown to Walk may render subqueries.
+		sql, _, err := s.ToSql()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, subquerySql(sql), nil
+	}
+
+	a := newTableAnalyzer(tables)
+	a.analyze()
+	stmt.tables = a.refs
+	if a.nestedJoins || a.unknown {
+		// The tables are found, but rewriters can't tell how they are
+		// joined, or some are missing.
+		hidden = append(hidden, tables)
+	}
+	return stmt, append(hidden, subquerySql(sqls...)...), nil
+}
+
+// tableClauseSql returns the SQL of the parts of a table clause separated by
+// sep, with placeholders for their args. Subqueries of FromSelect and
+// UsingSelect are left out, they are statements of their own. The SQL of
+// custom Sqlizers is added to hidden.
+func tableClauseSql(parts []Sqlizer, sep string, hidden *[]string) (string, error) {
+	var sqls []string
+	for _, p := range parts {
+		switch p := inspectSqlizer(p).(type) {
+		case expr:
+			sqls = append(sqls, p.sql)
+		case aliasExpr:
+		default:
+			sql, _, err := p.ToSql()
+			if err != nil {
+				return "", err
+			}
+			*hidden = append(*hidden, "FROM "+sql)
+		}
+	}
+	return strings.Join(sqls, sep), nil
+}
+
+// subquerySql returns the sqls that have a subquery, whose tables rewriters
+// can't see.
+func subquerySql(sqls ...string) []string {
+	var hidden []string
+	for _, sql := range sqls {
+		if hasSubquery(sql) {
+			hidden = append(hidden, sql)
+		}
+	}
+	return hidden
+}
+
+// subqueryKeys returns the column expressions of an Eq or a Lt that have a
+// subquery.
+func subqueryKeys(m map[string]interface{}) []string {
+	var hidden []string
+	for key := range m {
+		hidden = append(hidden, subquerySql(key)...)
+	}
+	return hidden
+}
+
+// hasSubquery reports whether sql has the keyword of a statement. MySQL's
+// executable comments and optimizer hints are code, e.g. /*! SELECT */ and
+// /*+ ... */.
+func hasSubquery(sql string) bool {
+	prev := ""
+	for _, tok := range scanSql(sql) {
+		if tok.kind == sqlComment {
+			if body := executableComment(tok.text); body != "" && hasSubquery(body) {
+				return true
+			}
+			continue
+		}
+		word := ""
+		if tok.kind == sqlWord {
+			word = strings.ToUpper(tok.text)
+		}
+
+		switch word {
+		case "SELECT", "INSERT", "MERGE", "TABLE":
+			return true
+		case "UPDATE":
+			// Unless FOR UPDATE, ON UPDATE, ON CONFLICT DO UPDATE and ON
+			// DUPLICATE KEY UPDATE.
+			if prev != "DO" && prev != "FOR" && prev != "KEY" && prev != "ON" {
+				return true
+			}
+		case "DELETE":
+			if prev != "ON" {
+				return true
+			}
+		}
+		prev = word
+	}
+	return false
+}
+
+// executableComment returns the body of comment if MySQL or MariaDB execute
+// it, or "" otherwise.
+func executableComment(comment string) string {
+	for _, prefix := range []string{"/*!", "/*M!", "/*+"} {
+		if strings.HasPrefix(comment, prefix) {
+			return strings.TrimSuffix(comment[len(prefix):], "*/")
+		}
+	}
+	return ""
+}
@@ -0,0 +1,183 @@
+package sqrl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tenantScoped adds tenant predicates and columns for the tables users and
+// orders.
+func tenantScoped(tenantID int) Rewriter {
+	return func(stmt *RewriteStatement) error {
+		for _, t := range stmt.Tables() {
+			if t.Name != "users" && t.Name != "orders" {
+				continue
+			}
+			if stmt.Kind() == "INSERT" {
+				return stmt.Column("tenant_id", tenantID)
+			}
+			if err := stmt.Where(Eq{t.Column("tenant_id"): tenantID}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	sb := StatementBuilder.Rewrite(tenantScoped(7))
+
+	testCases := map[string]struct {
+		s    Sqlizer
+		sql  string
+		args []interface{}
+	}{
+		"select": {
+			s:    sb.Select("*").From("users"),
+			sql:  "SELECT * FROM users WHERE users.tenant_id = ?",
+			args: []interface{}{7},
+		},
+		"join": {
+			s: sb.Select("u.name", "o.total").From("users u").Join("orders o ON o.user_id = u.id").
+				Join("products p ON p.id = o.product_id").Where("o.total > ?", 10),
+			sql:  "SELECT u.name, o.total FROM users u JOIN orders o ON o.user_id = u.id JOIN products p ON p.id = o.product_id WHERE (o.total > ?) AND u.tenant_id = ? AND o.tenant_id = ?",
+			args: []interface{}{10, 7, 7},
+		},
+		"qualified": {
+			s:    sb.Select("*").From("public.Orders"),
+			sql:  "SELECT * FROM public.Orders WHERE public.Orders.tenant_id = ?",
+			args: []interface{}{7},
+		},
+		"quoted": {
+			s:    sb.Select("*").From(`public."Orders"`).Join(`users AS "U" ON "U".id = user_id`),
+			sql:  `SELECT * FROM public."Orders" JOIN users AS "U" ON "U".id = user_id WHERE public."Orders".tenant_id = ? AND "U".tenant_id = ?`,
+			args: []interface{}{7, 7},
+		},
+		"or is grouped": {
+			s:    sb.Select("*").From("users").Where("admin OR id = ?", 1).Where(Eq{"active": true}),
+			sql:  "SELECT * FROM users WHERE (admin OR id = ? AND active = ?) AND users.tenant_id = ?",
+			args: []interface{}{1, true, 7},
+		},
+		"subqueries": {
+			s: sb.Select("name").FromSelect(Select("*").From("users"), "u").
+				Where(Expr("u.id IN (?)", Select("user_id").From("orders").Where("total > ?", 10))),
+			sql:  "SELECT name FROM (SELECT * FROM users WHERE users.tenant_id = ?) AS u WHERE u.id IN (SELECT user_id FROM orders WHERE (total > ?) AND orders.tenant_id = ?)",
+			args: []interface{}{7, 10, 7},
+		},
+		"unscoped tables": {
+			s:    sb.Select("*").From("products").Suffix("FOR UPDATE"),
+			sql:  "SELECT * FROM products FOR UPDATE",
+			args: nil,
+		},
+		"comment": {
+			s:    sb.Select("*").From("products").Where("id > 0 /* SELECT */"),
+			sql:  "SELECT * FROM products WHERE id > 0 /* SELECT */",
+			args: nil,
+		},
+		"update": {
+			s:    sb.Update("orders").Set("total", 0).From("users u").Where("u.id = orders.user_id"),
+			sql:  "UPDATE orders SET total = ? FROM users u WHERE (u.id = orders.user_id) AND orders.tenant_id = ? AND u.tenant_id = ?",
+			args: []interface{}{0, 7, 7},
+		},
+		"delete": {
+			s:    sb.Delete("orders").Using("users u").Where(Eq{"u.name": "moe"}),
+			sql:  "DELETE FROM orders USING users u WHERE (u.name = ?) AND orders.tenant_id = ? AND u.tenant_id = ?",
+			args: []interface{}{"moe", 7, 7},
+		},
+		"insert": {
+			s:    sb.Insert("orders").Columns("user_id", "total").Values(1, 10).Values(2, 20),
+			sql:  "INSERT INTO orders (user_id,total,tenant_id) VALUES (?,?,?),(?,?,?)",
+			args: []interface{}{1, 10, 7, 2, 20, 7},
+		},
+		"insert replaces column": {
+			s:    sb.Insert("orders").Columns("tenant_id", "total").Values(8, 10).Suffix("ON CONFLICT (id) DO UPDATE SET total = excluded.total"),
+			sql:  "INSERT INTO orders (tenant_id,total) VALUES (?,?) ON CONFLICT (id) DO UPDATE SET total = excluded.total",
+			args: []interface{}{7, 10},
+		},
+		"insert select": {
+			s:    sb.Insert("orders").Columns("user_id").Select(Select("id").From("users")),
+			sql:  "INSERT INTO orders (user_id,tenant_id) SELECT id, ? FROM users WHERE users.tenant_id = ?",
+			args: []interface{}{7, 7},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sql, args, err := tc.s.ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.sql, sql)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestRewriteFailsClosed(t *testing.T) {
+	sb := StatementBuilder.Rewrite(tenantScoped(7))
+
+	testCases := map[string]Sqlizer{
+		"where subquery":     sb.Select("*").From("products").Where("id IN (SELECT product_id FROM orders)"),
+		"from subquery":      sb.Select("*").From("(SELECT * FROM users) u"),
+		"join subquery":      sb.Select("*").From("products p").Join("(SELECT * FROM orders) o ON o.product_id = p.id"),
+		"cte":                sb.Select("*").From("x").Prefix("WITH x AS (SELECT * FROM users)"),
+		"column subquery":    sb.Select("(SELECT count(*) FROM orders) AS n"),
+		"eq column":          sb.Update("products").Set("price", 1).Where(Eq{"(SELECT max(id) FROM orders)": 1}),
+		"custom sqlizer":     sb.Delete("products").Where(Or{sqlizerFunc("id IN (SELECT product_id FROM orders)")}),
+		"insert columns":     sb.Insert("orders").Values(1, 2),
+		"mysql comment":      sb.Select("*").From("products").Where("id IN (/*!50100 SELECT product_id FROM orders */)"),
+		"hint":               sb.Select("*").From("products").Options("/*+ SET_VAR(x = (SELECT 1 FROM orders)) */"),
+		"parenthesized from": sb.Select("*").From("(users u JOIN b ON true)"),
+		"parenthesized list": sb.Select("*").From("a, (users)"),
+		"parenthesized join": sb.Select("*").From("a").Join("(users u JOIN b ON true) ON true"),
+		"list after join":    sb.Select("*").From("a").Join("b ON a.id = b.id, c"),
+		"list after in":      sb.Select("*").From("a").LeftJoin("b ON a.x IN (1,2), users"),
+		"list after parens":  sb.Select("*").From("a").Join("b ON (a.id = b.id), users"),
+		"unknown table":      sb.Select("*").From("a").Join("? ON true", "users"),
+	}
+
+	for name, s := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := s.ToSql()
+			assert.True(t, errors.Is(err, ErrCannotRewrite), "%v", err)
+		})
+	}
+}
+
+type sqlizerFunc string
+
+func (s sqlizerFunc) ToSql() (string, []interface{}, error) {
+	return string(s), nil, nil
+}
+
+func TestRewriteStatement(t *testing.T) {
+	var stmts []string
+	sb := StatementBuilder.Rewrite(func(stmt *RewriteStatement) error {
+		for _, t := range stmt.Tables() {
+			stmts = append(stmts, stmt.Kind()+" "+t.Table+" "+t.Alias)
+		}
+		if stmt.Nested() {
+			return stmt.Column("a", 1)
+		}
+		return nil
+	})
+
+	b := sb.Update(`"Users" AS u`).Set("a", Select("max(a)").From("t")).Where(Eq{"id": 1})
+	_, _, err := b.ToSql()
+	assert.EqualError(t, err, "cannot add columns to SELECT statements")
+	assert.Equal(t, []string{"UPDATE Users u", "SELECT t "}, stmts)
+
+	rewritten := errors.New("rewritten")
+	b = StatementBuilder.Rewrite(func(stmt *RewriteStatement) error {
+		stmt.Where("b")
+		return nil
+	}).Rewrite(func(stmt *RewriteStatement) error {
+		assert.Equal(t, []Sqlizer{And{NewWherePart(Eq{"id": 1})}, NewWherePart("b")}, stmt.Builder().(*UpdateBuilder).whereParts)
+		return rewritten
+	}).Update("a").Set("a", 1).Where(Eq{"id": 1})
+	_, _, err = b.ToSql()
+	assert.Equal(t, rewritten, err)
+
+	// The builder is left intact.
+	assert.Len(t, b.whereParts, 1)
+}
@@ -1,5 +1,7 @@
 package sqrl
 
+import "database/sql"
+
 // RowScanner is the interface that wraps the Scan method.
 //
 // Scan behaves like database/sql.Row.Scan.
@@ -20,3 +22,23 @@ func (r *Row) Scan(dest ...interface{}) error {
 	}
 	return r.RowScanner.Scan(dest...)
 }
+
+// rowsScanner scans the first row of rows like database/sql.Row does.
+type rowsScanner struct {
+	rows *sql.Rows
+}
+
+func (r *rowsScanner) Scan(dest ...interface{}) error {
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	if err := r.rows.Scan(dest...); err != nil {
+		return err
+	}
+	return r.rows.Close()
+}
@@ -0,0 +1,229 @@
+package sqrl
+
+import "strings"
+
+// sqlTokenKind is the kind of a token of SQL.
+type sqlTokenKind int
+
+const (
+	// sqlWord is a keyword or an unquoted identifier.
+	sqlWord sqlTokenKind = iota
+	// sqlQuoted is a quoted identifier, e.g. "a" or `a`.
+	sqlQuoted
+	// sqlString is a string literal, including prefixed strings such as E''
+	// and X'' and dollar-quoted strings.
+	sqlString
+	sqlNumber
+	// sqlPlaceholder is a "?" or "$1" placeholder.
+	sqlPlaceholder
+	sqlOperator
+	// sqlPunct is a parenthesis, bracket, comma, semicolon or dot.
+	sqlPunct
+	// sqlComment is a "--" comment without its newline or a block comment.
+	sqlComment
+)
+
+// sqlToken is a token of SQL. space is set if it was preceded by whitespace.
+type sqlToken struct {
+	kind  sqlTokenKind
+	text  string
+	space bool
+}
+
+// isOperand reports whether the token ends an operand, so that a following
+// minus sign is a binary operator.
+func (t sqlToken) isOperand() bool {
+	switch t.kind {
+	case sqlWord, sqlQuoted, sqlString, sqlNumber, sqlPlaceholder:
+		return true
+	}
+	return t.text == ")" || t.text == "]"
+}
+
+const operatorChars = "+-*/<>=~!@#%^&|:"
+
+// scanSql splits sql into tokens. Concatenating the tokens, with a space
+// where space is set, gives sql back with whitespace collapsed.
+func scanSql(sql string) []sqlToken {
+	var tokens []sqlToken
+	space := false
+	emit := func(kind sqlTokenKind, text string) {
+		tokens = append(tokens, sqlToken{kind, text, space})
+		space = false
+	}
+
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		end := i + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v':
+			space = true
+			i++
+			continue
+
+		case strings.HasPrefix(sql[i:], "--"):
+			end = strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				end = len(sql)
+			} else {
+				end += i
+			}
+			emit(sqlComment, sql[i:end])
+
+		case strings.HasPrefix(sql[i:], "/*"):
+			end = skipBlockComment(sql, i)
+			emit(sqlComment, sql[i:end])
+
+		case c == '\'':
+			end = skipQuoted(sql, i, '\'', false)
+			emit(sqlString, sql[i:end])
+
+		case c == '"' || c == '`':
+			end = skipQuoted(sql, i, c, false)
+			emit(sqlQuoted, sql[i:end])
+
+		case c == '?':
+			emit(sqlPlaceholder, "?")
+
+		case c == '$':
+			for end < len(sql) && isDigit(sql[end]) {
+				end++
+			}
+			switch {
+			case end > i+1:
+				emit(sqlPlaceholder, sql[i:end])
+			case skipDollarQuoted(sql, i) > i:
+				end = skipDollarQuoted(sql, i)
+				emit(sqlString, sql[i:end])
+			default:
+				emit(sqlOperator, "$")
+			}
+
+		case isDigit(c) || c == '.' && i+1 < len(sql) && isDigit(sql[i+1]):
+			end = skipNumber(sql, i)
+			emit(sqlNumber, sql[i:end])
+
+		case isWordByte(c) || c >= 0x80:
+			for end < len(sql) && (isWordByte(sql[end]) || sql[end] >= 0x80) {
+				end++
+			}
+			if end < len(sql) && sql[end] == '\'' && isLiteralPrefix(sql[i:end]) {
+				escapes := strings.EqualFold(sql[i:end], "E")
+				end = skipQuoted(sql, end, '\'', escapes)
+				emit(sqlString, sql[i:end])
+				break
+			}
+			emit(sqlWord, sql[i:end])
+
+		case strings.IndexByte(operatorChars, c) >= 0:
+			for end < len(sql) && strings.IndexByte(operatorChars, sql[end]) >= 0 &&
+				!strings.HasPrefix(sql[end:], "--") && !strings.HasPrefix(sql[end:], "/*") {
+				end++
+			}
+			emit(sqlOperator, sql[i:end])
+
+		default:
+			emit(sqlPunct, sql[i:end])
+		}
+		i = end
+	}
+	return tokens
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// isLiteralPrefix reports whether prefix followed by a quote starts a string
+// literal, e.g. E'\n', X'00', B'1', N'x' or MySQL's _utf8mb4'x'.
+func isLiteralPrefix(prefix string) bool {
+	switch strings.ToUpper(prefix) {
+	case "E", "X", "B", "N", "_UTF8", "_UTF8MB4", "_BINARY":
+		return true
+	}
+	return false
+}
+
+// skipQuoted returns the index after the quoted string starting at sql[i].
+// Doubled quotes are part of the string, and so are characters escaped with
+// a backslash if escapes.
+func skipQuoted(sql string, i int, quote byte, escapes bool) int {
+	for j := i + 1; j < len(sql); j++ {
+		switch {
+		case escapes && sql[j] == '\\':
+			j++
+		case sql[j] != quote:
+		case j+1 < len(sql) && sql[j+1] == quote:
+			j++
+		default:
+			return j + 1
+		}
+	}
+	return len(sql)
+}
+
+// skipBlockComment returns the index after the possibly nested block comment
+// starting at sql[i].
+func skipBlockComment(sql string, i int) int {
+	depth := 0
+	for j := i; j+1 < len(sql); j++ {
+		switch sql[j : j+2] {
+		case "/*":
+			depth++
+			j++
+		case "*/":
+			depth--
+			j++
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return len(sql)
+}
+
+// skipDollarQuoted returns the index after the dollar-quoted string starting
+// at sql[i], e.g. $$x$$ or $tag$x$tag$, or i if there is none.
+func skipDollarQuoted(sql string, i int) int {
+	end := i + 1
+	for end < len(sql) && (isWordByte(sql[end]) && sql[end] != '$' || sql[end] >= 0x80) {
+		end++
+	}
+	if end == len(sql) || sql[end] != '$' {
+		return i
+	}
+	tag := sql[i : end+1]
+	close := strings.Index(sql[end+1:], tag)
+	if close == -1 {
+		return len(sql)
+	}
+	return end + 1 + close + len(tag)
+}
+
+// skipNumber returns the index after the number starting at sql[i].
+func skipNumber(sql string, i int) int {
+	if strings.HasPrefix(sql[i:], "0x") || strings.HasPrefix(sql[i:], "0X") {
+		i += 2
+		for i < len(sql) && strings.IndexByte("0123456789abcdefABCDEF", sql[i]) >= 0 {
+			i++
+		}
+		return i
+	}
+
+	for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+		i++
+	}
+	if i < len(sql) && (sql[i] == 'e' || sql[i] == 'E') {
+		j := i + 1
+		if j < len(sql) && (sql[j] == '+' || sql[j] == '-') {
+			j++
+		}
+		if j < len(sql) && isDigit(sql[j]) {
+			i = j
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+		}
+	}
+	return i
+}
@@ -0,0 +1,165 @@
+package sqrl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TableScope returns the predicate that statements get for a table
+// referenced as t, see StatementBuilderType.Scope. Columns of the predicate
+// should be qualified with t.Column, since the statement may join other
+// tables having the same columns.
+type TableScope func(t TableRef) Sqlizer
+
+// softDelete is a table whose rows are soft deleted by setting column.
+type softDelete struct {
+	table  string
+	column string
+}
+
+// Scope adds a default scope to table: SELECT, UPDATE and DELETE statements
+// of child builders, including their subqueries, get the predicate returned
+// by scope for every reference to table.
+//
+//	active := StatementBuilder.Scope("users", func(t TableRef) Sqlizer {
+//		return Eq{t.Column("active"): true}
+//	})
+//
+// Tables are matched by their name without schema, as in TableRef.Name:
+// the scope of "users" applies to public.users too. Unscoped disables the
+// scopes of a query.
+//
+// Scopes are rewriters, see Rewrite, but they only fail closed for
+// statements that may hide table, e.g. in a subquery given as SQL. The
+// predicates are added to the WHERE clause, so statements where table is on
+// the nullable side of an outer join, as in LEFT JOIN table, fail to render
+// with an error wrapping ErrCannotRewrite.
+func (b StatementBuilderType) Scope(table string, scope TableScope) StatementBuilderType {
+	return b.addRewriter(statementRewriter{
+		rewrite: scopeRewriter(table, scope, false),
+		table:   tableName(table),
+	})
+}
+
+// SoftDelete makes child builders soft delete the rows of table by setting
+// column to the current time, e.g. deleted_at.
+//
+// Deletes from table are rendered as UPDATE table SET column =
+// CURRENT_TIMESTAMP, and table is scoped to the rows that are not deleted,
+// i.e. where column is NULL. WithDeleted disables the scope of a query so
+// that it sees deleted rows too, e.g. to restore them. Unscoped disables it
+// too and makes deletes delete rows.
+func (b StatementBuilderType) SoftDelete(table, column string) StatementBuilderType {
+	b.softDeletes = append(b.softDeletes[:len(b.softDeletes):len(b.softDeletes)], softDelete{table: table, column: column})
+	return b.addRewriter(statementRewriter{
+		rewrite: scopeRewriter(table, func(t TableRef) Sqlizer {
+			return Eq{t.Column(column): nil}
+		}, true),
+		table: tableName(table),
+	})
+}
+
+// scopeRewriter returns a Rewriter adding scope to the statements
+// referencing table. The scopes of the soft deletes of table are disabled by
+// WithDeleted too.
+func scopeRewriter(table string, scope TableScope, deleted bool) Rewriter {
+	table = tableName(table)
+	return func(stmt *RewriteStatement) error {
+		unscoped, withDeleted := scopeFlags(stmt.builder)
+		if stmt.Kind() == "INSERT" || unscoped || deleted && withDeleted {
+			return nil
+		}
+		if d, ok := stmt.builder.(*DeleteBuilder); ok && deleted && deleteTable(d) == table {
+			// Deletes that are rendered are turned into updates before
+			// they are rewritten.
+			return fmt.Errorf("%w: soft delete from %s in a subquery", ErrCannotRewrite, d.from)
+		}
+
+		for _, t := range stmt.Tables() {
+			if t.Name != table {
+				continue
+			}
+			if t.Outer {
+				return fmt.Errorf("%w: scoped table %s is outer joined", ErrCannotRewrite, t.Table)
+			}
+			if pred := scope(t); pred != nil {
+				if err := stmt.Where(pred); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// softDeleteUpdate returns the UPDATE soft deleting the rows that d deletes,
+// or nil if d deletes rows.
+func softDeleteUpdate(d *DeleteBuilder) (*UpdateBuilder, error) {
+	if d.unscoped {
+		return nil, nil
+	}
+	table := deleteTable(d)
+	var column string
+	for _, sd := range d.softDeletes {
+		if tableName(sd.table) == table {
+			column = sd.column
+		}
+	}
+	if column == "" {
+		return nil, nil
+	}
+	if len(d.what) > 1 || len(d.what) == 1 && d.what[0] != d.from {
+		return nil, fmt.Errorf("cannot soft delete from %s with a multiple-table delete", d.from)
+	}
+
+	if len(d.joins) > 0 {
+		// MySQL's UPDATE a JOIN b is ambiguous if b has the column too.
+		refs := sqlTableRefs("DELETE FROM " + d.from)
+		column = refs[0].Column(column)
+	}
+	return &UpdateBuilder{
+		StatementBuilderType: d.StatementBuilderType,
+		returning:            d.returning,
+		prefixes:             d.prefixes,
+		table:                strings.Join(append([]string{d.from}, d.joins...), " "),
+		fromParts:            d.usingParts,
+		setClauses:           []setClause{{column: column, value: Expr("CURRENT_TIMESTAMP")}},
+		whereParts:           d.whereParts,
+		orderBys:             d.orderBys,
+		limit:                d.limit,
+		limitValid:           d.limitValid,
+		offset:               d.offset,
+		offsetValid:          d.offsetValid,
+		suffixes:             d.suffixes,
+	}, nil
+}
+
+// deleteTable returns the name of the table that d deletes from, as in
+// TableRef.Name.
+func deleteTable(d *DeleteBuilder) string {
+	return tableName(d.from)
+}
+
+// tableName returns the name of table, possibly qualified, quoted or
+// aliased, as in TableRef.Name.
+func tableName(table string) string {
+	refs := sqlTableRefs("DELETE FROM " + table)
+	if len(refs) == 0 {
+		return ""
+	}
+	return refs[0].Name
+}
+
+// scopeFlags returns whether the scopes of the builder s are disabled by
+// Unscoped and WithDeleted.
+func scopeFlags(s Sqlizer) (unscoped, withDeleted bool) {
+	switch s := s.(type) {
+	case *SelectBuilder:
+		return s.unscoped, s.withDeleted
+	case *UpdateBuilder:
+		return s.unscoped, s.withDeleted
+	case *DeleteBuilder:
+		return s.unscoped, false
+	}
+	return false, false
+}
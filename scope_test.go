@@ -0,0 +1,195 @@
+package sqrl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScope(t *testing.T) {
+	sb := StatementBuilder.
+		SoftDelete("orders", "deleted_at").
+		Scope("users", func(t TableRef) Sqlizer {
+			return Eq{t.Column("active"): true}
+		})
+
+	testCases := map[string]struct {
+		s    Sqlizer
+		sql  string
+		args []interface{}
+	}{
+		"select": {
+			s:    sb.Select("*").From("orders").Where("total > ?", 10),
+			sql:  "SELECT * FROM orders WHERE (total > ?) AND orders.deleted_at IS NULL",
+			args: []interface{}{10},
+		},
+		"join": {
+			s:    sb.Select("*").From("users u").Join("orders o ON o.user_id = u.id"),
+			sql:  "SELECT * FROM users u JOIN orders o ON o.user_id = u.id WHERE o.deleted_at IS NULL AND u.active = ?",
+			args: []interface{}{true},
+		},
+		"qualified": {
+			s:    sb.Select("*").From("public.users"),
+			sql:  "SELECT * FROM public.users WHERE public.users.active = ?",
+			args: []interface{}{true},
+		},
+		"subquery": {
+			s:    sb.Select("*").From("users").Where(Expr("id IN (?)", Select("user_id").From("orders"))),
+			sql:  "SELECT * FROM users WHERE (id IN (SELECT user_id FROM orders WHERE orders.deleted_at IS NULL)) AND users.active = ?",
+			args: []interface{}{true},
+		},
+		"outer join of unscoped table": {
+			s:    sb.Select("*").From("orders o").LeftJoin("items i ON i.order_id = o.id"),
+			sql:  "SELECT * FROM orders o LEFT JOIN items i ON i.order_id = o.id WHERE o.deleted_at IS NULL",
+			args: nil,
+		},
+		"outer join with deleted": {
+			s:    sb.Select("*").From("items i").LeftJoin("orders o ON o.id = i.order_id AND o.deleted_at IS NULL").WithDeleted(),
+			sql:  "SELECT * FROM items i LEFT JOIN orders o ON o.id = i.order_id AND o.deleted_at IS NULL",
+			args: nil,
+		},
+		"with deleted": {
+			s:    sb.Select("*").From("users").Join("orders USING (user_id)").WithDeleted(),
+			sql:  "SELECT * FROM users JOIN orders USING (user_id) WHERE users.active = ?",
+			args: []interface{}{true},
+		},
+		"unscoped": {
+			s:    sb.Select("*").From("users").Join("orders USING (user_id)").Unscoped(),
+			sql:  "SELECT * FROM users JOIN orders USING (user_id)",
+			args: nil,
+		},
+		"update": {
+			s:    sb.Update("orders").Set("total", 0).Where(Eq{"id": 1}),
+			sql:  "UPDATE orders SET total = ? WHERE (id = ?) AND orders.deleted_at IS NULL",
+			args: []interface{}{0, 1},
+		},
+		"restore": {
+			s:    sb.Update("orders").Set("deleted_at", nil).Where(Eq{"id": 1}).WithDeleted(),
+			sql:  "UPDATE orders SET deleted_at = ? WHERE id = ?",
+			args: []interface{}{nil, 1},
+		},
+		"insert": {
+			s:    sb.Insert("orders").Columns("id").Values(1),
+			sql:  "INSERT INTO orders (id) VALUES (?)",
+			args: []interface{}{1},
+		},
+		"soft delete": {
+			s:    sb.Delete("orders").Where(Eq{"id": 1}).Returning("id"),
+			sql:  "UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE (id = ?) AND orders.deleted_at IS NULL RETURNING id",
+			args: []interface{}{1},
+		},
+		"soft delete using": {
+			s:    sb.Delete("orders").Using("users u").Where("u.id = orders.user_id"),
+			sql:  "UPDATE orders SET deleted_at = CURRENT_TIMESTAMP FROM users u WHERE (u.id = orders.user_id) AND orders.deleted_at IS NULL AND u.active = ?",
+			args: []interface{}{true},
+		},
+		"soft delete join": {
+			s:    sb.Delete("orders").Join("users u ON u.id = orders.user_id").Where(Eq{"u.name": "moe"}).Limit(1),
+			sql:  "UPDATE orders JOIN users u ON u.id = orders.user_id SET orders.deleted_at = CURRENT_TIMESTAMP WHERE (u.name = ?) AND orders.deleted_at IS NULL AND u.active = ? LIMIT 1",
+			args: []interface{}{"moe", true},
+		},
+		"qualified soft delete": {
+			s:    sb.Delete("public.orders").Where(Eq{"id": 1}),
+			sql:  "UPDATE public.orders SET deleted_at = CURRENT_TIMESTAMP WHERE (id = ?) AND public.orders.deleted_at IS NULL",
+			args: []interface{}{1},
+		},
+		"hard delete": {
+			s:    sb.Delete("orders").Where(Eq{"id": 1}).Unscoped(),
+			sql:  "DELETE FROM orders WHERE id = ?",
+			args: []interface{}{1},
+		},
+		"delete": {
+			s:    sb.Delete("users").Where(Eq{"id": 1}),
+			sql:  "DELETE FROM users WHERE (id = ?) AND users.active = ?",
+			args: []interface{}{1, true},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sql, args, err := tc.s.ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.sql, sql)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+}
+
+func TestSoftDeleteErrors(t *testing.T) {
+	sb := StatementBuilder.SoftDelete(`"Orders"`, "deleted_at")
+
+	_, _, err := sb.Delete("o").From("orders o").What("o", "u").ToSql()
+	assert.EqualError(t, err, "cannot soft delete from orders o with a multiple-table delete")
+
+	_, _, err = sb.Select("*").From("d").Prefix("WITH d AS (?)", Delete("orders").Suffix("RETURNING *")).ToSql()
+	assert.True(t, errors.Is(err, ErrCannotRewrite), "%v", err)
+
+	_, _, err = sb.Select("*").From("d").Prefix("WITH d AS (?)", Delete("orders").Suffix("RETURNING *").Unscoped()).ToSql()
+	assert.NoError(t, err)
+}
+
+func TestScopeOuterJoin(t *testing.T) {
+	softDeleted := StatementBuilder.SoftDelete("orders", "deleted_at")
+	scoped := StatementBuilder.Scope("orders", func(t TableRef) Sqlizer {
+		return Eq{t.Column("active"): true}
+	})
+
+	for _, sb := range []StatementBuilderType{softDeleted, scoped} {
+		for _, s := range []Sqlizer{
+			sb.Select("*").From("users u").LeftJoin("orders o ON o.user_id = u.id"),
+			sb.Select("*").From("users u").JoinClause("LEFT OUTER JOIN orders o ON o.user_id = u.id"),
+			sb.Select("*").From("orders o").RightJoin("users u ON o.user_id = u.id"),
+			sb.Select("*").From("users u").JoinClause("FULL JOIN orders o ON o.user_id = u.id"),
+			sb.Update("users").Set("a", 1).From("users u LEFT JOIN orders o ON o.user_id = u.id"),
+		} {
+			_, _, err := s.ToSql()
+			assert.True(t, errors.Is(err, ErrCannotRewrite), "%v", err)
+		}
+	}
+
+	_, _, err := softDeleted.Select("*").From("users u").LeftJoin("orders o ON o.user_id = u.id").ToSql()
+	assert.EqualError(t, err, "cannot rewrite statement: scoped table orders is outer joined")
+}
+
+func TestSoftDeleteExec(t *testing.T) {
+	db := &DBStub{}
+	b := StatementBuilder.SoftDelete("orders", "deleted_at").RunWith(db).Delete("orders").Where(Eq{"id": 1})
+
+	_, err := b.Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE orders SET deleted_at = CURRENT_TIMESTAMP WHERE (id = ?) AND orders.deleted_at IS NULL", db.LastExecSql)
+
+	sql, _, err := b.Unscoped().ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM orders WHERE id = ?", sql)
+}
+
+func TestScopeHiddenTables(t *testing.T) {
+	sb := StatementBuilder.SoftDelete("users", "deleted_at")
+
+	sql, _, err := sb.Select("*").From("orders").Where("id IN (SELECT order_id FROM items)").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM orders WHERE id IN (SELECT order_id FROM items)", sql)
+
+	sql, _, err = sb.Select("*").From("orders o").JoinClause(sqlizerFunc("LEFT JOIN items i ON i.order_id = o.id")).ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM orders o LEFT JOIN items i ON i.order_id = o.id", sql)
+
+	sql, _, err = sb.Select("*").From("(orders o JOIN items i ON i.order_id = o.id)").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM (orders o JOIN items i ON i.order_id = o.id)", sql)
+
+	for _, s := range []Sqlizer{
+		sb.Select("*").From("orders").Where("user_id IN (SELECT id FROM public.users)"),
+		sb.Select("*").From("orders").Where("user_id IN (/*!50100 SELECT id FROM users */)"),
+		sb.Select("*").From("orders o").JoinClause(sqlizerFunc("LEFT JOIN users u ON u.id = o.user_id")),
+		sb.Select("*").From("(orders o JOIN users u ON u.id = o.user_id)"),
+		sb.Select("*").From("orders o").Join("items i ON i.order_id = o.id, users u"),
+		sb.Rewrite(func(*RewriteStatement) error { return nil }).
+			Select("*").From("orders").Where("id IN (SELECT order_id FROM items)"),
+	} {
+		_, _, err := s.ToSql()
+		assert.True(t, errors.Is(err, ErrCannotRewrite), "%v", err)
+	}
+}
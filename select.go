@@ -5,7 +5,6 @@ import (
 	"context"
 	"database/sql"
 	"strconv"
-	"strings"
 )
 
 // SelectBuilder builds SQL SELECT statements.
@@ -29,6 +28,11 @@ type SelectBuilder struct {
 	offsetValid bool
 
 	suffixes exprs
+
+	// unscoped and withDeleted disable the scopes of the query, see Unscoped
+	// and WithDeleted.
+	unscoped    bool
+	withDeleted bool
 }
 
 // NewSelectBuilder creates new instance of SelectBuilder
@@ -36,9 +40,52 @@ func NewSelectBuilder(b StatementBuilderType) *SelectBuilder {
 	return &SelectBuilder{StatementBuilderType: b}
 }
 
-// RunWith sets a Runner (like database/sql.DB) to be used with e.g. Exec.
-func (b *SelectBuilder) RunWith(runner BaseRunner) *SelectBuilder {
-	b.runWith = wrapRunner(runner)
+// Clone returns a deep copy of the builder.
+//
+// Modifying the clone, including subqueries added with FromSelect, never
+// affects the original builder and vice versa.
+func (b *SelectBuilder) Clone() *SelectBuilder {
+	c := *b
+	c.prefixes = b.prefixes.clone()
+	c.options = cloneStrings(b.options)
+	c.columns = cloneSqlizers(b.columns)
+	c.fromParts = cloneSqlizers(b.fromParts)
+	c.joins = cloneSqlizers(b.joins)
+	c.whereParts = cloneSqlizers(b.whereParts)
+	c.groupBys = cloneStrings(b.groupBys)
+	c.havingParts = cloneSqlizers(b.havingParts)
+	c.orderBys = cloneStrings(b.orderBys)
+	c.suffixes = b.suffixes.clone()
+	return &c
+}
+
+// derive returns the builder that a modification should be applied to: b
+// itself, or a copy of b if the builder is immutable.
+//
+// The copy shares clause slices with b, but their capacity is capped so that
+// appending to them always allocates.
+func (b *SelectBuilder) derive() *SelectBuilder {
+	if !b.immutable {
+		return b
+	}
+	c := *b
+	c.prefixes = c.prefixes[:len(c.prefixes):len(c.prefixes)]
+	c.options = c.options[:len(c.options):len(c.options)]
+	c.columns = c.columns[:len(c.columns):len(c.columns)]
+	c.fromParts = c.fromParts[:len(c.fromParts):len(c.fromParts)]
+	c.joins = c.joins[:len(c.joins):len(c.joins)]
+	c.whereParts = c.whereParts[:len(c.whereParts):len(c.whereParts)]
+	c.groupBys = c.groupBys[:len(c.groupBys):len(c.groupBys)]
+	c.havingParts = c.havingParts[:len(c.havingParts):len(c.havingParts)]
+	c.orderBys = c.orderBys[:len(c.orderBys):len(c.orderBys)]
+	c.suffixes = c.suffixes[:len(c.suffixes):len(c.suffixes)]
+	return &c
+}
+
+// RunWith sets a Runner (like database/sql.DB or sql.Conn) to be used with e.g. Exec.
+func (b *SelectBuilder) RunWith(runner BaseRunnerContext) *SelectBuilder {
+	b = b.derive()
+	b.runWith = adaptRunner(runner)
 	return b
 }
 
@@ -92,16 +139,52 @@ func (b *SelectBuilder) Scan(dest ...interface{}) error {
 // PlaceholderFormat sets PlaceholderFormat (e.g. Question or Dollar) for the
 // query.
 func (b *SelectBuilder) PlaceholderFormat(f PlaceholderFormat) *SelectBuilder {
+	b = b.derive()
 	b.placeholderFormat = f
 	return b
 }
 
 // ToSql builds the query into a SQL string and bound args.
 func (b *SelectBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
-	sql := &bytes.Buffer{}
+	return statementToSql(b, b.StatementBuilderType)
+}
+
+// Tag adds a key/value tag that ToSql appends to the query as a sqlcommenter
+// comment, e.g. "/*route='%2Fusers'*/". Keys and values are URL-encoded.
+//
+// Tags are only rendered for top-level statements, not for subqueries.
+func (b *SelectBuilder) Tag(key, value string) *SelectBuilder {
+	b = b.derive()
+	b.tags = b.tags.with(key, value)
+	return b
+}
+
+// Unscoped disables the default scopes of the query, see
+// StatementBuilderType.Scope. Subqueries keep their scopes.
+func (b *SelectBuilder) Unscoped() *SelectBuilder {
+	b = b.derive()
+	b.unscoped = true
+	return b
+}
+
+// WithDeleted disables the scopes of soft-deleted tables of the query, so
+// that it sees deleted rows too, see StatementBuilderType.SoftDelete.
+// Subqueries keep their scopes.
+func (b *SelectBuilder) WithDeleted() *SelectBuilder {
+	b = b.derive()
+	b.withDeleted = true
+	return b
+}
+
+// AppendSql implements SqlAppender
+func (b *SelectBuilder) AppendSql(sql *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	var err error
 
 	if len(b.prefixes) > 0 {
-		args, _ = b.prefixes.AppendToSql(sql, " ", args)
+		args, err = b.prefixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 		sql.WriteString(" ")
 	}
 
@@ -112,14 +195,14 @@ func (b *SelectBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 	}
 
 	if len(b.options) > 0 {
-		sql.WriteString(strings.Join(b.options, " "))
+		writeStrings(sql, b.options, " ")
 		sql.WriteString(" ")
 	}
 
 	if len(b.columns) > 0 {
 		args, err = appendToSql(b.columns, sql, ", ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
@@ -127,7 +210,7 @@ func (b *SelectBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 		sql.WriteString(" FROM ")
 		args, err = appendToSql(b.fromParts, sql, ", ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
@@ -135,7 +218,7 @@ func (b *SelectBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 		sql.WriteString(" ")
 		args, err = appendToSql(b.joins, sql, " ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
@@ -143,26 +226,26 @@ func (b *SelectBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 		sql.WriteString(" WHERE ")
 		args, err = appendToSql(b.whereParts, sql, " AND ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.groupBys) > 0 {
 		sql.WriteString(" GROUP BY ")
-		sql.WriteString(strings.Join(b.groupBys, ", "))
+		writeStrings(sql, b.groupBys, ", ")
 	}
 
 	if len(b.havingParts) > 0 {
 		sql.WriteString(" HAVING ")
 		args, err = appendToSql(b.havingParts, sql, " AND ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.orderBys) > 0 {
 		sql.WriteString(" ORDER BY ")
-		sql.WriteString(strings.Join(b.orderBys, ", "))
+		writeStrings(sql, b.orderBys, ", ")
 	}
 
 	// TODO: limit == 0 and offswt == 0 are valid. Need to go dbr way and implement offsetValid and limitValid
@@ -178,22 +261,25 @@ func (b *SelectBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 
 	if len(b.suffixes) > 0 {
 		sql.WriteString(" ")
-		args, _ = b.suffixes.AppendToSql(sql, " ", args)
+		args, err = b.suffixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	sqlStr, err = b.placeholderFormat.ReplacePlaceholders(sql.String())
-	return
-
+	return args, nil
 }
 
 // Prefix adds an expression to the beginning of the query
 func (b *SelectBuilder) Prefix(sql string, args ...interface{}) *SelectBuilder {
+	b = b.derive()
 	b.prefixes = append(b.prefixes, Expr(sql, args...))
 	return b
 }
 
 // Distinct adds a DISTINCT clause to the query.
 func (b *SelectBuilder) Distinct() *SelectBuilder {
+	b = b.derive()
 	b.distinct = true
 
 	return b
@@ -201,6 +287,7 @@ func (b *SelectBuilder) Distinct() *SelectBuilder {
 
 // Options adds select option to the query
 func (b *SelectBuilder) Options(options ...string) *SelectBuilder {
+	b = b.derive()
 	for _, str := range options {
 		b.options = append(b.options, str)
 	}
@@ -209,6 +296,7 @@ func (b *SelectBuilder) Options(options ...string) *SelectBuilder {
 
 // Columns adds result columns to the query.
 func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	b = b.derive()
 	for _, str := range columns {
 		b.columns = append(b.columns, newPart(str))
 	}
@@ -221,6 +309,7 @@ func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
 // the columns string, for example:
 //   Column("IF(col IN ("+Placeholders(3)+"), 1, 0) as col", 1, 2, 3)
 func (b *SelectBuilder) Column(column interface{}, args ...interface{}) *SelectBuilder {
+	b = b.derive()
 	b.columns = append(b.columns, newPart(column, args...))
 
 	return b
@@ -228,6 +317,7 @@ func (b *SelectBuilder) Column(column interface{}, args ...interface{}) *SelectB
 
 // From sets the FROM clause of the query.
 func (b *SelectBuilder) From(tables ...string) *SelectBuilder {
+	b = b.derive()
 	parts := make([]Sqlizer, len(tables))
 	for i, table := range tables {
 		parts[i] = newPart(table)
@@ -239,12 +329,14 @@ func (b *SelectBuilder) From(tables ...string) *SelectBuilder {
 
 // FromSelect sets a subquery into the FROM clause of the query.
 func (b *SelectBuilder) FromSelect(from *SelectBuilder, alias string) *SelectBuilder {
+	b = b.derive()
 	b.fromParts = append(b.fromParts, Alias(from, alias))
 	return b
 }
 
 // JoinClause adds a join clause to the query.
 func (b *SelectBuilder) JoinClause(pred interface{}, args ...interface{}) *SelectBuilder {
+	b = b.derive()
 	b.joins = append(b.joins, newPart(pred, args...))
 
 	return b
@@ -286,12 +378,14 @@ func (b *SelectBuilder) RightJoin(join string, rest ...interface{}) *SelectBuild
 //
 // Where will panic if pred isn't any of the above types.
 func (b *SelectBuilder) Where(pred interface{}, args ...interface{}) *SelectBuilder {
+	b = b.derive()
 	b.whereParts = append(b.whereParts, NewWherePart(pred, args...))
 	return b
 }
 
 // GroupBy adds GROUP BY expressions to the query.
 func (b *SelectBuilder) GroupBy(groupBys ...string) *SelectBuilder {
+	b = b.derive()
 	b.groupBys = append(b.groupBys, groupBys...)
 	return b
 }
@@ -300,18 +394,21 @@ func (b *SelectBuilder) GroupBy(groupBys ...string) *SelectBuilder {
 //
 // See Where.
 func (b *SelectBuilder) Having(pred interface{}, rest ...interface{}) *SelectBuilder {
+	b = b.derive()
 	b.havingParts = append(b.havingParts, NewWherePart(pred, rest...))
 	return b
 }
 
 // OrderBy adds ORDER BY expressions to the query.
 func (b *SelectBuilder) OrderBy(orderBys ...string) *SelectBuilder {
+	b = b.derive()
 	b.orderBys = append(b.orderBys, orderBys...)
 	return b
 }
 
 // Limit sets a LIMIT clause on the query.
 func (b *SelectBuilder) Limit(limit uint64) *SelectBuilder {
+	b = b.derive()
 	b.limit = limit
 	b.limitValid = true
 	return b
@@ -319,6 +416,7 @@ func (b *SelectBuilder) Limit(limit uint64) *SelectBuilder {
 
 // Offset sets a OFFSET clause on the query.
 func (b *SelectBuilder) Offset(offset uint64) *SelectBuilder {
+	b = b.derive()
 	b.offset = offset
 	b.offsetValid = true
 	return b
@@ -326,6 +424,7 @@ func (b *SelectBuilder) Offset(offset uint64) *SelectBuilder {
 
 // Suffix adds an expression to the end of the query
 func (b *SelectBuilder) Suffix(sql string, args ...interface{}) *SelectBuilder {
+	b = b.derive()
 	b.suffixes = append(b.suffixes, Expr(sql, args...))
 
 	return b
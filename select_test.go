@@ -213,3 +213,43 @@ func TestSelectWithOptions(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, "SELECT DISTINCT SQL_NO_CACHE * FROM foo", sql)
 }
+
+func TestSelectBuilderClone(t *testing.T) {
+	subQ := Select("c").From("d").Where(Eq{"i": 0})
+	base := Select("a").FromSelect(subQ, "subq").Where("x = ?", 1).Where("y = ?", 2)
+
+	c1 := base.Clone().Where("z = ?", 3).OrderBy("a")
+	c2 := base.Clone().Where("w = ?", 4).Limit(1)
+
+	sql, args, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT c FROM d WHERE i = ?) AS subq WHERE x = ? AND y = ?", sql)
+	assert.Equal(t, []interface{}{0, 1, 2}, args)
+
+	sql, args, err = c1.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT c FROM d WHERE i = ?) AS subq WHERE x = ? AND y = ? AND z = ? ORDER BY a", sql)
+	assert.Equal(t, []interface{}{0, 1, 2, 3}, args)
+
+	sql, args, err = c2.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT c FROM d WHERE i = ?) AS subq WHERE x = ? AND y = ? AND w = ? LIMIT 1", sql)
+	assert.Equal(t, []interface{}{0, 1, 2, 4}, args)
+}
+
+func TestSelectBuilderCloneSubquery(t *testing.T) {
+	subQ := Select("c").From("d")
+	base := Select("a").FromSelect(subQ, "subq")
+
+	clone := base.Clone()
+	subQ.Where("e = ?", 1)
+
+	sql, args, err := clone.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT c FROM d) AS subq", sql)
+	assert.Empty(t, args)
+
+	sql, _, err = base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT c FROM d WHERE e = ?) AS subq", sql)
+}
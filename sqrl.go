@@ -67,6 +67,14 @@ type BaseRunner interface {
 	QueryerContext
 }
 
+// BaseRunnerContext groups the ExecerContext and QueryerContext interfaces.
+// It is implemented by every BaseRunner, and by handles that only have
+// context methods, such as *sql.Conn.
+type BaseRunnerContext interface {
+	ExecerContext
+	QueryerContext
+}
+
 // Runner groups the Execer, Queryer, and QueryRower interfaces.
 type Runner interface {
 	Execer
@@ -92,6 +100,9 @@ func ExecWith(db Execer, s Sqlizer) (res sql.Result, err error) {
 	if err != nil {
 		return
 	}
+	if sr, ok := db.(statementRunner); ok {
+		return sr.execStatement(context.Background(), s, query, args)
+	}
 	return db.Exec(query, args...)
 }
 
@@ -101,6 +112,9 @@ func ExecWithContext(ctx context.Context, db ExecerContext, s Sqlizer) (res sql.
 	if err != nil {
 		return
 	}
+	if sr, ok := db.(statementRunner); ok {
+		return sr.execStatement(ctx, s, query, args)
+	}
 	return db.ExecContext(ctx, query, args...)
 }
 
@@ -110,6 +124,9 @@ func QueryWith(db Queryer, s Sqlizer) (rows *sql.Rows, err error) {
 	if err != nil {
 		return
 	}
+	if sr, ok := db.(statementRunner); ok {
+		return sr.queryStatement(context.Background(), s, query, args)
+	}
 	return db.Query(query, args...)
 }
 
@@ -119,18 +136,27 @@ func QueryWithContext(ctx context.Context, db QueryerContext, s Sqlizer) (rows *
 	if err != nil {
 		return
 	}
+	if sr, ok := db.(statementRunner); ok {
+		return sr.queryStatement(ctx, s, query, args)
+	}
 	return db.QueryContext(ctx, query, args...)
 }
 
 // QueryRowWith QueryRows the SQL returned by s with db.
 func QueryRowWith(db QueryRower, s Sqlizer) RowScanner {
 	query, args, err := s.ToSql()
+	if sr, ok := db.(statementRunner); ok && err == nil {
+		return sr.queryRowStatement(context.Background(), s, query, args)
+	}
 	return &Row{RowScanner: db.QueryRow(query, args...), err: err}
 }
 
 // QueryRowWithContext QueryRows the SQL returned by s with db.
 func QueryRowWithContext(ctx context.Context, db QueryRowerContext, s Sqlizer) RowScanner {
 	query, args, err := s.ToSql()
+	if sr, ok := db.(statementRunner); ok && err == nil {
+		return sr.queryRowStatement(ctx, s, query, args)
+	}
 	return &Row{RowScanner: db.QueryRowContext(ctx, query, args...), err: err}
 }
 
@@ -160,15 +186,79 @@ func (r *txRunner) QueryRowContext(ctx context.Context, query string, args ...in
 	return r.Tx.QueryRowContext(ctx, query, args...)
 }
 
-// WrapRunner returns Runner for sql.DB and sql.Tx, or BaseRunner otherwise.
-func wrapRunner(baseRunner BaseRunner) (runner BaseRunner) {
+// ContextRunner wraps handles with context methods only, such as sql.Conn, to
+// implement BaseRunner.
+type contextRunner struct {
+	BaseRunnerContext
+}
+
+func (r *contextRunner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.ExecContext(context.Background(), query, args...)
+}
+
+func (r *contextRunner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+// sqlRowQueryer is implemented by handles of database/sql and handles that
+// embed them.
+type sqlRowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// HandleRunner wraps handles whose QueryRowContext returns sql.Row, such as
+// sql.Conn, to implement Runner.
+type handleRunner struct {
+	contextRunner
+	rowQueryer sqlRowQueryer
+}
+
+func (r *handleRunner) QueryRow(query string, args ...interface{}) RowScanner {
+	return r.rowQueryer.QueryRowContext(context.Background(), query, args...)
+}
+
+func (r *handleRunner) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return r.rowQueryer.QueryRowContext(ctx, query, args...)
+}
+
+// RowerRunner wraps handles with context methods only whose QueryRowContext
+// returns RowScanner to implement Runner.
+type rowerRunner struct {
+	contextRunner
+	rower QueryRowerContext
+}
+
+func (r *rowerRunner) QueryRow(query string, args ...interface{}) RowScanner {
+	return r.rower.QueryRowContext(context.Background(), query, args...)
+}
+
+func (r *rowerRunner) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return r.rower.QueryRowContext(ctx, query, args...)
+}
+
+// adaptRunner returns Runner for sql.DB, sql.Tx, sql.Conn and other handles
+// with QueryRowContext, or BaseRunner otherwise.
+func adaptRunner(baseRunner BaseRunnerContext) (runner BaseRunner) {
+	if baseRunner == nil {
+		return nil
+	}
+
 	switch r := baseRunner.(type) {
 	case *sql.DB:
-		runner = &dbRunner{r}
+		return &dbRunner{r}
 	case *sql.Tx:
-		runner = &txRunner{r}
-	default:
-		runner = r
+		return &txRunner{r}
+	case QueryRowerContext:
+		if r, ok := baseRunner.(BaseRunner); ok {
+			return r
+		}
+		return &rowerRunner{contextRunner{baseRunner}, r}
+	case sqlRowQueryer:
+		return &handleRunner{contextRunner{baseRunner}, r}
+	}
+
+	if r, ok := baseRunner.(BaseRunner); ok {
+		return r
 	}
-	return
+	return &contextRunner{baseRunner}
 }
@@ -3,6 +3,7 @@ package sqrl
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
 	"testing"
 
@@ -128,3 +129,90 @@ type errSqlizer struct{}
 func (errSqlizer) ToSql() (string, []interface{}, error) {
 	return "", nil, errors.New("nope")
 }
+
+func TestRunWithConn(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.columns = []string{"a"}
+	fake.rows = [][]driver.Value{{int64(1)}}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SET search_path = app")
+	assert.NoError(t, err)
+
+	var a int
+	err = Select("a").From("b").RunWith(conn).QueryRow().Scan(&a)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+
+	_, err = Update("b").Set("a", 2).RunWith(conn).Exec()
+	assert.NoError(t, err)
+
+	rows, err := StatementBuilder.RunWith(conn).Select("a").From("b").Query()
+	assert.NoError(t, err)
+	rows.Close()
+
+	err = InTx(ctx, conn, nil, func(tx Runner) error {
+		_, err := Delete("b").RunWith(tx).ExecContext(ctx)
+		return err
+	})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, fake.Count("exec SET search_path = app"))
+	assert.Equal(t, 2, fake.Count("query SELECT a FROM b"))
+	assert.Equal(t, 1, fake.Count("exec UPDATE b SET a = ?"))
+	assert.Equal(t, 1, fake.Count("exec DELETE FROM b"))
+	assert.Equal(t, 1, fake.Count("commit"))
+}
+
+// embeddedDB is a handle that embeds *sql.DB, like the handles of sqlx.
+type embeddedDB struct {
+	*sql.DB
+}
+
+// contextOnly is a handle without QueryRowContext.
+type contextOnly struct {
+	ExecerContext
+	QueryerContext
+}
+
+// rowerContextOnly is a handle with context methods only whose
+// QueryRowContext returns RowScanner.
+type rowerContextOnly struct {
+	contextOnly
+	QueryRowerContext
+}
+
+func TestRunWithHandle(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.columns = []string{"a"}
+	fake.rows = [][]driver.Value{{int64(1)}}
+
+	var a int
+	err := Select("a").From("b").RunWith(embeddedDB{db}).QueryRow().Scan(&a)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+
+	handle := contextOnly{db, db}
+	_, err = Delete("b").RunWith(handle).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.Count("exec DELETE FROM b"))
+
+	err = Select("a").From("b").RunWith(handle).QueryRow().Scan(&a)
+	assert.Equal(t, ErrRunnerNotQueryRunnerContext, err)
+
+	a = 0
+	rower := rowerContextOnly{handle, &dbRunner{db}}
+	err = Select("a").From("b").RunWith(rower).QueryRow().Scan(&a)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+	_, err = Delete("b").RunWith(rower).Exec()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.Count("exec DELETE FROM b"))
+
+	_, err = Select("a").From("b").RunWith(nil).Exec()
+	assert.Equal(t, ErrRunnerNotSet, err)
+}
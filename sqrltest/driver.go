@@ -0,0 +1,191 @@
+package sqrltest
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+
+	"github.com/SharperShape/sqrl"
+)
+
+// connector is the in-process driver of a Runner. Statements are executed
+// without being prepared, and args are passed through as they are so that
+// they're recorded as given.
+type connector struct {
+	r *Runner
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{c.r}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{c.r}
+}
+
+type fakeDriver struct {
+	r *Runner
+}
+
+func (d fakeDriver) Open(string) (driver.Conn, error) {
+	return &conn{d.r}, nil
+}
+
+type conn struct {
+	r *Runner
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{c, query}, nil
+}
+
+func (c *conn) Close() error {
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return tx{}, nil
+}
+
+func (c *conn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	s := c.r.record(Call{Method: sqrl.MethodExec, SQL: query, Args: values(args)})
+	if s == nil {
+		return result{}, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return result{s.lastInsertId, s.rowsAffected}, nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	method := sqrl.MethodQuery
+	if ctx.Value(queryRowKey{}) != nil {
+		method = sqrl.MethodQueryRow
+	}
+
+	s := c.r.record(Call{Method: method, SQL: query, Args: values(args)})
+	if s == nil {
+		return &rows{}, nil
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &rows{columns: s.columns, rows: s.rows}, nil
+}
+
+func values(args []driver.NamedValue) []interface{} {
+	if len(args) == 0 {
+		return nil
+	}
+	values := make([]interface{}, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+type stmt struct {
+	c     *conn
+	query string
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), named(args))
+}
+
+func (s *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return s.c.ExecContext(ctx, s.query, args)
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), named(args))
+}
+
+func (s *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return s.c.QueryContext(ctx, s.query, args)
+}
+
+func named(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type tx struct{}
+
+func (tx) Commit() error {
+	return nil
+}
+
+func (tx) Rollback() error {
+	return nil
+}
+
+type result struct {
+	lastInsertId, rowsAffected int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return r.rowsAffected, nil
+}
+
+type rows struct {
+	columns []string
+	rows    [][]interface{}
+	i       int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	row := r.rows[r.i]
+	r.i++
+	for i := range dest {
+		if i >= len(row) {
+			dest[i] = nil
+			continue
+		}
+		v, err := driver.DefaultParameterConverter.ConvertValue(row[i])
+		if err != nil {
+			return err
+		}
+		dest[i] = v
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package sqrltest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/SharperShape/sqrl"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+var update = flag.Bool("sqrltest.update", false, "update the golden files of sqrltest.Golden")
+
+// GoldenOption configures Golden.
+type GoldenOption func(*goldenConfig)
+
+type goldenConfig struct {
+	pretty bool
+}
+
+// Pretty formats the SQL of golden files with sqrl.FormatSql.
+func Pretty() GoldenOption {
+	return func(c *goldenConfig) {
+		c.pretty = true
+	}
+}
+
+// Golden checks that the SQL and args of s match the golden file
+// testdata/<name>.sql, and shows a diff if they don't.
+//
+// Run the tests with the -sqrltest.update flag to write the golden files:
+//
+//	go test ./... -args -sqrltest.update
+func Golden(t testing.TB, name string, s sqrl.Sqlizer, opts ...GoldenOption) bool {
+	t.Helper()
+	c := &goldenConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	sql, args, err := s.ToSql()
+	if err != nil {
+		t.Errorf("golden %s: %v", name, err)
+		return false
+	}
+	if c.pretty {
+		sql = sqrl.FormatSql(sql)
+	}
+	actual := goldenContent(sql, args)
+
+	path := filepath.Join("testdata", filepath.FromSlash(name)+".sql")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Errorf("golden %s: %v", name, err)
+			return false
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Errorf("golden %s: %v", name, err)
+			return false
+		}
+		return true
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Errorf("golden file %s doesn't exist, run the tests with -sqrltest.update to create it", path)
+		return false
+	}
+	if err != nil {
+		t.Errorf("golden %s: %v", name, err)
+		return false
+	}
+
+	expected = bytes.Replace(expected, []byte("\r\n"), []byte("\n"), -1)
+	if string(expected) == actual {
+		return true
+	}
+
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(string(expected)),
+		B:        difflib.SplitLines(actual),
+		FromFile: path,
+		ToFile:   "actual",
+		Context:  3,
+	})
+	t.Errorf("SQL doesn't match golden file %s, run the tests with -sqrltest.update to update it:\n%s", path, diff)
+	return false
+}
+
+// goldenContent returns the content of the golden file of sql and args. Args
+// are listed in comments after the SQL, one per line.
+func goldenContent(sql string, args []interface{}) string {
+	var buf strings.Builder
+	buf.WriteString(sql)
+	buf.WriteString("\n")
+	if len(args) > 0 {
+		buf.WriteString("\n-- args:\n")
+		for i, arg := range args {
+			fmt.Fprintf(&buf, "-- %d: %s\n", i+1, formatArg(arg))
+		}
+	}
+	return buf.String()
+}
+
+// formatArg formats arg as Go syntax, following pointers so that the result
+// doesn't depend on addresses.
+func formatArg(arg interface{}) string {
+	v := reflect.ValueOf(arg)
+	prefix := ""
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		prefix += "&"
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return "nil"
+	}
+	return strings.Replace(prefix+fmt.Sprintf("%#v", v.Interface()), "\n", `\n`, -1)
+}
@@ -0,0 +1,66 @@
+package sqrltest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SharperShape/sqrl"
+	"github.com/stretchr/testify/assert"
+)
+
+func goldenQuery() *sqrl.SelectBuilder {
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	name := "it's"
+	return sqrl.Select("u.id", "u.name").
+		From("users u").
+		Join("emails e ON e.user_id = u.id").
+		Where(sqrl.Eq{"u.id": []int{1, 2}}).
+		Where("u.created_at > ? AND u.name <> ?", since, &name).
+		Where(sqrl.Eq{"e.address": []byte("x\ny"), "e.deleted_at": nil}).
+		OrderBy("u.name")
+}
+
+func TestGolden(t *testing.T) {
+	Golden(t, "select", goldenQuery())
+	Golden(t, "pretty/select", goldenQuery(), Pretty())
+	Golden(t, "noargs", sqrl.Delete("users"))
+}
+
+func TestGoldenMismatch(t *testing.T) {
+	rt := &recordingT{}
+	assert.False(t, Golden(rt, "select", goldenQuery().Limit(10)))
+	assert.Len(t, rt.errors, 1)
+	assert.Contains(t, rt.errors[0], "SQL doesn't match golden file testdata/select.sql")
+	assert.Contains(t, rt.errors[0], "\n-SELECT u.id, u.name FROM users u JOIN emails e ON e.user_id = u.id WHERE u.id IN (?,?) AND u.created_at > ? AND u.name <> ? AND e.address = ? AND e.deleted_at IS NULL ORDER BY u.name\n")
+	assert.Contains(t, rt.errors[0], "\n+SELECT u.id, u.name FROM users u JOIN emails e ON e.user_id = u.id WHERE u.id IN (?,?) AND u.created_at > ? AND u.name <> ? AND e.address = ? AND e.deleted_at IS NULL ORDER BY u.name LIMIT 10\n")
+	assert.Contains(t, rt.errors[0], "\n -- 1: 1\n")
+
+	rt.errors = nil
+	assert.False(t, Golden(rt, "missing", goldenQuery()))
+	assert.Equal(t, []string{"golden file testdata/missing.sql doesn't exist, run the tests with -sqrltest.update to create it"}, rt.errors)
+
+	rt.errors = nil
+	assert.False(t, Golden(rt, "error", sqrl.Update("users")))
+	assert.Len(t, rt.errors, 1)
+}
+
+func TestGoldenUpdate(t *testing.T) {
+	defer func(u bool) { *update = u }(*update)
+	*update = true
+	defer os.RemoveAll(filepath.Join("testdata", "tmp"))
+
+	assert.True(t, Golden(t, "tmp/update", sqrl.Select("a").From("b").Where("c = ?", 1)))
+	content, err := ioutil.ReadFile(filepath.Join("testdata", "tmp", "update.sql"))
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"SELECT a FROM b WHERE c = ?",
+		"",
+		"-- args:",
+		"-- 1: 1",
+		"",
+	}, "\n"), string(content))
+}
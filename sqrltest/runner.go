@@ -0,0 +1,270 @@
+// Package sqrltest provides test doubles for code built on sqrl.
+//
+//	db := sqrltest.NewRunner()
+//	db.On("SELECT name FROM users WHERE id = ?").Rows([]string{"name"}, []interface{}{"moe"})
+//
+//	name, err := users.NameByID(db, 1)
+//
+//	db.AssertCalls(t, sqrltest.QueryRow("SELECT name FROM users WHERE id = ?", 1))
+package sqrltest
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/SharperShape/sqrl"
+)
+
+// Call is a statement executed by a Runner.
+type Call struct {
+	Method sqrl.RunMethod
+	SQL    string
+	Args   []interface{}
+}
+
+// Exec returns the Call of an Exec of sql with args.
+func Exec(sql string, args ...interface{}) Call {
+	return Call{Method: sqrl.MethodExec, SQL: sql, Args: args}
+}
+
+// Query returns the Call of a Query of sql with args.
+func Query(sql string, args ...interface{}) Call {
+	return Call{Method: sqrl.MethodQuery, SQL: sql, Args: args}
+}
+
+// QueryRow returns the Call of a QueryRow of sql with args.
+func QueryRow(sql string, args ...interface{}) Call {
+	return Call{Method: sqrl.MethodQueryRow, SQL: sql, Args: args}
+}
+
+func (c Call) String() string {
+	return fmt.Sprintf("%v %s %v", c.Method, c.SQL, c.Args)
+}
+
+// matches reports whether c and o are the same statement. SQL that differs
+// only by whitespace is the same.
+func (c Call) matches(o Call) bool {
+	if c.Method != o.Method || !sameSql(c.SQL, o.SQL) || len(c.Args) != len(o.Args) {
+		return false
+	}
+	for i := range c.Args {
+		if !reflect.DeepEqual(c.Args[i], o.Args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameSql(a, b string) bool {
+	return strings.Join(strings.Fields(a), " ") == strings.Join(strings.Fields(b), " ")
+}
+
+// Runner is a fake sqrl.Runner that records every statement and returns
+// scripted results.
+//
+// Statements are executed by a *sql.DB backed by an in-process driver, so
+// results and rows are the ones of database/sql. Statements without a script
+// affect no rows and return no rows.
+type Runner struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	calls   []Call
+	scripts []*Script
+}
+
+// NewRunner returns a new Runner.
+func NewRunner() *Runner {
+	r := &Runner{}
+	r.db = sql.OpenDB(&connector{r})
+	return r
+}
+
+// DB returns the *sql.DB that executes the statements of r, for code that
+// needs one, e.g. to begin transactions. Its statements are recorded too;
+// its QueryRow calls are recorded as Query calls.
+func (r *Runner) DB() *sql.DB {
+	return r.db
+}
+
+// Close closes the DB of r.
+func (r *Runner) Close() error {
+	return r.db.Close()
+}
+
+// On returns a new Script for the statements with SQL sql, or for any
+// statement if sql is empty. SQL that differs only by whitespace is the same.
+//
+// Scripts are used once, in the order they were added, unless repeated.
+func (r *Runner) On(sql string) *Script {
+	s := &Script{sql: sql}
+	r.mu.Lock()
+	r.scripts = append(r.scripts, s)
+	r.mu.Unlock()
+	return s
+}
+
+// Calls returns the statements executed so far.
+func (r *Runner) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Reset forgets the statements executed so far and the unused scripts.
+func (r *Runner) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = nil
+	r.scripts = nil
+}
+
+// record records call and returns its script, nil if there is none.
+func (r *Runner) record(call Call) *Script {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.calls = append(r.calls, call)
+	for i, s := range r.scripts {
+		if s.sql != "" && !sameSql(s.sql, call.SQL) {
+			continue
+		}
+		if !s.repeat {
+			r.scripts = append(r.scripts[:i:i], r.scripts[i+1:]...)
+		}
+		return s
+	}
+	return nil
+}
+
+// AssertCalls checks that the statements executed so far are expected, in
+// order.
+func (r *Runner) AssertCalls(t testing.TB, expected ...Call) bool {
+	t.Helper()
+	calls := r.Calls()
+	ok := len(calls) == len(expected)
+	for i := 0; ok && i < len(calls); i++ {
+		ok = expected[i].matches(calls[i])
+	}
+	if !ok {
+		t.Errorf("unexpected calls\nexpected:\n%s\nactual:\n%s", formatCalls(expected), formatCalls(calls))
+	}
+	return ok
+}
+
+// AssertCallsInAnyOrder checks that the statements executed so far are
+// expected, in any order.
+func (r *Runner) AssertCallsInAnyOrder(t testing.TB, expected ...Call) bool {
+	t.Helper()
+	calls := r.Calls()
+	unmatched := append([]Call(nil), calls...)
+	var missing []Call
+	for _, e := range expected {
+		found := false
+		for i, c := range unmatched {
+			if e.matches(c) {
+				unmatched = append(unmatched[:i], unmatched[i+1:]...)
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, e)
+		}
+	}
+
+	if len(missing) > 0 || len(unmatched) > 0 {
+		t.Errorf("unexpected calls\nmissing:\n%s\nunexpected:\n%s", formatCalls(missing), formatCalls(unmatched))
+		return false
+	}
+	return true
+}
+
+// AssertNoCalls checks that no statement was executed.
+func (r *Runner) AssertNoCalls(t testing.TB) bool {
+	t.Helper()
+	return r.AssertCalls(t)
+}
+
+func formatCalls(calls []Call) string {
+	if len(calls) == 0 {
+		return "\t(none)"
+	}
+	lines := make([]string, len(calls))
+	for i, c := range calls {
+		lines[i] = "\t" + c.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+type queryRowKey struct{}
+
+func (r *Runner) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return r.ExecContext(context.Background(), query, args...)
+}
+
+func (r *Runner) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return r.db.ExecContext(ctx, query, args...)
+}
+
+func (r *Runner) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return r.QueryContext(context.Background(), query, args...)
+}
+
+func (r *Runner) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return r.db.QueryContext(ctx, query, args...)
+}
+
+func (r *Runner) QueryRow(query string, args ...interface{}) sqrl.RowScanner {
+	return r.QueryRowContext(context.Background(), query, args...)
+}
+
+func (r *Runner) QueryRowContext(ctx context.Context, query string, args ...interface{}) sqrl.RowScanner {
+	return r.db.QueryRowContext(context.WithValue(ctx, queryRowKey{}, true), query, args...)
+}
+
+// Script is the scripted response to a statement.
+type Script struct {
+	sql    string
+	repeat bool
+
+	err          error
+	lastInsertId int64
+	rowsAffected int64
+	columns      []string
+	rows         [][]interface{}
+}
+
+// Result makes statements return a sql.Result with lastInsertId and
+// rowsAffected.
+func (s *Script) Result(lastInsertId, rowsAffected int64) *Script {
+	s.lastInsertId = lastInsertId
+	s.rowsAffected = rowsAffected
+	return s
+}
+
+// Rows makes statements return rows of columns. Values are converted as
+// database/sql drivers convert args, e.g. ints to int64.
+func (s *Script) Rows(columns []string, rows ...[]interface{}) *Script {
+	s.columns = columns
+	s.rows = rows
+	return s
+}
+
+// Error makes statements fail with err.
+func (s *Script) Error(err error) *Script {
+	s.err = err
+	return s
+}
+
+// Repeat makes the script answer every matching statement instead of the
+// first one only.
+func (s *Script) Repeat() *Script {
+	s.repeat = true
+	return s
+}
@@ -0,0 +1,164 @@
+package sqrltest
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/SharperShape/sqrl"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records the failures of assertions.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Helper() {}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRunner(t *testing.T) {
+	r := NewRunner()
+	defer r.Close()
+
+	r.On("SELECT name FROM users WHERE id = ?").Rows([]string{"name"}, []interface{}{"moe"})
+	r.On("INSERT INTO users (name) VALUES (?)").Result(7, 1)
+
+	var name string
+	err := sqrl.Select("name").From("users").Where(sqrl.Eq{"id": 1}).RunWith(r).QueryRow().Scan(&name)
+	assert.NoError(t, err)
+	assert.Equal(t, "moe", name)
+
+	res, err := sqrl.Insert("users").Columns("name").Values("larry").RunWith(r).Exec()
+	assert.NoError(t, err)
+	id, _ := res.LastInsertId()
+	affected, _ := res.RowsAffected()
+	assert.Equal(t, int64(7), id)
+	assert.Equal(t, int64(1), affected)
+
+	rows, err := r.QueryContext(context.Background(), "SELECT * FROM users")
+	assert.NoError(t, err)
+	assert.False(t, rows.Next(), "statements without script return no rows")
+	rows.Close()
+
+	err = r.QueryRowContext(context.Background(), "SELECT name FROM users WHERE id = ?", 1).Scan(&name)
+	assert.Equal(t, sql.ErrNoRows, err, "scripts are used once")
+
+	_, err = r.ExecContext(context.Background(), "DELETE FROM users")
+	assert.NoError(t, err)
+
+	r.AssertCalls(t,
+		QueryRow("SELECT name FROM users WHERE id = ?", 1),
+		Exec("INSERT INTO users (name) VALUES (?)", "larry"),
+		Query("SELECT * FROM users"),
+		QueryRow("SELECT name FROM users WHERE id = ?", 1),
+		Exec("DELETE  FROM\n users"))
+}
+
+func TestRunnerRows(t *testing.T) {
+	r := NewRunner()
+	defer r.Close()
+
+	r.On("").Repeat().Rows([]string{"id", "name", "admin"},
+		[]interface{}{1, "moe", true},
+		[]interface{}{int32(2), nil, false})
+
+	for i := 0; i < 2; i++ {
+		rows, err := r.Query("SELECT id, name, admin FROM users")
+		assert.NoError(t, err)
+
+		var ids []int
+		var names []sql.NullString
+		for rows.Next() {
+			var id int
+			var name sql.NullString
+			var admin bool
+			assert.NoError(t, rows.Scan(&id, &name, &admin))
+			ids = append(ids, id)
+			names = append(names, name)
+		}
+		assert.NoError(t, rows.Err())
+		rows.Close()
+
+		assert.Equal(t, []int{1, 2}, ids)
+		assert.Equal(t, []sql.NullString{{String: "moe", Valid: true}, {}}, names)
+	}
+}
+
+func TestRunnerError(t *testing.T) {
+	r := NewRunner()
+	defer r.Close()
+
+	boom := errors.New("boom")
+	r.On("UPDATE users SET name = ?").Error(boom).Repeat()
+
+	_, err := r.Exec("UPDATE users SET name = ?", "moe")
+	assert.Equal(t, boom, err)
+	_, err = r.Query("UPDATE users SET name = ?", "moe")
+	assert.Equal(t, boom, err)
+	err = r.QueryRow("UPDATE users SET name = ?", "moe").Scan()
+	assert.Equal(t, boom, err)
+
+	r.AssertCalls(t,
+		Exec("UPDATE users SET name = ?", "moe"),
+		Query("UPDATE users SET name = ?", "moe"),
+		QueryRow("UPDATE users SET name = ?", "moe"))
+}
+
+type valuer struct{}
+
+func (valuer) Value() (driver.Value, error) {
+	return "value", nil
+}
+
+func TestRunnerDB(t *testing.T) {
+	r := NewRunner()
+	defer r.Close()
+
+	tx, err := r.DB().Begin()
+	assert.NoError(t, err)
+	_, err = sqrl.Update("users").Set("name", []string{"not", "a", "driver", "value"}).RunWith(tx).Exec()
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+	_, err = r.DB().Exec("DELETE FROM users WHERE name = ?", valuer{})
+	assert.NoError(t, err)
+
+	r.AssertCalls(t,
+		Exec("UPDATE users SET name = ?", []string{"not", "a", "driver", "value"}),
+		Exec("DELETE FROM users WHERE name = ?", valuer{}))
+
+	r.Reset()
+	r.AssertNoCalls(t)
+}
+
+func TestAssertCalls(t *testing.T) {
+	r := NewRunner()
+	defer r.Close()
+
+	r.Exec("DELETE FROM a WHERE b = ?", 1)
+	r.Exec("DELETE FROM c")
+
+	rt := &recordingT{}
+	assert.True(t, r.AssertCalls(rt, Exec("DELETE FROM a WHERE b = ?", 1), Exec("DELETE FROM c")))
+	assert.True(t, r.AssertCallsInAnyOrder(rt, Exec("DELETE FROM c"), Exec("DELETE FROM a WHERE b = ?", 1)))
+	assert.Empty(t, rt.errors)
+
+	assert.False(t, r.AssertCalls(rt, Exec("DELETE FROM c"), Exec("DELETE FROM a WHERE b = ?", 1)))
+	assert.False(t, r.AssertCalls(rt, Exec("DELETE FROM a WHERE b = ?", 2), Exec("DELETE FROM c")))
+	assert.False(t, r.AssertCalls(rt, Query("DELETE FROM a WHERE b = ?", 1), Exec("DELETE FROM c")))
+	assert.False(t, r.AssertNoCalls(rt))
+	assert.Len(t, rt.errors, 4)
+
+	rt.errors = nil
+	assert.False(t, r.AssertCallsInAnyOrder(rt, Exec("DELETE FROM c"), Exec("DELETE FROM d")))
+	assert.Equal(t, []string{"unexpected calls\n" +
+		"missing:\n\tExec DELETE FROM d []\n" +
+		"unexpected:\n\tExec DELETE FROM a WHERE b = ? [1]"}, rt.errors)
+}
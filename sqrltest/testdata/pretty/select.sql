@@ -0,0 +1,21 @@
+SELECT
+  u.id,
+  u.name
+FROM
+  users u
+  JOIN emails e ON e.user_id = u.id
+WHERE
+  u.id IN (?, ?)
+  AND u.created_at > ?
+  AND u.name <> ?
+  AND e.address = ?
+  AND e.deleted_at IS NULL
+ORDER BY
+  u.name
+
+-- args:
+-- 1: 1
+-- 2: 2
+-- 3: time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+-- 4: &"it's"
+-- 5: []byte{0x78, 0xa, 0x79}
@@ -4,6 +4,11 @@ package sqrl
 type StatementBuilderType struct {
 	placeholderFormat PlaceholderFormat
 	runWith           BaseRunner
+	immutable         bool
+	tags              queryTags
+	interpolate       Dialect
+	rewriters         []statementRewriter
+	softDeletes       []softDelete
 }
 
 // Select returns a SelectBuilder for this StatementBuilder.
@@ -26,6 +31,20 @@ func (b StatementBuilderType) Delete(what ...string) *DeleteBuilder {
 	return NewDeleteBuilder(b).What(what...)
 }
 
+// Case returns a CaseBuilder for this StatementBuilder.
+func (b StatementBuilderType) Case(what ...interface{}) *CaseBuilder {
+	c := &CaseBuilder{immutable: b.immutable}
+
+	switch len(what) {
+	case 0:
+	case 1:
+		c = c.what(what[0])
+	default:
+		c = c.what(newPart(what[0], what[1:]...))
+	}
+	return c
+}
+
 // PlaceholderFormat sets the PlaceholderFormat field for any child builders.
 func (b StatementBuilderType) PlaceholderFormat(f PlaceholderFormat) StatementBuilderType {
 	b.placeholderFormat = f
@@ -33,8 +52,33 @@ func (b StatementBuilderType) PlaceholderFormat(f PlaceholderFormat) StatementBu
 }
 
 // RunWith sets the RunWith field for any child builders.
-func (b StatementBuilderType) RunWith(runner BaseRunner) StatementBuilderType {
-	b.runWith = wrapRunner(runner)
+func (b StatementBuilderType) RunWith(runner BaseRunnerContext) StatementBuilderType {
+	b.runWith = adaptRunner(runner)
+	return b
+}
+
+// Immutable makes child builders immutable, like the builders of squirrel.
+//
+// Every method of an immutable builder returns a modified copy and leaves the
+// receiver intact, so a base query can be shared between goroutines and
+// extended concurrently. Copies share unmodified clauses with their parent,
+// so deriving a query does not copy the whole statement.
+func (b StatementBuilderType) Immutable() StatementBuilderType {
+	b.immutable = true
+	return b
+}
+
+// Interpolate makes child builders inline args into the SQL returned by ToSql
+// as literals of dialect d, for connection poolers and proxies that don't
+// support prepared statements. See Interpolate.
+func (b StatementBuilderType) Interpolate(d Dialect) StatementBuilderType {
+	b.interpolate = d
+	return b
+}
+
+// Tag sets a sqlcommenter tag for any child builders, see SelectBuilder.Tag.
+func (b StatementBuilderType) Tag(key, value string) StatementBuilderType {
+	b.tags = b.tags.with(key, value)
 	return b
 }
 
@@ -73,15 +117,5 @@ func Delete(what ...string) *DeleteBuilder {
 // Case returns a new CaseBuilder
 // "what" represents case value
 func Case(what ...interface{}) *CaseBuilder {
-	b := &CaseBuilder{}
-
-	switch len(what) {
-	case 0:
-	case 1:
-		b = b.what(what[0])
-	default:
-		b = b.what(newPart(what[0], what[1:]...))
-
-	}
-	return b
+	return StatementBuilder.Case(what...)
 }
@@ -2,6 +2,8 @@ package sqrl
 
 import (
 	"database/sql"
+	"fmt"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -43,3 +45,207 @@ func TestRunWithTx(t *testing.T) {
 		Delete("t").RunWith(tx)
 	}, "RunWith(*sql.Tx) should not panic")
 }
+
+func TestImmutableSelectBuilder(t *testing.T) {
+	base := StatementBuilder.Immutable().Select("a").From("b").Where("c = ?", 1)
+
+	q1 := base.Where("d = ?", 2).OrderBy("a")
+	q2 := base.Where("e = ?", 3).PlaceholderFormat(Dollar)
+
+	sql, args, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c = ?", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, args, err = q1.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c = ? AND d = ? ORDER BY a", sql)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	sql, args, err = q2.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c = $1 AND e = $2", sql)
+	assert.Equal(t, []interface{}{1, 3}, args)
+}
+
+func TestImmutableInsertBuilder(t *testing.T) {
+	base := StatementBuilder.Immutable().Insert("a").Columns("b")
+
+	q1 := base.Values(1)
+	q2 := base.Values(2).Values(3)
+
+	_, _, err := base.ToSql()
+	assert.Error(t, err)
+
+	sql, args, err := q1.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO a (b) VALUES (?)", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, args, err = q2.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO a (b) VALUES (?),(?)", sql)
+	assert.Equal(t, []interface{}{2, 3}, args)
+}
+
+func TestImmutableUpdateBuilder(t *testing.T) {
+	base := StatementBuilder.Immutable().Update("a").Set("b", 1)
+
+	q1 := base.SetMap(map[string]interface{}{"c": 2, "d": 3})
+	q2 := base.Where("e = ?", 4).Returning("f")
+
+	sql, args, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE a SET b = ?", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, args, err = q1.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE a SET b = ?, c = ?, d = ?", sql)
+	assert.Equal(t, []interface{}{1, 2, 3}, args)
+
+	sql, args, err = q2.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE a SET b = ? WHERE e = ? RETURNING f", sql)
+	assert.Equal(t, []interface{}{1, 4}, args)
+}
+
+func TestImmutableDeleteBuilder(t *testing.T) {
+	base := StatementBuilder.Immutable().Delete("a").Where("b = ?", 1)
+
+	q1 := base.Where("c = ?", 2)
+	q2 := base.What("a", "d").Join("d ON a.id = d.id")
+
+	sql, args, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM a WHERE b = ?", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, args, err = q1.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM a WHERE b = ? AND c = ?", sql)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	sql, _, err = q2.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE a, d FROM a JOIN d ON a.id = d.id WHERE b = ?", sql)
+}
+
+func sqlOf(t *testing.T, s Sqlizer) string {
+	sql, _, err := s.ToSql()
+	assert.NoError(t, err)
+	return sql
+}
+
+// testConcurrentDerive derives 16 queries from a shared base concurrently
+// with derive and checks their SQL.
+func testConcurrentDerive(t *testing.T, derive func(i int) Sqlizer, sql func(i int) string) {
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			s, _, err := derive(i).ToSql()
+			assert.NoError(t, err)
+			assert.Equal(t, sql(i), s)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestImmutableConcurrentDerive is meant to be run with the race detector:
+// go test -race
+func TestImmutableConcurrentDerive(t *testing.T) {
+	sb := StatementBuilder.Immutable()
+
+	t.Run("select", func(t *testing.T) {
+		base := sb.Select("a", "b").From("c").Join("d ON c.id = d.c_id").Where(Eq{"tenant_id": 1})
+		testConcurrentDerive(t, func(i int) Sqlizer {
+			return base.Where("e = ?", i).OrderBy("a").Limit(uint64(i))
+		}, func(i int) string {
+			return fmt.Sprintf("SELECT a, b FROM c JOIN d ON c.id = d.c_id WHERE tenant_id = ? AND e = ? ORDER BY a LIMIT %d", i)
+		})
+		assert.Equal(t, "SELECT a, b FROM c JOIN d ON c.id = d.c_id WHERE tenant_id = ?", sqlOf(t, base))
+	})
+
+	t.Run("insert", func(t *testing.T) {
+		base := sb.Insert("a").Columns("b", "c").Values(1, 2)
+		testConcurrentDerive(t, func(i int) Sqlizer {
+			return base.Values(i, i).Suffix(fmt.Sprintf("RETURNING x%d", i))
+		}, func(i int) string {
+			return fmt.Sprintf("INSERT INTO a (b,c) VALUES (?,?),(?,?) RETURNING x%d", i)
+		})
+		assert.Equal(t, "INSERT INTO a (b,c) VALUES (?,?)", sqlOf(t, base))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		base := sb.Update("a").Set("b", 1).Where(Eq{"tenant_id": 1})
+		testConcurrentDerive(t, func(i int) Sqlizer {
+			return base.Set(fmt.Sprintf("c%d", i), i).Where("d = ?", i).Limit(uint64(i))
+		}, func(i int) string {
+			return fmt.Sprintf("UPDATE a SET b = ?, c%d = ? WHERE tenant_id = ? AND d = ? LIMIT %d", i, i)
+		})
+		assert.Equal(t, "UPDATE a SET b = ? WHERE tenant_id = ?", sqlOf(t, base))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		base := sb.Delete("a").Where(Eq{"tenant_id": 1})
+		testConcurrentDerive(t, func(i int) Sqlizer {
+			return base.Where("b = ?", i).OrderBy(fmt.Sprintf("c%d", i))
+		}, func(i int) string {
+			return fmt.Sprintf("DELETE FROM a WHERE tenant_id = ? AND b = ? ORDER BY c%d", i)
+		})
+		assert.Equal(t, "DELETE FROM a WHERE tenant_id = ?", sqlOf(t, base))
+	})
+
+	t.Run("case", func(t *testing.T) {
+		base := sb.Case("a").When("1", "'one'")
+		testConcurrentDerive(t, func(i int) Sqlizer {
+			return base.When(fmt.Sprint(i+2), "'more'").Else(fmt.Sprint(i))
+		}, func(i int) string {
+			return fmt.Sprintf("CASE a WHEN 1 THEN 'one' WHEN %d THEN 'more' ELSE %d END", i+2, i)
+		})
+		assert.Equal(t, "CASE a WHEN 1 THEN 'one' END", sqlOf(t, base))
+	})
+}
+
+func benchmarkDerive(b *testing.B, sb StatementBuilderType) {
+	base := sb.Select("a", "b").From("c").Where(Eq{"tenant_id": 1})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		q := base
+		if !sb.immutable {
+			q = base.Clone()
+		}
+		q.Where("d = ?", i).OrderBy("a").Limit(10).ToSql()
+	}
+}
+
+func BenchmarkDeriveMutableClone(b *testing.B) {
+	benchmarkDerive(b, StatementBuilder)
+}
+
+func BenchmarkDeriveImmutable(b *testing.B) {
+	benchmarkDerive(b, StatementBuilder.Immutable())
+}
+
+func benchmarkBuild(b *testing.B, sb StatementBuilderType) {
+	for i := 0; i < b.N; i++ {
+		sb.Select("a", "b").
+			From("c").
+			Join("d ON c.id = d.c_id").
+			Where(Eq{"tenant_id": 1}).
+			Where("d = ?", i).
+			OrderBy("a").
+			Limit(10).
+			ToSql()
+	}
+}
+
+func BenchmarkBuildMutable(b *testing.B) {
+	benchmarkBuild(b, StatementBuilder)
+}
+
+func BenchmarkBuildImmutable(b *testing.B) {
+	benchmarkBuild(b, StatementBuilder.Immutable())
+}
@@ -1,8 +1,11 @@
 package sqrl
 
 import (
+	"container/list"
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"sync"
 )
 
@@ -26,55 +29,403 @@ type DBProxy interface {
 	QueryRowerContext
 }
 
+// StmtCache is a DBProxy that caches prepared statements.
+type StmtCache interface {
+	DBProxy
+
+	// Clear closes and removes all cached statements. The cache stays usable.
+	Clear() error
+
+	// Close closes all cached statements. Any further use of the cache
+	// returns ErrStmtCacheClosed.
+	Close() error
+
+	// Stats returns a snapshot of the cache statistics.
+	Stats() StmtCacheStats
+
+	// Invalidate closes and removes the statements of query, including those
+	// of Templates with the same SQL. They are prepared again on next use.
+	Invalidate(query string)
+
+	// InvalidateAll closes and removes all cached statements, e.g. from a
+	// deployment hook after a migration. It is Clear without the error.
+	InvalidateAll()
+}
+
+// ErrStmtCacheClosed is returned by a StmtCache that has been closed.
+var ErrStmtCacheClosed = fmt.Errorf("statement cache is closed")
+
+// StmtCacheEvent is the kind of an event reported to a StmtCacheObserver.
+type StmtCacheEvent int
+
+const (
+	// StmtCacheHit is reported when a prepared statement is found in the cache.
+	StmtCacheHit StmtCacheEvent = iota
+	// StmtCacheMiss is reported when a statement has to be prepared.
+	StmtCacheMiss
+	// StmtCacheEviction is reported when a statement is closed and removed
+	// from the cache to make room for another one.
+	StmtCacheEviction
+)
+
+func (e StmtCacheEvent) String() string {
+	switch e {
+	case StmtCacheHit:
+		return "hit"
+	case StmtCacheMiss:
+		return "miss"
+	case StmtCacheEviction:
+		return "eviction"
+	default:
+		return fmt.Sprintf("StmtCacheEvent(%d)", int(e))
+	}
+}
+
+// StmtCacheStats holds statistics of a StmtCache.
+type StmtCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+
+	// Size is the number of statements in the cache.
+	Size int
+	// Capacity is the maximum number of statements in the cache, 0 means
+	// unlimited.
+	Capacity int
+}
+
+// StmtCacheOption configures a StmtCache.
+type StmtCacheOption func(*stmtCacher)
+
+// StmtCacheCapacity limits the number of prepared statements held by the
+// cache. When the limit is reached the least recently used statement is
+// closed and evicted. The default capacity 0 means unlimited.
+func StmtCacheCapacity(capacity int) StmtCacheOption {
+	return func(sc *stmtCacher) {
+		sc.capacity = capacity
+	}
+}
+
+// StmtCacheObserver registers a function that is called for every hit, miss
+// and eviction with the SQL of the affected statement, e.g. to feed metrics.
+//
+// The function is called synchronously, but never while the cache is locked.
+func StmtCacheObserver(observer func(event StmtCacheEvent, query string)) StmtCacheOption {
+	return func(sc *stmtCacher) {
+		sc.observer = observer
+	}
+}
+
+// stmtCacheEntry is a prepared statement held by the cache. Entries are keyed
+// by SQL string or by *Template.
+type stmtCacheEntry struct {
+	key   interface{}
+	query string
+	stmt  *sql.Stmt
+
+	// refs counts executions in progress, an evicted statement is closed
+	// once its last execution finishes.
+	refs    int
+	evicted bool
+}
+
+type stmtCacheNotification struct {
+	event StmtCacheEvent
+	query string
+}
+
 type stmtCacher struct {
-	prep  Preparer
-	cache map[string]*sql.Stmt
-	mu    sync.Mutex
+	prep     Preparer
+	capacity int
+	observer func(event StmtCacheEvent, query string)
+
+	mu     sync.Mutex
+	cache  map[interface{}]*list.Element
+	lru    *list.List
+	stats  StmtCacheStats
+	closed bool
 }
 
-// NewStmtCacher returns a DBProxy wrapping prep that caches Prepared Stmts.
+// NewStmtCacher returns a StmtCache wrapping prep that caches Prepared Stmts.
+//
+// Stmts are cached based on the string value of their queries. Stmts of
+// compiled Templates executed with the proxy are cached based on the Template
+// pointer, so their SQL is never hashed.
+//
+// A statement that the database refuses to execute because the schema changed
+// since it was prepared (Postgres "cached plan must not change result type",
+// MySQL "prepared statement needs to be re-prepared") is evicted, prepared
+// again and retried once. Inside transactions it is only evicted, as the
+// failed statement may have aborted the transaction.
 //
-// Stmts are cached based on the string value of their queries.
-func NewStmtCacher(prep Preparer) DBProxy {
-	return &stmtCacher{prep: prep, cache: make(map[string]*sql.Stmt)}
+// Stmts returned by Prepare are owned by the cache: they must not be closed
+// by the caller, and may be closed by the cache when they are evicted.
+func NewStmtCacher(prep Preparer, opts ...StmtCacheOption) StmtCache {
+	return newStmtCacher(prep, opts...)
 }
 
-func (sc *stmtCacher) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+func newStmtCacher(prep Preparer, opts ...StmtCacheOption) *stmtCacher {
+	sc := &stmtCacher{
+		prep:  prep,
+		cache: make(map[interface{}]*list.Element),
+		lru:   list.New(),
+	}
+	for _, opt := range opts {
+		opt(sc)
+	}
+	return sc
+}
+
+// acquire returns the cache entry for key, preparing query if needed. The
+// entry must be released after use.
+func (sc *stmtCacher) acquire(ctx context.Context, key interface{}, query string) (*stmtCacheEntry, error) {
+	return sc.lookup(ctx, key, query, true)
+}
+
+// lookup returns the cache entry for key like acquire. If the entry is
+// missing and prepare is false, it returns a nil entry instead of preparing
+// query.
+func (sc *stmtCacher) lookup(ctx context.Context, key interface{}, query string, prepare bool) (*stmtCacheEntry, error) {
+	var notifications []stmtCacheNotification
+	defer func() {
+		sc.notify(notifications)
+	}()
+
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
-	stmt, ok := sc.cache[query]
-	if ok {
-		return stmt, nil
+
+	if sc.closed {
+		return nil, ErrStmtCacheClosed
+	}
+
+	if el, ok := sc.cache[key]; ok {
+		sc.lru.MoveToFront(el)
+		sc.stats.Hits++
+		notifications = append(notifications, stmtCacheNotification{StmtCacheHit, query})
+
+		e := el.Value.(*stmtCacheEntry)
+		e.refs++
+		return e, nil
+	}
+
+	sc.stats.Misses++
+	notifications = append(notifications, stmtCacheNotification{StmtCacheMiss, query})
+	if !prepare {
+		return nil, nil
 	}
+
 	stmt, err := sc.prep.PrepareContext(ctx, query)
-	if err == nil {
-		sc.cache[query] = stmt
+	if err != nil {
+		return nil, err
 	}
-	return stmt, err
+
+	e := &stmtCacheEntry{key: key, query: query, stmt: stmt, refs: 1}
+	sc.cache[key] = sc.lru.PushFront(e)
+
+	for sc.capacity > 0 && sc.lru.Len() > sc.capacity {
+		evicted := sc.removeElement(sc.lru.Back())
+		sc.stats.Evictions++
+		notifications = append(notifications, stmtCacheNotification{StmtCacheEviction, evicted.query})
+	}
+	return e, nil
 }
 
-func (sc *stmtCacher) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
-	stmt, err := sc.PrepareContext(ctx, query)
-	if err != nil {
+// release marks an execution of e as finished.
+func (sc *stmtCacher) release(e *stmtCacheEntry) {
+	sc.mu.Lock()
+	e.refs--
+	closeStmt := e.evicted && e.refs == 0
+	sc.mu.Unlock()
+
+	if closeStmt {
+		closeCachedStmt(e.stmt)
+	}
+}
+
+// removeElement removes el from the cache and closes its statement unless it
+// is in use. sc.mu must be held.
+func (sc *stmtCacher) removeElement(el *list.Element) *stmtCacheEntry {
+	e := sc.lru.Remove(el).(*stmtCacheEntry)
+	delete(sc.cache, e.key)
+	e.evicted = true
+	if e.refs == 0 {
+		closeCachedStmt(e.stmt)
+	}
+	return e
+}
+
+// removeAll removes all statements from the cache, sc.mu must be held.
+func (sc *stmtCacher) removeAll() error {
+	var firstErr error
+	for el := sc.lru.Front(); el != nil; el = sc.lru.Front() {
+		e := sc.lru.Remove(el).(*stmtCacheEntry)
+		delete(sc.cache, e.key)
+		e.evicted = true
+		if e.refs == 0 {
+			if err := closeCachedStmt(e.stmt); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// invalidate removes e from the cache, unless it has already been replaced.
+func (sc *stmtCacher) invalidate(e *stmtCacheEntry) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if el, ok := sc.cache[e.key]; ok && el.Value.(*stmtCacheEntry) == e {
+		sc.removeElement(el)
+	}
+}
+
+func closeCachedStmt(stmt *sql.Stmt) error {
+	if stmt == nil {
+		return nil
+	}
+	return stmt.Close()
+}
+
+func (sc *stmtCacher) notify(notifications []stmtCacheNotification) {
+	if sc.observer == nil {
 		return
 	}
-	return stmt.ExecContext(ctx, args...)
+	for _, n := range notifications {
+		sc.observer(n.event, n.query)
+	}
 }
 
-func (sc *stmtCacher) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
-	stmt, err := sc.PrepareContext(ctx, query)
+func (sc *stmtCacher) Clear() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.removeAll()
+}
+
+func (sc *stmtCacher) Close() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.closed = true
+	return sc.removeAll()
+}
+
+func (sc *stmtCacher) Invalidate(query string) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	for el := sc.lru.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*stmtCacheEntry).query == query {
+			sc.removeElement(el)
+		}
+		el = next
+	}
+}
+
+func (sc *stmtCacher) InvalidateAll() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.removeAll()
+}
+
+func (sc *stmtCacher) Stats() StmtCacheStats {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	stats := sc.stats
+	stats.Size = sc.lru.Len()
+	stats.Capacity = sc.capacity
+	return stats
+}
+
+func (sc *stmtCacher) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	e, err := sc.acquire(ctx, query, query)
 	if err != nil {
-		return
+		return nil, err
 	}
-	return stmt.QueryContext(ctx, args...)
+	sc.release(e)
+	return e.stmt, nil
+}
+
+func (sc *stmtCacher) ExecContext(ctx context.Context, query string, args ...interface{}) (res sql.Result, err error) {
+	return sc.exec(ctx, query, query, args)
+}
+
+func (sc *stmtCacher) QueryContext(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, err error) {
+	return sc.query(ctx, query, query, args)
 }
 
 func (sc *stmtCacher) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
-	stmt, err := sc.PrepareContext(ctx, query)
+	return sc.queryRow(ctx, query, query, args)
+}
+
+func (sc *stmtCacher) execTemplate(ctx context.Context, t *Template, args []interface{}) (sql.Result, error) {
+	return sc.exec(ctx, t, t.sql, args)
+}
+
+func (sc *stmtCacher) queryTemplate(ctx context.Context, t *Template, args []interface{}) (*sql.Rows, error) {
+	return sc.query(ctx, t, t.sql, args)
+}
+
+func (sc *stmtCacher) queryRowTemplate(ctx context.Context, t *Template, args []interface{}) RowScanner {
+	return sc.queryRow(ctx, t, t.sql, args)
+}
+
+// run calls fn with the cached statement of key. If the statement has been
+// invalidated by a schema change, fn is called once more with a freshly
+// prepared statement.
+func (sc *stmtCacher) run(ctx context.Context, key interface{}, query string, fn func(stmt *sql.Stmt) error) error {
+	for retried := false; ; retried = true {
+		e, err := sc.acquire(ctx, key, query)
+		if err != nil {
+			return err
+		}
+		err = fn(e.stmt)
+		sc.release(e)
+
+		if retried || !isStaleStmtError(err) {
+			return err
+		}
+		sc.invalidate(e)
+	}
+}
+
+func (sc *stmtCacher) exec(ctx context.Context, key interface{}, query string, args []interface{}) (res sql.Result, err error) {
+	err = sc.run(ctx, key, query, func(stmt *sql.Stmt) error {
+		res, err = stmt.ExecContext(ctx, args...)
+		return err
+	})
+	return res, err
+}
+
+func (sc *stmtCacher) query(ctx context.Context, key interface{}, query string, args []interface{}) (rows *sql.Rows, err error) {
+	err = sc.run(ctx, key, query, func(stmt *sql.Stmt) error {
+		rows, err = stmt.QueryContext(ctx, args...)
+		return err
+	})
+	return rows, err
+}
+
+// queryRow runs the query with QueryContext rather than QueryRowContext, so
+// that an invalidated statement is detected before the row is scanned.
+func (sc *stmtCacher) queryRow(ctx context.Context, key interface{}, query string, args []interface{}) RowScanner {
+	rows, err := sc.query(ctx, key, query, args)
 	if err != nil {
 		return &Row{err: err}
 	}
-	return stmt.QueryRowContext(ctx, args...)
+	return &rowsScanner{rows: rows}
+}
+
+// isStaleStmtError reports whether err is returned by the database for a
+// prepared statement that must be prepared again after a schema change.
+//
+// Errors are recognised by message, so that sqrl doesn't depend on drivers.
+func isStaleStmtError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "cached plan must not change result type") ||
+		strings.Contains(msg, "prepared statement needs to be re-prepared")
 }
 
 func (sc *stmtCacher) Prepare(query string) (*sql.Stmt, error) {
@@ -95,20 +446,202 @@ func (sc *stmtCacher) QueryRow(query string, args ...interface{}) RowScanner {
 
 // DBProxyBeginner describes a DBProxy that can start transactions
 type DBProxyBeginner interface {
-	DBProxy
+	StmtCache
 	Begin() (*sql.Tx, error)
+
+	// BeginTx starts a transaction that runs queries with the cached
+	// statements. The statements are bound to the transaction when they are
+	// first used in it, and closed when it is committed or rolled back.
+	//
+	// Statements missing from the cache are prepared in the transaction and
+	// are not added to the cache, so the transaction never needs a second
+	// connection from the pool.
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (TxRunner, error)
 }
 
 type stmtCacheProxy struct {
-	DBProxy
+	*stmtCacher
 	db *sql.DB
 }
 
 // NewStmtCacheProxy creates new cache proxy for statements
-func NewStmtCacheProxy(db *sql.DB) DBProxyBeginner {
-	return &stmtCacheProxy{DBProxy: NewStmtCacher(db), db: db}
+func NewStmtCacheProxy(db *sql.DB, opts ...StmtCacheOption) DBProxyBeginner {
+	return &stmtCacheProxy{stmtCacher: newStmtCacher(db, opts...), db: db}
 }
 
 func (sp *stmtCacheProxy) Begin() (*sql.Tx, error) {
 	return sp.db.Begin()
 }
+
+func (sp *stmtCacheProxy) BeginTx(ctx context.Context, opts *sql.TxOptions) (TxRunner, error) {
+	tx, err := sp.db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &stmtCacheTx{
+		sc:      sp.stmtCacher,
+		tx:      tx,
+		stmts:   make(map[interface{}]*sql.Stmt),
+		entries: make(map[interface{}]*stmtCacheEntry),
+	}, nil
+}
+
+// TxRunner is a Runner bound to a transaction.
+type TxRunner interface {
+	Runner
+	Commit() error
+	Rollback() error
+}
+
+// stmtCacheTx runs queries of a transaction with statements of a stmtCacher.
+//
+// Cached statements are bound to the transaction with Tx.StmtContext once per
+// transaction, missing ones are prepared with Tx.PrepareContext. The cache entries are released as soon as they are bound:
+// database/sql keeps the prepared statement of an evicted entry open until
+// the statements bound to it are closed, so a transaction that is never
+// ended doesn't hold cache entries.
+type stmtCacheTx struct {
+	sc *stmtCacher
+	tx *sql.Tx
+
+	mu      sync.Mutex
+	stmts   map[interface{}]*sql.Stmt
+	entries map[interface{}]*stmtCacheEntry
+	done    bool
+}
+
+func (t *stmtCacheTx) stmt(ctx context.Context, key interface{}, query string) (*sql.Stmt, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.done {
+		return nil, sql.ErrTxDone
+	}
+	if stmt, ok := t.stmts[key]; ok {
+		return stmt, nil
+	}
+
+	e, err := t.sc.lookup(ctx, key, query, false)
+	if err != nil {
+		return nil, err
+	}
+	var stmt *sql.Stmt
+	if e != nil {
+		stmt = t.tx.StmtContext(ctx, e.stmt)
+		t.sc.release(e)
+		t.entries[key] = e
+	} else {
+		// Preparing through the cache would take a second connection from
+		// the pool while t.mu is held.
+		stmt, err = t.tx.PrepareContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+	t.stmts[key] = stmt
+	return stmt, nil
+}
+
+// checkErr evicts the statement of key from the cache if err tells that it
+// is stale. The transaction keeps using it, as it is likely aborted anyway.
+func (t *stmtCacheTx) checkErr(key interface{}, err error) error {
+	if isStaleStmtError(err) {
+		t.mu.Lock()
+		e := t.entries[key]
+		t.mu.Unlock()
+		if e != nil {
+			t.sc.invalidate(e)
+		}
+	}
+	return err
+}
+
+// cleanup closes the transaction's statements. Any further use of the
+// transaction returns sql.ErrTxDone.
+func (t *stmtCacheTx) cleanup() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.done = true
+	for key, stmt := range t.stmts {
+		stmt.Close()
+		delete(t.stmts, key)
+	}
+	for key := range t.entries {
+		delete(t.entries, key)
+	}
+}
+
+func (t *stmtCacheTx) Commit() error {
+	err := t.tx.Commit()
+	t.cleanup()
+	return err
+}
+
+func (t *stmtCacheTx) Rollback() error {
+	err := t.tx.Rollback()
+	t.cleanup()
+	return err
+}
+
+func (t *stmtCacheTx) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return t.exec(ctx, query, query, args)
+}
+
+func (t *stmtCacheTx) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	return t.query(ctx, query, query, args)
+}
+
+func (t *stmtCacheTx) QueryRowContext(ctx context.Context, query string, args ...interface{}) RowScanner {
+	return t.queryRow(ctx, query, query, args)
+}
+
+func (t *stmtCacheTx) execTemplate(ctx context.Context, tpl *Template, args []interface{}) (sql.Result, error) {
+	return t.exec(ctx, tpl, tpl.sql, args)
+}
+
+func (t *stmtCacheTx) queryTemplate(ctx context.Context, tpl *Template, args []interface{}) (*sql.Rows, error) {
+	return t.query(ctx, tpl, tpl.sql, args)
+}
+
+func (t *stmtCacheTx) queryRowTemplate(ctx context.Context, tpl *Template, args []interface{}) RowScanner {
+	return t.queryRow(ctx, tpl, tpl.sql, args)
+}
+
+func (t *stmtCacheTx) exec(ctx context.Context, key interface{}, query string, args []interface{}) (sql.Result, error) {
+	stmt, err := t.stmt(ctx, key, query)
+	if err != nil {
+		return nil, err
+	}
+	res, err := stmt.ExecContext(ctx, args...)
+	return res, t.checkErr(key, err)
+}
+
+func (t *stmtCacheTx) query(ctx context.Context, key interface{}, query string, args []interface{}) (*sql.Rows, error) {
+	stmt, err := t.stmt(ctx, key, query)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := stmt.QueryContext(ctx, args...)
+	return rows, t.checkErr(key, err)
+}
+
+func (t *stmtCacheTx) queryRow(ctx context.Context, key interface{}, query string, args []interface{}) RowScanner {
+	rows, err := t.query(ctx, key, query, args)
+	if err != nil {
+		return &Row{err: err}
+	}
+	return &rowsScanner{rows: rows}
+}
+
+func (t *stmtCacheTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
+
+func (t *stmtCacheTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+func (t *stmtCacheTx) QueryRow(query string, args ...interface{}) RowScanner {
+	return t.QueryRowContext(context.Background(), query, args...)
+}
@@ -1,6 +1,10 @@
 package sqrl
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -17,3 +21,311 @@ func TestStmtCacherPrepare(t *testing.T) {
 	sc.Prepare(query)
 	assert.Equal(t, 1, db.PrepareCount, "expected 1 Prepare, got %d", db.PrepareCount)
 }
+
+func TestStmtCacherCapacity(t *testing.T) {
+	db, fake := newFakeDB()
+
+	var events []string
+	sc := NewStmtCacher(db,
+		StmtCacheCapacity(2),
+		StmtCacheObserver(func(event StmtCacheEvent, query string) {
+			events = append(events, event.String()+" "+query)
+		}))
+
+	for _, query := range []string{"SELECT 1", "SELECT 2", "SELECT 1", "SELECT 3", "SELECT 2"} {
+		_, err := sc.Exec(query)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, []string{
+		"miss SELECT 1",
+		"miss SELECT 2",
+		"hit SELECT 1",
+		"miss SELECT 3",
+		"eviction SELECT 2",
+		"miss SELECT 2",
+		"eviction SELECT 1",
+	}, events)
+
+	assert.Equal(t, StmtCacheStats{Hits: 1, Misses: 4, Evictions: 2, Size: 2, Capacity: 2}, sc.Stats())
+	assert.Equal(t, 1, fake.Count("close SELECT 1"))
+	assert.Equal(t, 1, fake.Count("close SELECT 2"))
+	assert.Equal(t, 0, fake.Count("close SELECT 3"))
+}
+
+func TestStmtCacherEvictionWaitsForRelease(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := newStmtCacher(db, StmtCacheCapacity(1))
+
+	e, err := sc.acquire(context.Background(), "SELECT 1", "SELECT 1")
+	assert.NoError(t, err)
+
+	_, err = sc.Exec("SELECT 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fake.Count("close SELECT 1"), "statement in use must not be closed")
+
+	sc.release(e)
+	assert.Equal(t, 1, fake.Count("close SELECT 1"))
+}
+
+func TestStmtCacherClear(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := NewStmtCacher(db)
+
+	sc.Exec("SELECT 1")
+	sc.Exec("SELECT 2")
+	assert.NoError(t, sc.Clear())
+	assert.Equal(t, 2, fake.Count("close "))
+	assert.Equal(t, 0, sc.Stats().Size)
+
+	_, err := sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, fake.Count("prepare SELECT 1"))
+}
+
+func TestStmtCacherClose(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := NewStmtCacheProxy(db)
+
+	sc.Exec("SELECT 1")
+	assert.NoError(t, sc.Close())
+	assert.Equal(t, 1, fake.Count("close SELECT 1"))
+
+	_, err := sc.Exec("SELECT 1")
+	assert.Equal(t, ErrStmtCacheClosed, err)
+
+	_, err = sc.Prepare("SELECT 1")
+	assert.Equal(t, ErrStmtCacheClosed, err)
+
+	err = sc.QueryRow("SELECT 1").Scan()
+	assert.Equal(t, ErrStmtCacheClosed, err)
+}
+
+func TestStmtCacherTemplateEviction(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := NewStmtCacher(db, StmtCacheCapacity(1))
+	tpl := MustCompile(Select("a").From("b").Where("c = ?", Param("c")))
+
+	_, err := tpl.ExecContext(context.Background(), sc, map[string]interface{}{"c": 1})
+	assert.NoError(t, err)
+	_, err = sc.Exec("SELECT a FROM b WHERE c = ?", 2)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, fake.Count("prepare SELECT a FROM b WHERE c = ?"), "templates are cached by pointer")
+	assert.Equal(t, 1, fake.Count("close SELECT a FROM b WHERE c = ?"))
+	assert.Equal(t, uint64(1), sc.Stats().Evictions)
+}
+
+func TestStmtCacheProxyBeginTx(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := NewStmtCacheProxy(db)
+
+	_, err := sc.Exec("UPDATE a SET b = ?", 1)
+	assert.NoError(t, err)
+
+	tx, err := sc.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelSerializable})
+	assert.NoError(t, err)
+
+	var runner BaseRunner = tx
+	_, ok := runner.(QueryRowerContext)
+	assert.True(t, ok, "TxRunner must be usable with QueryRow")
+
+	_, err = Update("a").Set("b", 2).RunWith(tx).Exec()
+	assert.NoError(t, err)
+	_, err = Update("a").Set("b", 3).RunWith(tx).ExecContext(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, 1, fake.Count("prepare UPDATE a SET b = ?"), "transaction must reuse the cached statement")
+	assert.Equal(t, 3, fake.Count("exec UPDATE a SET b = ?"))
+	assert.Equal(t, 1, fake.Count("begin Serializable"))
+	assert.Equal(t, 1, fake.Count("commit"))
+	assert.Equal(t, uint64(1), sc.Stats().Hits)
+}
+
+func TestStmtCacheTxReleasesStatements(t *testing.T) {
+	db, fake := newFakeDB()
+	// The cache is used outside of the open transaction.
+	db.SetMaxOpenConns(2)
+	sc := NewStmtCacheProxy(db, StmtCacheCapacity(1))
+
+	_, err := sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	tx, err := sc.BeginTx(context.Background(), nil)
+	assert.NoError(t, err)
+
+	_, err = tx.Exec("SELECT 1")
+	assert.NoError(t, err)
+
+	// Evict the statement used by the transaction.
+	_, err = sc.Exec("SELECT 2")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, fake.Count("close SELECT 1"), "statement used by a transaction must not be closed")
+
+	_, err = tx.Exec("SELECT 1")
+	assert.NoError(t, err)
+
+	assert.NoError(t, tx.Rollback())
+	assert.Equal(t, 1, fake.Count("rollback"))
+	assert.NotZero(t, fake.Count("close SELECT 1"))
+
+	_, err = tx.Exec("SELECT 3")
+	assert.Equal(t, sql.ErrTxDone, err)
+	assert.Equal(t, 0, fake.Count("prepare SELECT 3"))
+}
+
+func TestStmtCacheTxNotEnded(t *testing.T) {
+	db, _ := newFakeDB()
+	// The cache is used outside of the open transaction.
+	db.SetMaxOpenConns(2)
+	sc := NewStmtCacheProxy(db, StmtCacheCapacity(1))
+
+	_, err := sc.Exec("SELECT 1")
+	assert.NoError(t, err)
+	e := sc.(*stmtCacheProxy).cache["SELECT 1"].Value.(*stmtCacheEntry)
+	tx, err := sc.BeginTx(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = tx.Exec("SELECT 1")
+	assert.NoError(t, err)
+
+	// The transaction doesn't pin the entry, so eviction closes the cached
+	// statement although the transaction is never ended.
+	_, err = sc.Exec("SELECT 2")
+	assert.NoError(t, err)
+	assert.True(t, e.evicted)
+	assert.Equal(t, 0, e.refs)
+}
+
+func TestStmtCacheTxTemplate(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := NewStmtCacheProxy(db)
+	tpl := MustCompile(Delete("a").Where("b = ?", Param("b")))
+
+	tx, err := sc.BeginTx(context.Background(), nil)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = tpl.ExecContext(context.Background(), tx, map[string]interface{}{"b": i})
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, uint64(1), sc.Stats().Misses)
+	assert.Equal(t, 1, fake.Count("prepare DELETE FROM a WHERE b = ?"))
+	assert.Equal(t, 2, fake.Count("exec DELETE FROM a WHERE b = ?"))
+}
+
+func TestStmtCacheTxPreparesMissesInTx(t *testing.T) {
+	// newFakeDB allows a single open connection, which the transaction holds.
+	db, fake := newFakeDB()
+	sc := NewStmtCacheProxy(db)
+
+	tx, err := sc.BeginTx(context.Background(), nil)
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = tx.Exec("UPDATE a SET b = ?", i)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, 1, fake.Count("prepare UPDATE a SET b = ?"))
+	assert.Equal(t, 1, fake.Count("close UPDATE a SET b = ?"), "statement prepared in the transaction must be closed with it")
+	assert.Equal(t, 0, sc.Stats().Size, "statement prepared in the transaction must not be cached")
+
+	_, err = sc.Exec("UPDATE a SET b = ?", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sc.Stats().Size)
+}
+
+// staleOnce makes the statements prepared first for every query fail like
+// after a schema change.
+func staleOnce(fake *fakeDB, msg string) {
+	prepared := map[string]int{}
+	fake.prepareErr = func(query string) error {
+		prepared[query]++
+		return nil
+	}
+	fake.execErr = func(query string) error {
+		if prepared[query] == 1 {
+			return errors.New(msg)
+		}
+		return nil
+	}
+}
+
+func TestStmtCacherRetriesStaleStmt(t *testing.T) {
+	for _, msg := range []string{
+		"pq: cached plan must not change result type",
+		"Error 1615: Prepared statement needs to be re-prepared",
+	} {
+		db, fake := newFakeDB()
+		fake.columns = []string{"a"}
+		fake.rows = [][]driver.Value{{int64(1)}}
+		staleOnce(fake, msg)
+		sc := NewStmtCacher(db)
+
+		_, err := sc.Exec("UPDATE a SET b = 1")
+		assert.NoError(t, err, msg)
+		assert.Equal(t, 2, fake.Count("prepare UPDATE a SET b = 1"))
+		assert.Equal(t, 1, fake.Count("close UPDATE a SET b = 1"))
+
+		var a int
+		err = sc.QueryRow("SELECT a FROM b").Scan(&a)
+		assert.NoError(t, err, msg)
+		assert.Equal(t, 1, a)
+		assert.Equal(t, 2, fake.Count("prepare SELECT a FROM b"))
+
+		assert.Equal(t, 2, sc.Stats().Size)
+	}
+}
+
+func TestStmtCacherRetriesOnce(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.execErr = func(query string) error {
+		return errors.New("pq: cached plan must not change result type")
+	}
+	sc := NewStmtCacher(db)
+
+	_, err := sc.Exec("SELECT 1")
+	assert.EqualError(t, err, "pq: cached plan must not change result type")
+	assert.Equal(t, 2, fake.Count("prepare SELECT 1"))
+}
+
+func TestStmtCacheTxEvictsStaleStmt(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.execErr = func(query string) error {
+		return errors.New("pq: cached plan must not change result type")
+	}
+	sc := NewStmtCacheProxy(db)
+
+	_, err := sc.Prepare("SELECT 1")
+	assert.NoError(t, err)
+	tx, err := sc.BeginTx(context.Background(), nil)
+	assert.NoError(t, err)
+	_, err = tx.Exec("SELECT 1")
+	assert.Error(t, err, "statements are not retried in transactions")
+	assert.Equal(t, uint64(1), sc.Stats().Hits)
+	assert.NoError(t, tx.Rollback())
+	assert.Equal(t, 0, sc.Stats().Size)
+}
+
+func TestStmtCacherInvalidate(t *testing.T) {
+	db, fake := newFakeDB()
+	sc := NewStmtCacher(db)
+	tpl := MustCompile(Select("a").From("b"))
+
+	sc.Exec("SELECT a FROM b")
+	tpl.ExecContext(context.Background(), sc, nil)
+	sc.Exec("SELECT 1")
+	assert.Equal(t, 3, sc.Stats().Size)
+
+	sc.Invalidate("SELECT a FROM b")
+	assert.Equal(t, 1, sc.Stats().Size)
+	assert.Equal(t, 2, fake.Count("close SELECT a FROM b"))
+
+	sc.Exec("SELECT a FROM b")
+	assert.Equal(t, 3, fake.Count("prepare SELECT a FROM b"))
+
+	sc.InvalidateAll()
+	assert.Equal(t, 0, sc.Stats().Size)
+	assert.Equal(t, 1, fake.Count("close SELECT 1"))
+}
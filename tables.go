@@ -0,0 +1,474 @@
+package sqrl
+
+import "strings"
+
+// TableDependencies are the tables read and written by a statement.
+//
+// Tables are named as in the statement, without quotes and aliases. CTEs are
+// not tables; the tables they read and write are dependencies of the
+// statement. Views and functions are not looked into.
+type TableDependencies struct {
+	// Read are the tables of FROM, JOIN and USING clauses, including those
+	// of subqueries and CTEs.
+	Read []string
+
+	// Written are the tables inserted into, updated, deleted from or
+	// truncated, including those of data-modifying CTEs. They are only
+	// listed as read if the statement reads them too, e.g. in a subquery.
+	Written []string
+
+	// Unknown is true if some tables of the statement couldn't be resolved,
+	// e.g. a placeholder in a FROM clause. Read and Written are then
+	// incomplete.
+	Unknown bool
+}
+
+// Tables returns the written tables followed by the tables that are only
+// read.
+func (d TableDependencies) Tables() []string {
+	var tables []string
+	for _, table := range d.Written {
+		tables = appendTable(tables, table)
+	}
+	for _, table := range d.Read {
+		tables = appendTable(tables, table)
+	}
+	return tables
+}
+
+// ExtractTableDependencies returns the tables read and written by the
+// statement built by s, e.g. to invalidate caches or to audit access to
+// tables.
+//
+// The SQL of s is analysed, so that tables named in strings, such as joins,
+// WHERE subqueries and CTEs given as prefixes, are found as well as those of
+// builders.
+func ExtractTableDependencies(s Sqlizer) (TableDependencies, error) {
+	sql, _, err := s.ToSql()
+	if err != nil {
+		return TableDependencies{}, err
+	}
+	return SqlTableDependencies(sql), nil
+}
+
+// SqlTableDependencies returns the tables read and written by sql, see
+// ExtractTableDependencies.
+func SqlTableDependencies(sql string) TableDependencies {
+	a := newTableAnalyzer(sql)
+	a.analyze()
+
+	deps := TableDependencies{Read: a.read, Unknown: a.unknown}
+	for _, ref := range a.written {
+		if table, ok := a.aliases[strings.ToLower(ref)]; ok {
+			ref = table
+		}
+		if !a.ctes[strings.ToLower(ref)] {
+			deps.Written = appendTable(deps.Written, ref)
+		}
+	}
+	return deps
+}
+
+// tableStopWords can't be table names or aliases without quotes.
+var tableStopWords = map[string]bool{
+	"DUMPFILE": true, "FETCH": true, "FORCE": true, "IGNORE": true,
+	"LOCK": true, "OUTFILE": true, "STRAIGHT_JOIN": true, "TABLESAMPLE": true,
+	"USE": true, "WINDOW": true,
+}
+
+// textFunctions take FROM as an argument, e.g. EXTRACT(YEAR FROM a).
+var textFunctions = map[string]bool{
+	"EXTRACT": true, "OVERLAY": true, "POSITION": true, "SUBSTR": true,
+	"SUBSTRING": true, "TRIM": true,
+}
+
+// sqlTableRefs returns the tables referenced by sql with their aliases.
+func sqlTableRefs(sql string) []TableRef {
+	a := newTableAnalyzer(sql)
+	a.analyze()
+	return a.refs
+}
+
+type tableAnalyzer struct {
+	tokens []sqlToken
+	// ctes are the lower-case names of CTEs.
+	ctes map[string]bool
+	// aliases maps lower-case aliases to tables.
+	aliases map[string]string
+
+	read []string
+	// written are written tables or aliases of them, as in DELETE a FROM b
+	// AS a.
+	written []string
+
+	// refs are the tables read or written with their aliases.
+	refs []TableRef
+	// outer is true while reading the table of a LEFT or FULL join.
+	outer bool
+	// unknown is true if a table couldn't be resolved.
+	unknown bool
+	// nestedJoins is true if tables are joined in parentheses or listed
+	// after a join condition, as in a JOIN b ON a.id = b.id, c.
+	nestedJoins bool
+
+	// deleteFrom is the index of the FROM of DELETE FROM, whose tables are
+	// written.
+	deleteFrom int
+	// updateDepth is one more than the paren depth of an UPDATE whose
+	// joins are written, until its SET; 0 if none.
+	updateDepth int
+}
+
+func newTableAnalyzer(sql string) *tableAnalyzer {
+	var tokens []sqlToken
+	for _, tok := range scanSql(sql) {
+		if tok.kind != sqlComment {
+			tokens = append(tokens, tok)
+		}
+	}
+
+	a := &tableAnalyzer{tokens: tokens, aliases: make(map[string]string), deleteFrom: -1}
+	a.ctes = a.cteNames()
+	return a
+}
+
+func (a *tableAnalyzer) analyze() {
+	// parens holds the word before every open parenthesis.
+	var parens []string
+	for i, tok := range a.tokens {
+		if tok.kind == sqlPunct {
+			switch tok.text {
+			case "(":
+				parens = append(parens, a.word(i-1))
+			case ")":
+				if len(parens) > 0 {
+					parens = parens[:len(parens)-1]
+				}
+			}
+			continue
+		}
+		if tok.kind != sqlWord || a.isPunct(i-1, ".") {
+			continue
+		}
+
+		switch strings.ToUpper(tok.text) {
+		case "FROM":
+			if a.word(i-1) == "DISTINCT" || len(parens) > 0 && textFunctions[parens[len(parens)-1]] {
+				continue
+			}
+			a.readTables(i+1, i == a.deleteFrom, false)
+		case "JOIN", "STRAIGHT_JOIN":
+			joinType := a.word(i - 1)
+			if joinType == "OUTER" {
+				joinType = a.word(i - 2)
+			}
+			if joinType == "RIGHT" || joinType == "FULL" {
+				for k := range a.refs {
+					a.refs[k].Outer = true
+				}
+			}
+			a.outer = joinType == "LEFT" || joinType == "FULL"
+
+			written := a.updateDepth == len(parens)+1
+			if written {
+				a.readTables(i+1, true, true)
+			}
+			j := a.readTables(i+1, false, true)
+			a.outer = false
+
+			if k := a.skipJoinCondition(j); a.isPunct(k, ",") {
+				a.nestedJoins = true
+				if written {
+					a.readTables(k+1, true, false)
+				}
+				a.readTables(k+1, false, false)
+			}
+		case "USING":
+			if a.isPunct(i+1, "(") && !a.isSubquery(i+1) {
+				// The columns of a join.
+				continue
+			}
+			a.readTables(i+1, false, false)
+		case "INTO":
+			// Unless SELECT INTO a variable or a file.
+			if name, _, _ := a.readName(i + 1); name != "" {
+				a.readTables(i+1, true, true)
+			}
+		case "UPDATE":
+			switch a.word(i - 1) {
+			case "DO", "FOR", "KEY", "ON":
+				// ON CONFLICT DO UPDATE, FOR UPDATE, ON DUPLICATE KEY
+				// UPDATE and ON UPDATE CASCADE.
+				continue
+			}
+			a.updateDepth = len(parens) + 1
+			a.readTables(a.skipWords(i+1, "LOW_PRIORITY", "IGNORE", "ONLY"), true, false)
+		case "SET":
+			if a.updateDepth == len(parens)+1 {
+				a.updateDepth = 0
+			}
+		case "DELETE":
+			if a.word(i-1) == "ON" {
+				continue
+			}
+			j := a.skipWords(i+1, "LOW_PRIORITY", "QUICK", "IGNORE")
+			if a.word(j) == "FROM" {
+				a.deleteFrom = j
+			} else if name, _, _ := a.readName(j); name != "" {
+				// DELETE a, b FROM ...
+				a.readTables(j, true, false)
+			}
+		case "TRUNCATE":
+			a.readTables(a.skipWords(i+1, "TABLE", "ONLY"), true, false)
+		}
+	}
+}
+
+// readTables reads the list of tables at i, or a single one, and returns the
+// index after it. Subqueries are skipped; their tables are read when the
+// analysis reaches them.
+func (a *tableAnalyzer) readTables(i int, written, single bool) int {
+	for {
+		i = a.skipWords(i, "ONLY", "LATERAL")
+
+		var table, tableSql string
+		if a.isPunct(i, "(") {
+			if !a.isSubquery(i) {
+				// Joined tables in parentheses.
+				a.nestedJoins = true
+				a.readTables(i+1, written, false)
+			}
+			i = a.skipParens(i)
+		} else {
+			table, tableSql, i = a.readName(i)
+			if table == "" {
+				a.unknown = true
+				return i
+			}
+			if a.isPunct(i, "(") {
+				// The columns of an INSERT, or a table function.
+				if !written {
+					table = ""
+				}
+				i = a.skipParens(i)
+			}
+		}
+
+		if a.word(i) == "AS" {
+			i++
+		}
+		alias, aliasSql, next := a.readName(i)
+		if alias != "" {
+			i = next
+			if a.isPunct(i, "(") {
+				i = a.skipParens(i)
+			}
+		}
+
+		if table != "" {
+			if alias != "" {
+				tableSql = aliasSql
+			}
+			a.add(table, alias, tableSql, written)
+		}
+		if single || !a.isPunct(i, ",") {
+			return i
+		}
+		i++
+	}
+}
+
+// add adds table, referenced as sql in the statement.
+func (a *tableAnalyzer) add(table, alias, sql string, written bool) {
+	if alias != "" {
+		a.aliases[strings.ToLower(alias)] = table
+	}
+	ref := TableRef{
+		Table: table,
+		Name:  strings.ToLower(table[strings.LastIndexByte(table, '.')+1:]),
+		Alias: alias,
+		Outer: a.outer,
+		sql:   sql,
+	}
+	if !containsTableRef(a.refs, ref) {
+		a.refs = append(a.refs, ref)
+	}
+	if written {
+		a.written = append(a.written, table)
+	} else if !a.ctes[strings.ToLower(table)] {
+		a.read = appendTable(a.read, table)
+	}
+}
+
+// cteNames returns the lower-case names of the CTEs of WITH clauses.
+func (a *tableAnalyzer) cteNames() map[string]bool {
+	names := make(map[string]bool)
+	for i := range a.tokens {
+		if a.word(i) != "WITH" {
+			continue
+		}
+
+		j := a.skipWords(i+1, "RECURSIVE")
+		for {
+			name, _, next := a.readName(j)
+			if name == "" {
+				break
+			}
+			j = next
+			if a.isPunct(j, "(") {
+				j = a.skipParens(j)
+			}
+			if a.word(j) != "AS" {
+				break
+			}
+			j = a.skipWords(j+1, "NOT", "MATERIALIZED")
+			if !a.isPunct(j, "(") {
+				break
+			}
+			names[strings.ToLower(name)] = true
+			j = a.skipParens(j)
+			if !a.isPunct(j, ",") {
+				break
+			}
+			j++
+		}
+	}
+	return names
+}
+
+// readName reads a possibly qualified name at i and returns it without
+// quotes, as it is spelled in the SQL, and the index after it. It returns
+// empty names if there is none.
+func (a *tableAnalyzer) readName(i int) (string, string, int) {
+	var parts, sqlParts []string
+	for i < len(a.tokens) {
+		tok := a.tokens[i]
+		switch {
+		case tok.kind == sqlQuoted:
+			parts = append(parts, tok.text[1:len(tok.text)-1])
+		case tok.kind == sqlWord && (len(parts) > 0 || !a.isStopWord(i)):
+			parts = append(parts, tok.text)
+		default:
+			return strings.Join(parts, "."), strings.Join(sqlParts, "."), i
+		}
+		sqlParts = append(sqlParts, tok.text)
+		i++
+		if !a.isPunct(i, ".") {
+			break
+		}
+		i++
+	}
+	return strings.Join(parts, "."), strings.Join(sqlParts, "."), i
+}
+
+// isSubquery reports whether the parenthesis at i opens a statement, or a
+// placeholder for one, rather than joined tables.
+func (a *tableAnalyzer) isSubquery(i int) bool {
+	switch a.word(i + 1) {
+	case "SELECT", "WITH", "VALUES", "TABLE":
+		return true
+	}
+	return i+2 < len(a.tokens) && a.tokens[i+1].kind == sqlPlaceholder && a.isPunct(i+2, ")")
+}
+
+// joinConditionEnds are the words that end a join condition, besides a comma
+// listing more tables.
+var joinConditionEnds = map[string]bool{
+	"CROSS": true, "DELETE": true, "EXCEPT": true, "FETCH": true, "FOR": true,
+	"FULL": true, "GROUP": true, "HAVING": true, "INNER": true, "INSERT": true,
+	"INTERSECT": true, "JOIN": true, "LEFT": true, "LIMIT": true,
+	"NATURAL": true, "OFFSET": true, "ORDER": true, "RETURNING": true,
+	"RIGHT": true, "SET": true, "STRAIGHT_JOIN": true, "UNION": true,
+	"UPDATE": true, "USING": true, "WHERE": true, "WINDOW": true,
+}
+
+// skipJoinCondition returns the index after the ON or USING condition of a
+// join at i, or i if there is none.
+func (a *tableAnalyzer) skipJoinCondition(i int) int {
+	switch {
+	case a.word(i) == "USING" && a.isPunct(i+1, "("):
+		return a.skipParens(i + 1)
+	case a.word(i) != "ON":
+		return i
+	}
+	for i++; i < len(a.tokens); i++ {
+		switch {
+		case a.isPunct(i, "("):
+			i = a.skipParens(i) - 1
+		case a.isPunct(i, ")"), a.isPunct(i, ","), a.isPunct(i, ";"), joinConditionEnds[a.word(i)]:
+			return i
+		}
+	}
+	return i
+}
+
+func (a *tableAnalyzer) isStopWord(i int) bool {
+	w := a.word(i)
+	return reservedKeywords[w] || tableStopWords[w]
+}
+
+// word returns the upper-case word at i, or "" if there is none.
+func (a *tableAnalyzer) word(i int) string {
+	if i < 0 || i >= len(a.tokens) || a.tokens[i].kind != sqlWord {
+		return ""
+	}
+	return strings.ToUpper(a.tokens[i].text)
+}
+
+func (a *tableAnalyzer) isPunct(i int, text string) bool {
+	return i >= 0 && i < len(a.tokens) && a.tokens[i].kind == sqlPunct && a.tokens[i].text == text
+}
+
+// skipWords returns the index of the first token from i that isn't one of
+// words.
+func (a *tableAnalyzer) skipWords(i int, words ...string) int {
+	for {
+		w := a.word(i)
+		found := false
+		for _, word := range words {
+			found = found || w == word
+		}
+		if !found {
+			return i
+		}
+		i++
+	}
+}
+
+// skipParens returns the index after the parenthesis closing the one at i.
+func (a *tableAnalyzer) skipParens(i int) int {
+	depth := 0
+	for ; i < len(a.tokens); i++ {
+		if a.tokens[i].kind != sqlPunct {
+			continue
+		}
+		switch a.tokens[i].text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return i
+}
+
+func appendTable(tables []string, table string) []string {
+	for _, t := range tables {
+		if t == table {
+			return tables
+		}
+	}
+	return append(tables, table)
+}
+
+func containsTableRef(refs []TableRef, ref TableRef) bool {
+	for _, r := range refs {
+		if r == ref {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package sqrl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractTableDependencies(t *testing.T) {
+	testCases := map[string]struct {
+		s       Sqlizer
+		read    []string
+		written []string
+	}{
+		"select": {
+			s:    Select("a").From("b"),
+			read: []string{"b"},
+		},
+		"aliases and schemas": {
+			s:    Select("a").From(`public.b AS x`, "c y", "`d`"),
+			read: []string{"public.b", "c", "d"},
+		},
+		"joins": {
+			s: Select("a").From("b").
+				Join("c ON c.id = b.c_id").
+				LeftJoin(`"D" d USING (id)`).
+				JoinClause("CROSS JOIN LATERAL (SELECT * FROM e WHERE e.b_id = b.id) e2"),
+			read: []string{"b", "c", "D", "e"},
+		},
+		"join sqlizer": {
+			s:    Select("a").From("b").JoinClause(Expr("JOIN (?) c ON c.id = b.id", Select("id").From("d"))),
+			read: []string{"b", "d"},
+		},
+		"from select": {
+			s:    Select("a").FromSelect(Select("a").From("b").Where("c IN (SELECT c FROM d)"), "x"),
+			read: []string{"b", "d"},
+		},
+		"where subquery": {
+			s: Select("a").From("b").
+				Where(Or{Expr("c IN (?)", Select("c").From("d")), And{Eq{"e": 1}, Expr("EXISTS (?)", Select("*").From("f"))}}),
+			read: []string{"b", "d", "f"},
+		},
+		"column subquery": {
+			s:    Select("a").Column(Alias(Select("count(*)").From("c"), "n")).From("b"),
+			read: []string{"c", "b"},
+		},
+		"ctes": {
+			s: Select("a").From("x").Join("y USING (id)").
+				Prefix("WITH RECURSIVE x AS (SELECT a FROM b), y (id) AS MATERIALIZED (SELECT id FROM c)"),
+			read: []string{"b", "c"},
+		},
+		"data-modifying cte": {
+			s:       Select("a").From("d").Prefix("WITH d AS (DELETE FROM b WHERE c RETURNING a)"),
+			read:    nil,
+			written: []string{"b"},
+		},
+		"functions": {
+			s: Select("EXTRACT(YEAR FROM a)", "SUBSTRING(b FROM 2)", "c IS DISTINCT FROM d").
+				From("generate_series(1, 3) AS s", "t"),
+			read: []string{"t"},
+		},
+		"comments and literals": {
+			s:    Select("a").From("b /* JOIN c */").Where("d = 'FROM e'"),
+			read: []string{"b"},
+		},
+		"insert": {
+			s:       Insert("a").Columns("b", "c").Values(1, 2),
+			written: []string{"a"},
+		},
+		"insert select": {
+			s:       Insert("a").Columns("b").Select(Select("b").From("c").Join("d ON d.id = c.id")),
+			read:    []string{"c", "d"},
+			written: []string{"a"},
+		},
+		"upsert": {
+			s:       Insert("a").Columns("b").Values(1).Suffix("ON CONFLICT (b) DO UPDATE SET b = excluded.b"),
+			written: []string{"a"},
+		},
+		"update": {
+			s:       Update("a").Set("b", 1).Where("c IN (SELECT c FROM d)"),
+			read:    []string{"d"},
+			written: []string{"a"},
+		},
+		"update from": {
+			s:       Update("a").Set("b", Expr("x.b")).From("c x").FromSelect(Select("b").From("d"), "y"),
+			read:    []string{"c", "d"},
+			written: []string{"a"},
+		},
+		"update set subquery": {
+			s:       Update("a").Set("b", Select("max(b)").From("c")),
+			read:    []string{"c"},
+			written: []string{"a"},
+		},
+		"mysql update join": {
+			s:       Update("a JOIN b ON a.id = b.a_id").Set("a.c", Expr("b.c")),
+			read:    []string{"b"},
+			written: []string{"a", "b"},
+		},
+		"delete": {
+			s:       Delete("a").Where(Eq{"b": 1}),
+			written: []string{"a"},
+		},
+		"delete using": {
+			s:       Delete("a").Using("b", "c").UsingSelect(Select("id").From("d"), "x").Where("a.id = b.id"),
+			read:    []string{"b", "c", "d"},
+			written: []string{"a"},
+		},
+		"delete multiple tables": {
+			s: Delete("a1", "a2").
+				From("z1 AS a1").
+				JoinClause("INNER JOIN z2 a2 ON a1.id = a2.ref_id").
+				Join("a3"),
+			read:    []string{"z1", "z2", "a3"},
+			written: []string{"z1", "z2"},
+		},
+		"delete returning": {
+			s:       Delete("a").ReturningSelect(Select("b").From("c"), "d"),
+			read:    []string{"c"},
+			written: []string{"a"},
+		},
+		"locking": {
+			s:    Select("a").From("b").Suffix("FOR UPDATE OF b"),
+			read: []string{"b"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			deps, err := ExtractTableDependencies(tc.s)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.read, deps.Read, "read")
+			assert.Equal(t, tc.written, deps.Written, "written")
+		})
+	}
+}
+
+func TestSqlTableDependencies(t *testing.T) {
+	testCases := map[string]TableDependencies{
+		"TRUNCATE TABLE a, b":                                                 {Written: []string{"a", "b"}},
+		"INSERT IGNORE INTO a (b) SELECT b FROM c":                            {Read: []string{"c"}, Written: []string{"a"}},
+		"MERGE INTO a USING b ON a.id = b.id WHEN MATCHED THEN DELETE":        {Read: []string{"b"}, Written: []string{"a"}},
+		"DELETE FROM a, b USING a JOIN b JOIN c":                              {Read: []string{"a", "b", "c"}, Written: []string{"a", "b"}},
+		"SELECT a FROM b FOR UPDATE":                                          {Read: []string{"b"}},
+		"CALL a()":                                                            {},
+		"SELECT * FROM (users u JOIN b ON true)":                              {Read: []string{"users", "b"}},
+		"SELECT * FROM a, ((users) LEFT JOIN (SELECT 1) c ON true)":           {Read: []string{"a", "users"}},
+		"SELECT * FROM a JOIN b ON a.id = b.id, c":                            {Read: []string{"a", "b", "c"}},
+		"SELECT * FROM a LEFT JOIN b ON a.x IN (1,2), c JOIN d USING (id), e": {Read: []string{"a", "b", "c", "d", "e"}},
+		"SELECT * FROM a JOIN b ON a.id = b.id, ?":                            {Read: []string{"a", "b"}, Unknown: true},
+		"SELECT * FROM a WHERE b IN (SELECT b FROM ?)":                        {Read: []string{"a"}, Unknown: true},
+	}
+
+	for sql, expected := range testCases {
+		assert.Equal(t, expected, SqlTableDependencies(sql), sql)
+	}
+}
+
+func TestExtractTableNames(t *testing.T) {
+	assert.Equal(t, []string{"a", "c", "d"},
+		ExtractTableNames(Update("a").Set("b", 1).From("c").Where("e IN (SELECT e FROM d)")))
+
+	b := Select("a").From("x")
+	b.fromParts = []Sqlizer{newPart(Expr("(SELECT a FROM b) AS x"))}
+	assert.Equal(t, []string{"b"}, ExtractTableNames(b))
+
+	assert.Nil(t, ExtractTableNames(Delete("")))
+}
+
+func TestTableDependenciesTables(t *testing.T) {
+	deps := TableDependencies{Read: []string{"b", "a"}, Written: []string{"a"}}
+	assert.Equal(t, []string{"a", "b"}, deps.Tables())
+}
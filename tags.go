@@ -0,0 +1,214 @@
+package sqrl
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// tag is a key/value pair rendered as a sqlcommenter comment.
+type tag struct {
+	key, value string
+}
+
+// queryTags are the tags of a statement. They are never modified in place, so
+// that builders and contexts can share them.
+type queryTags []tag
+
+// with returns a copy of t where key is set to value.
+func (t queryTags) with(key, value string) queryTags {
+	c := make(queryTags, len(t), len(t)+1)
+	copy(c, t)
+	for i := range c {
+		if c[i].key == key {
+			c[i].value = value
+			return c
+		}
+	}
+	return append(c, tag{key, value})
+}
+
+// merge returns a copy of t with all tags of o set.
+func (t queryTags) merge(o queryTags) queryTags {
+	for _, tag := range o {
+		t = t.with(tag.key, tag.value)
+	}
+	return t
+}
+
+// appendComment writes t as a sqlcommenter comment, e.g.
+// " /*action='list',route='%2Fusers'*/", sorted by key.
+//
+// Keys and values are percent-encoded except for unreserved characters, so
+// the comment can neither be closed early nor contain quotes or placeholders.
+func (t queryTags) appendComment(buf *bytes.Buffer) {
+	if len(t) == 0 {
+		return
+	}
+
+	sorted := make(queryTags, len(t))
+	copy(sorted, t)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].key < sorted[j].key })
+
+	buf.WriteString(" /*")
+	for i, tag := range sorted {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		writeTagEscaped(buf, tag.key)
+		buf.WriteString("='")
+		writeTagEscaped(buf, tag.value)
+		buf.WriteByte('\'')
+	}
+	buf.WriteString("*/")
+}
+
+func (t queryTags) comment() string {
+	var buf bytes.Buffer
+	t.appendComment(&buf)
+	return buf.String()
+}
+
+const upperHex = "0123456789ABCDEF"
+
+func writeTagEscaped(buf *bytes.Buffer, s string) {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+			continue
+		}
+		buf.WriteByte('%')
+		buf.WriteByte(upperHex[c>>4])
+		buf.WriteByte(upperHex[c&15])
+	}
+}
+
+type tagsKey struct{}
+
+// WithTags returns a context carrying tags in addition to the tags already
+// carried by ctx. Statements executed with the context by a runner wrapped
+// with TagStatements are tagged with them.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	t, _ := ctx.Value(tagsKey{}).(queryTags)
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		t = t.with(key, tags[key])
+	}
+	return context.WithValue(ctx, tagsKey{}, t)
+}
+
+// TagsFromContext returns the tags carried by ctx.
+func TagsFromContext(ctx context.Context) map[string]string {
+	t, _ := ctx.Value(tagsKey{}).(queryTags)
+	tags := make(map[string]string, len(t))
+	for _, tag := range t {
+		tags[tag.key] = tag.value
+	}
+	return tags
+}
+
+// TagOption configures TagStatements.
+type TagOption func(*tagConfig)
+
+type tagConfig struct {
+	callerKey string
+}
+
+// TagCaller tags statements with the name of the Go function that executed
+// them under key, e.g. "func".
+func TagCaller(key string) TagOption {
+	return func(c *tagConfig) {
+		c.callerKey = key
+	}
+}
+
+// TagStatements returns a Middleware that appends the tags of the context to
+// every statement as a sqlcommenter comment.
+//
+// Tags of builders are merged with the tags of the context, so that a
+// statement always has a single comment. Builder tags take precedence.
+func TagStatements(opts ...TagOption) Middleware {
+	c := &tagConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			tags, _ := ctx.Value(tagsKey{}).(queryTags)
+			if c.callerKey != "" {
+				if caller := statementCaller(); caller != "" {
+					tags = tags.with(c.callerKey, caller)
+				}
+			}
+
+			if bt := builderTags(stmt.Sqlizer); len(bt) > 0 {
+				if comment := bt.comment(); strings.HasSuffix(stmt.SQL, comment) {
+					stmt.SQL = stmt.SQL[:len(stmt.SQL)-len(comment)]
+					tags = tags.merge(bt)
+				}
+			}
+
+			if len(tags) > 0 {
+				stmt.SQL += tags.comment()
+			}
+			return next(ctx, stmt)
+		}
+	}
+}
+
+func builderTags(s Sqlizer) queryTags {
+	switch b := s.(type) {
+	case *SelectBuilder:
+		return b.tags
+	case *InsertBuilder:
+		return b.tags
+	case *UpdateBuilder:
+		return b.tags
+	case *DeleteBuilder:
+		return b.tags
+	default:
+		return nil
+	}
+}
+
+// sqrlDir is the directory of the sqrl sources, used to skip sqrl frames when
+// looking for the caller of a statement.
+var sqrlDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// statementCaller returns the name of the function that executed the current
+// statement: the first function outside of sqrl that called the wrapped
+// runner. Middlewares between the runner and the caller are skipped.
+func statementCaller() string {
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	inRunner := false
+	for {
+		frame, more := frames.Next()
+		if strings.HasSuffix(frame.Function, ".(*wrappedRunner).runStatement") {
+			inRunner = true
+		} else if inRunner && !isSqrlFrame(frame) {
+			return frame.Function[strings.LastIndexByte(frame.Function, '/')+1:]
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func isSqrlFrame(frame runtime.Frame) bool {
+	return filepath.Dir(frame.File) == sqrlDir && !strings.HasSuffix(frame.File, "_test.go")
+}
@@ -0,0 +1,105 @@
+package sqrl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilderTags(t *testing.T) {
+	sql, args, err := Select("a").
+		From("b").
+		Where("c = ?", 1).
+		Tag("route", "/users/{id}").
+		Tag("action", "show").
+		PlaceholderFormat(Dollar).
+		ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM b WHERE c = $1 /*action='show',route='%2Fusers%2F%7Bid%7D'*/", sql)
+	assert.Equal(t, []interface{}{1}, args)
+
+	sql, _, _ = Insert("a").Values(1).Tag("k", "v").ToSql()
+	assert.Equal(t, "INSERT INTO a VALUES (?) /*k='v'*/", sql)
+	sql, _, _ = Update("a").Set("b", 1).Tag("k", "v").Tag("k", "w").ToSql()
+	assert.Equal(t, "UPDATE a SET b = ? /*k='w'*/", sql)
+	sql, _, _ = Delete("a").Tag("k", "v").ToSql()
+	assert.Equal(t, "DELETE FROM a /*k='v'*/", sql)
+}
+
+func TestBuilderTagsSubquery(t *testing.T) {
+	sub := Select("id").From("b").Tag("sub", "1")
+	sql, _, err := Select("a").FromSelect(sub, "s").Tag("top", "1").ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM (SELECT id FROM b) AS s /*top='1'*/", sql)
+}
+
+func TestStatementBuilderTags(t *testing.T) {
+	sb := StatementBuilder.Tag("application", "billing")
+	sql, _, _ := sb.Select("a").From("b").Tag("action", "list").ToSql()
+	assert.Equal(t, "SELECT a FROM b /*action='list',application='billing'*/", sql)
+
+	sql, _, _ = sb.Delete("b").ToSql()
+	assert.Equal(t, "DELETE FROM b /*application='billing'*/", sql)
+
+	sql, _, _ = Select("a").From("b").ToSql()
+	assert.Equal(t, "SELECT a FROM b", sql)
+}
+
+func TestImmutableBuilderTags(t *testing.T) {
+	base := StatementBuilder.Immutable().Select("a").From("b").Tag("k", "base")
+	derived := base.Tag("k", "derived").Tag("x", "y")
+
+	sql, _, _ := base.ToSql()
+	assert.Equal(t, "SELECT a FROM b /*k='base'*/", sql)
+	sql, _, _ = derived.ToSql()
+	assert.Equal(t, "SELECT a FROM b /*k='derived',x='y'*/", sql)
+}
+
+func TestTagEscaping(t *testing.T) {
+	testCases := map[string]string{
+		"plain-value_1.0~":      "plain-value_1.0~",
+		"it's":                  "it%27s",
+		"*/ DROP TABLE users--": "%2A%2F%20DROP%20TABLE%20users--",
+		"a=?&b=$1":              "a%3D%3F%26b%3D%241",
+		`back\slash"`:           "back%5Cslash%22",
+		"line\nbreak\x00":       "line%0Abreak%00",
+		"zażółć":                "za%C5%BC%C3%B3%C5%82%C4%87",
+	}
+	for value, expected := range testCases {
+		comment := queryTags{{"k", value}}.comment()
+		assert.Equal(t, " /*k='"+expected+"'*/", comment, value)
+	}
+
+	assert.Equal(t, " /*a%27b='c'*/", queryTags{{"a'b", "c"}}.comment())
+}
+
+func TestTagStatements(t *testing.T) {
+	db := &DBStub{}
+	runner := WrapRunner(db, TagStatements())
+
+	ctx := WithTags(context.Background(), map[string]string{"route": "/users", "action": "list"})
+	ctx = WithTags(ctx, map[string]string{"action": "show"})
+	assert.Equal(t, map[string]string{"route": "/users", "action": "show"}, TagsFromContext(ctx))
+
+	Select("a").From("b").RunWith(runner).QueryContext(ctx)
+	assert.Equal(t, "SELECT a FROM b /*action='show',route='%2Fusers'*/", db.LastQuerySql)
+
+	Select("a").From("b").Tag("action", "builder").Tag("k", "v").RunWith(runner).QueryContext(ctx)
+	assert.Equal(t, "SELECT a FROM b /*action='builder',k='v',route='%2Fusers'*/", db.LastQuerySql,
+		"builder tags must be merged into a single comment")
+
+	runner.ExecContext(context.Background(), "DELETE FROM a")
+	assert.Equal(t, "DELETE FROM a", db.LastExecSql)
+}
+
+func TestTagCaller(t *testing.T) {
+	db := &DBStub{}
+	runner := WrapRunner(db, TagStatements(TagCaller("func")), TraceStatements(&tracerStub{}))
+
+	Delete("a").RunWith(runner).Exec()
+	assert.Equal(t, "DELETE FROM a /*func='sqrl.TestTagCaller'*/", db.LastExecSql)
+
+	Select("a").RunWith(runner).Scan()
+	assert.Equal(t, "SELECT a /*func='sqrl.TestTagCaller'*/", db.LastQueryRowSql)
+}
@@ -0,0 +1,201 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+)
+
+// param is a named argument slot of a Template.
+type param struct {
+	name string
+	typ  reflect.Type
+}
+
+// Param returns a named argument slot that is bound to a value when a compiled
+// Template is executed. It can be used anywhere a query argument is accepted.
+//
+// Ex:
+//     .Where("id = ?", Param("id"))
+//     .Where(Eq{"name": Param("name")})
+//
+// The shape of the query is fixed at compile time, so a Param always binds to
+// a single placeholder: Eq{"id": Param("ids")} renders "id = ?", never an IN
+// list.
+//
+// A Param accepts values of any type, use TypedParam to check the values bound
+// to the slot.
+func Param(name string) interface{} {
+	return param{name: name}
+}
+
+// TypedParam is like Param but the slot only accepts values of the same type
+// as example, or nil. Binding a value of another type returns an error.
+//
+// Ex:
+//     .Where("id = ?", TypedParam("id", int64(0)))
+func TypedParam(name string, example interface{}) interface{} {
+	return param{name: name, typ: reflect.TypeOf(example)}
+}
+
+type slot struct {
+	index int
+	name  string
+	typ   reflect.Type
+}
+
+// Template is a statement that has been rendered once and can be executed
+// many times with different values bound to its Param slots.
+//
+// A Template is immutable and safe for concurrent use.
+type Template struct {
+	sql   string
+	args  []interface{}
+	slots []slot
+	names []string
+}
+
+// Compile renders s into a Template.
+func Compile(s Sqlizer) (*Template, error) {
+	query, args, err := s.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Template{sql: query, args: args}
+	types := make(map[string]reflect.Type)
+	for i, arg := range args {
+		p, ok := arg.(param)
+		if !ok {
+			continue
+		}
+		typ, seen := types[p.name]
+		if !seen {
+			types[p.name] = p.typ
+			t.names = append(t.names, p.name)
+		} else if typ != p.typ {
+			return nil, fmt.Errorf("template parameter %q has conflicting types %v and %v", p.name, typ, p.typ)
+		}
+		t.slots = append(t.slots, slot{index: i, name: p.name, typ: p.typ})
+	}
+	return t, nil
+}
+
+// MustCompile is like Compile but panics if s cannot be rendered. It is meant
+// for templates stored in package level variables.
+func MustCompile(s Sqlizer) *Template {
+	t, err := Compile(s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Sql returns the SQL of the template.
+func (t *Template) Sql() string {
+	return t.sql
+}
+
+// Params returns the names of the template's parameters in order of their
+// first appearance.
+func (t *Template) Params() []string {
+	return append([]string(nil), t.names...)
+}
+
+// Bind returns the SQL of the template along with its args, where every Param
+// slot is replaced with the value of the same name from params.
+//
+// Every parameter must have a value, and params must not contain names
+// unknown to the template.
+func (t *Template) Bind(params map[string]interface{}) (string, []interface{}, error) {
+	args, err := t.bindArgs(params)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.sql, args, nil
+}
+
+func (t *Template) bindArgs(params map[string]interface{}) ([]interface{}, error) {
+	if len(params) != len(t.names) {
+		for name := range params {
+			if !t.hasParam(name) {
+				return nil, fmt.Errorf("unknown template parameter %q", name)
+			}
+		}
+	}
+
+	args := make([]interface{}, len(t.args))
+	copy(args, t.args)
+	for _, s := range t.slots {
+		v, ok := params[s.name]
+		if !ok {
+			return nil, fmt.Errorf("missing value for template parameter %q", s.name)
+		}
+		if s.typ != nil && v != nil && reflect.TypeOf(v) != s.typ {
+			return nil, fmt.Errorf("template parameter %q must be %v, not %T", s.name, s.typ, v)
+		}
+		args[s.index] = v
+	}
+	return args, nil
+}
+
+func (t *Template) hasParam(name string) bool {
+	for _, n := range t.names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// templateRunner is implemented by statement caches that look up prepared
+// statements of templates by pointer.
+type templateRunner interface {
+	execTemplate(ctx context.Context, t *Template, args []interface{}) (sql.Result, error)
+	queryTemplate(ctx context.Context, t *Template, args []interface{}) (*sql.Rows, error)
+	queryRowTemplate(ctx context.Context, t *Template, args []interface{}) RowScanner
+}
+
+// ExecContext binds params and Execs the template with db.
+//
+// If db is a statement cache created by NewStmtCacher or NewStmtCacheProxy,
+// the prepared statement is looked up by template rather than by SQL string.
+func (t *Template) ExecContext(ctx context.Context, db ExecerContext, params map[string]interface{}) (sql.Result, error) {
+	args, err := t.bindArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	if tr, ok := db.(templateRunner); ok {
+		return tr.execTemplate(ctx, t, args)
+	}
+	return db.ExecContext(ctx, t.sql, args...)
+}
+
+// QueryContext binds params and Querys the template with db.
+//
+// See ExecContext for statement caches.
+func (t *Template) QueryContext(ctx context.Context, db QueryerContext, params map[string]interface{}) (*sql.Rows, error) {
+	args, err := t.bindArgs(params)
+	if err != nil {
+		return nil, err
+	}
+	if tr, ok := db.(templateRunner); ok {
+		return tr.queryTemplate(ctx, t, args)
+	}
+	return db.QueryContext(ctx, t.sql, args...)
+}
+
+// QueryRowContext binds params and QueryRows the template with db.
+//
+// See ExecContext for statement caches.
+func (t *Template) QueryRowContext(ctx context.Context, db QueryRowerContext, params map[string]interface{}) RowScanner {
+	args, err := t.bindArgs(params)
+	if err != nil {
+		return &Row{err: err}
+	}
+	if tr, ok := db.(templateRunner); ok {
+		return &Row{RowScanner: tr.queryRowTemplate(ctx, t, args)}
+	}
+	return &Row{RowScanner: db.QueryRowContext(ctx, t.sql, args...)}
+}
@@ -0,0 +1,119 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTemplateBind(t *testing.T) {
+	tpl, err := Compile(Select("a").
+		From("b").
+		Where("c = ?", Param("id")).
+		Where(Eq{"d": Param("name"), "e": 1}).
+		Where(Or{Expr("f = ?", Param("id")), Expr("g")}).
+		PlaceholderFormat(Dollar))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "SELECT a FROM b WHERE c = $1 AND d = $2 AND e = $3 AND (f = $4 OR g)", tpl.Sql())
+	assert.Equal(t, []string{"id", "name"}, tpl.Params())
+
+	sql, args, err := tpl.Bind(map[string]interface{}{"id": 42, "name": "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, tpl.Sql(), sql)
+	assert.Equal(t, []interface{}{42, "foo", 1, 42}, args)
+
+	_, args, err = tpl.Bind(map[string]interface{}{"id": 7, "name": "bar"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{7, "bar", 1, 7}, args)
+}
+
+func TestTemplateBindErr(t *testing.T) {
+	tpl := MustCompile(Select("a").From("b").Where("c = ?", Param("id")))
+
+	_, _, err := tpl.Bind(nil)
+	assert.EqualError(t, err, `missing value for template parameter "id"`)
+
+	_, _, err = tpl.Bind(map[string]interface{}{"id": 1, "idd": 2})
+	assert.EqualError(t, err, `unknown template parameter "idd"`)
+}
+
+func TestTemplateTypedParam(t *testing.T) {
+	tpl := MustCompile(Select("a").From("b").Where("c = ? OR d = ?", TypedParam("id", int64(0)), Param("name")))
+
+	_, args, err := tpl.Bind(map[string]interface{}{"id": int64(1), "name": 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(1), 2}, args)
+
+	_, args, err = tpl.Bind(map[string]interface{}{"id": nil, "name": "foo"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{nil, "foo"}, args)
+
+	_, _, err = tpl.Bind(map[string]interface{}{"id": 1, "name": "foo"})
+	assert.EqualError(t, err, `template parameter "id" must be int64, not int`)
+
+	_, err = Compile(Select("a").From("b").Where("c = ? OR d = ?", TypedParam("id", int64(0)), Param("id")))
+	assert.EqualError(t, err, `template parameter "id" has conflicting types int64 and <nil>`)
+}
+
+func TestCompileErr(t *testing.T) {
+	_, err := Compile(Insert(""))
+	assert.Error(t, err)
+
+	assert.Panics(t, func() { MustCompile(Insert("")) })
+}
+
+func TestTemplateRunners(t *testing.T) {
+	db := &DBStub{}
+	tpl := MustCompile(Update("a").Set("b", Param("b")).Where("c = ?", 1))
+	params := map[string]interface{}{"b": 2}
+
+	tpl.ExecContext(context.TODO(), db, params)
+	assert.Equal(t, "UPDATE a SET b = ? WHERE c = ?", db.LastExecSql)
+	assert.Equal(t, []interface{}{2, 1}, db.LastExecArgs)
+
+	tpl.QueryContext(context.TODO(), db, params)
+	assert.Equal(t, "UPDATE a SET b = ? WHERE c = ?", db.LastQuerySql)
+	assert.Equal(t, []interface{}{2, 1}, db.LastQueryArgs)
+
+	err := tpl.QueryRowContext(context.TODO(), db, params).Scan()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE a SET b = ? WHERE c = ?", db.LastQueryRowSql)
+	assert.Equal(t, []interface{}{2, 1}, db.LastQueryRowArgs)
+
+	_, err = tpl.ExecContext(context.TODO(), db, nil)
+	assert.Error(t, err)
+}
+
+func TestTemplateStmtCacher(t *testing.T) {
+	db, fake := newFakeDB()
+	fake.columns = []string{"a"}
+	fake.rows = [][]driver.Value{{int64(1)}}
+	sc := NewStmtCacher(db)
+
+	tpl := MustCompile(Select("a").From("b").Where("c = ?", Param("c")))
+
+	for i := 0; i < 3; i++ {
+		_, err := tpl.ExecContext(context.TODO(), sc, map[string]interface{}{"c": i})
+		assert.NoError(t, err)
+	}
+	var a int
+	err := tpl.QueryRowContext(context.TODO(), sc, map[string]interface{}{"c": 3}).Scan(&a)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, a)
+
+	assert.Equal(t, 1, fake.Count("prepare SELECT a FROM b WHERE c = ?"))
+	assert.Equal(t, 3, fake.Count("exec "))
+	assert.Equal(t, 1, fake.Count("query "))
+}
+
+func BenchmarkTemplateBind(b *testing.B) {
+	tpl := MustCompile(Select("a", "b").From("c").Where(Eq{"d": Param("d")}).Limit(10))
+	params := map[string]interface{}{"d": 1}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		tpl.Bind(params)
+	}
+}
@@ -0,0 +1,86 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// Tracer starts a span for every statement executed by a runner wrapped
+// with TraceStatements.
+//
+// sqrl doesn't depend on any tracing SDK, Tracer is implemented by adapters
+// such as package github.com/SharperShape/sqrl/otelsqrl.
+type Tracer interface {
+	StartSpan(ctx context.Context, info SpanInfo) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span with the error of the statement, nil on success.
+	End(err error)
+}
+
+// SpanInfo describes the statement of a span.
+type SpanInfo struct {
+	Method RunMethod
+
+	// Kind is the kind of the statement, e.g. "SELECT" or "INSERT".
+	Kind string
+
+	// Tables are the tables referenced by the statement as returned by
+	// SqlTableDependencies.
+	Tables []string
+
+	// Fingerprint is the SQL of the statement normalised by Normalize, so
+	// that all executions of a statement share it and no literal ends up in
+	// traces.
+	Fingerprint string
+}
+
+// TraceStatements returns a Middleware that starts a span with tracer for
+// every statement.
+//
+// sql.ErrNoRows returned by QueryRow is not reported as an error.
+func TraceStatements(tracer Tracer) Middleware {
+	return func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			info := SpanInfo{
+				Method:      stmt.Method,
+				Kind:        statementKind(stmt),
+				Fingerprint: Normalize(stmt.SQL),
+				Tables:      SqlTableDependencies(stmt.SQL).Tables(),
+			}
+
+			ctx, span := tracer.StartSpan(ctx, info)
+			err := next(ctx, stmt)
+			if err == sql.ErrNoRows {
+				span.End(nil)
+			} else {
+				span.End(err)
+			}
+			return err
+		}
+	}
+}
+
+// statementKind returns the kind of the builder of stmt, or the first
+// keyword of its SQL.
+func statementKind(stmt *Statement) string {
+	switch stmt.Sqlizer.(type) {
+	case *SelectBuilder:
+		return "SELECT"
+	case *InsertBuilder:
+		return "INSERT"
+	case *UpdateBuilder:
+		return "UPDATE"
+	case *DeleteBuilder:
+		return "DELETE"
+	}
+
+	sql := strings.TrimSpace(stmt.SQL)
+	if i := strings.IndexAny(sql, " \t\r\n("); i >= 0 {
+		sql = sql[:i]
+	}
+	return strings.ToUpper(sql)
+}
@@ -0,0 +1,82 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tracerStub struct {
+	spans []*spanStub
+}
+
+type spanStub struct {
+	info  SpanInfo
+	ended bool
+	err   error
+}
+
+type spanKey struct{}
+
+func (t *tracerStub) StartSpan(ctx context.Context, info SpanInfo) (context.Context, Span) {
+	s := &spanStub{info: info}
+	t.spans = append(t.spans, s)
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+func (s *spanStub) End(err error) {
+	s.ended = true
+	s.err = err
+}
+
+func TestTraceStatements(t *testing.T) {
+	db, fake := newFakeDB()
+	tracer := &tracerStub{}
+
+	var spanCtx interface{}
+	runner := WrapRunner(db, TraceStatements(tracer), func(next RunFunc) RunFunc {
+		return func(ctx context.Context, stmt *Statement) error {
+			spanCtx = ctx.Value(spanKey{})
+			return next(ctx, stmt)
+		}
+	})
+
+	rows, err := Select("a").
+		From("b").
+		Join("c ON c.id = b.c_id").
+		Where(Eq{"d": []int{1, 2, 3}}).
+		PlaceholderFormat(Dollar).
+		RunWith(runner).
+		Query()
+	assert.NoError(t, err)
+	rows.Close()
+
+	assert.Len(t, tracer.spans, 1)
+	span := tracer.spans[0]
+	assert.Equal(t, SpanInfo{
+		Method:      MethodQuery,
+		Kind:        "SELECT",
+		Tables:      []string{"b", "c"},
+		Fingerprint: "SELECT a FROM b JOIN c ON c.id = b.c_id WHERE d IN (?)",
+	}, span.info)
+	assert.True(t, span.ended)
+	assert.NoError(t, span.err)
+	assert.Equal(t, span, spanCtx, "span context must be passed down the chain")
+
+	fake.execErr = func(string) error { return errors.New("boom") }
+	_, err = runner.Exec("update  a\n\tSET b = 1")
+	assert.Error(t, err)
+	span = tracer.spans[1]
+	assert.Equal(t, "UPDATE", span.info.Kind)
+	assert.Equal(t, []string{"a"}, span.info.Tables)
+	assert.Equal(t, "update a SET b = ?", span.info.Fingerprint)
+	assert.EqualError(t, span.err, "boom")
+
+	fake.execErr = nil
+	err = Select("a").From("b").RunWith(runner).Scan()
+	assert.Equal(t, sql.ErrNoRows, err)
+	assert.NoError(t, tracer.spans[2].err, "no rows is not a failure")
+}
@@ -0,0 +1,208 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// TxRetryPolicy tells InTx how to retry transactions.
+type TxRetryPolicy struct {
+	// MaxAttempts is the maximum number of times a transaction is run. Zero
+	// or less means once.
+	MaxAttempts int
+
+	// MinBackoff and MaxBackoff bound the delay before a retry. The delay
+	// doubles with every attempt, and a random delay up to it is used.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable reports whether a transaction that failed with err can be
+	// retried. IsRetryableTxError is used if nil.
+	Retryable func(err error) bool
+}
+
+// DefaultTxRetryPolicy is the TxRetryPolicy of InTx.
+var DefaultTxRetryPolicy = TxRetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  10 * time.Millisecond,
+	MaxBackoff:  500 * time.Millisecond,
+}
+
+// InTx runs fn in a transaction begun with db and opts, see
+// TxRetryPolicy.InTx. Transactions are retried with DefaultTxRetryPolicy.
+func InTx(ctx context.Context, db BaseRunnerContext, opts *sql.TxOptions, fn func(tx Runner) error) error {
+	return DefaultTxRetryPolicy.InTx(ctx, db, opts, fn)
+}
+
+// InTx runs fn in a transaction begun with db and opts, e.g. to set the
+// isolation level.
+//
+// db is a *sql.DB, a *sql.Conn, a DBProxyBeginner such as NewStmtCacheProxy,
+// or anything with a BeginTx method that returns a *sql.Tx or a TxRunner.
+//
+// The transaction is committed if fn returns nil, and rolled back if fn
+// returns an error or panics; panics are propagated. Transactions that fail
+// with an error for which p.Retryable returns true, e.g. serialization
+// failures and deadlocks, are rolled back and run again after a backoff, so
+// fn must not have side effects outside of the transaction. If ctx is done
+// during a backoff, the error wraps ctx.Err() and mentions the last error.
+//
+// If db is already a transaction, e.g. the Runner passed to fn by an outer
+// InTx or a *sql.Tx, fn runs in a savepoint of it instead: the savepoint is
+// released if fn returns nil, and the transaction is rolled back to it if fn
+// returns an error or panics. opts is ignored and fn isn't retried, as
+// serialization failures and deadlocks abort the whole transaction.
+func (p TxRetryPolicy) InTx(ctx context.Context, db BaseRunnerContext, opts *sql.TxOptions, fn func(tx Runner) error) error {
+	switch tx := db.(type) {
+	case TxRunner:
+		return runSavepoint(ctx, tx, fn)
+	case *sql.Tx:
+		return runSavepoint(ctx, &txRunner{tx}, fn)
+	}
+
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsRetryableTxError
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, opts, fn)
+		if err == nil || attempt >= p.MaxAttempts || !retryable(err) {
+			return err
+		}
+
+		timer := time.NewTimer(p.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the retry following attempt.
+func (p TxRetryPolicy) backoff(attempt int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(d) + 1))
+}
+
+func runTx(ctx context.Context, db BaseRunnerContext, opts *sql.TxOptions, fn func(tx Runner) error) error {
+	tx, err := beginTx(ctx, db, opts)
+	if err != nil {
+		return err
+	}
+
+	// Roll back if fn panics or calls runtime.Goexit, e.g. with t.FailNow.
+	done := false
+	defer func() {
+		if !done {
+			tx.Rollback()
+		}
+	}()
+
+	err = fn(tx)
+	done = true
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// savepointID numbers savepoints, so that their names are unique and never
+// come from user input.
+var savepointID uint64
+
+// runSavepoint runs fn in a new savepoint of tx.
+func runSavepoint(ctx context.Context, tx Runner, fn func(tx Runner) error) error {
+	name := fmt.Sprintf("sp_%d", atomic.AddUint64(&savepointID, 1))
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return err
+	}
+
+	done := false
+	defer func() {
+		if !done {
+			tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name)
+		}
+	}()
+
+	err := fn(tx)
+	done = true
+	if err != nil {
+		if _, rerr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rerr != nil {
+			return fmt.Errorf("%w; rollback to savepoint: %v", err, rerr)
+		}
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name)
+	return err
+}
+
+type sqlTxBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+type txRunnerBeginner interface {
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (TxRunner, error)
+}
+
+func beginTx(ctx context.Context, db BaseRunnerContext, opts *sql.TxOptions) (TxRunner, error) {
+	switch db := db.(type) {
+	case sqlTxBeginner:
+		tx, err := db.BeginTx(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &txRunner{tx}, nil
+	case txRunnerBeginner:
+		return db.BeginTx(ctx, opts)
+	default:
+		return nil, fmt.Errorf("cannot begin a transaction with %T", db)
+	}
+}
+
+// IsRetryableTxError reports whether err is a serialization failure or a
+// deadlock, after which a transaction can be run again.
+//
+// Errors are classified without depending on drivers: PostgreSQL errors by
+// their SQLState method (SQLSTATE 40001 and 40P01), as implemented by pgx and
+// lib/pq, and MySQL errors by their Number field (error 1213), as implemented
+// by go-sql-driver/mysql.
+func IsRetryableTxError(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if e, ok := err.(interface{ SQLState() string }); ok {
+			switch e.SQLState() {
+			case "40001", "40P01":
+				return true
+			}
+		}
+
+		v := reflect.ValueOf(err)
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Struct {
+			if n := v.FieldByName("Number"); n.IsValid() && n.Kind() == reflect.Uint16 && n.Uint() == 1213 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,354 @@
+package sqrl
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sqlStateError is an error with a SQLSTATE, like the errors of pgx and
+// lib/pq.
+type sqlStateError string
+
+func (e sqlStateError) Error() string {
+	return "sqlstate " + string(e)
+}
+
+func (e sqlStateError) SQLState() string {
+	return string(e)
+}
+
+// mysqlError is shaped like the errors of go-sql-driver/mysql.
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string {
+	return e.Message
+}
+
+var fastRetry = TxRetryPolicy{MaxAttempts: 3}
+
+func TestInTx(t *testing.T) {
+	db, fake := newFakeDB()
+
+	opts := &sql.TxOptions{Isolation: sql.LevelSerializable}
+	err := InTx(context.Background(), db, opts, func(tx Runner) error {
+		_, err := Update("a").Set("b", 1).RunWith(tx).Exec()
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"begin Serializable",
+		"prepare UPDATE a SET b = ?",
+		"exec UPDATE a SET b = ? [1]",
+		"close UPDATE a SET b = ?",
+		"commit",
+	}, fake.Log())
+}
+
+func TestInTxRollback(t *testing.T) {
+	db, fake := newFakeDB()
+
+	boom := errors.New("boom")
+	err := InTx(context.Background(), db, nil, func(tx Runner) error {
+		return boom
+	})
+	assert.Equal(t, boom, err)
+	assert.Equal(t, []string{"begin", "rollback"}, fake.Log())
+}
+
+func TestInTxPanic(t *testing.T) {
+	db, fake := newFakeDB()
+
+	assert.PanicsWithValue(t, "boom", func() {
+		InTx(context.Background(), db, nil, func(tx Runner) error {
+			panic("boom")
+		})
+	})
+	assert.Equal(t, []string{"begin", "rollback"}, fake.Log())
+
+	err := InTx(context.Background(), db, nil, func(tx Runner) error { return nil })
+	assert.NoError(t, err, "the connection must be released")
+}
+
+func TestInTxRetry(t *testing.T) {
+	db, fake := newFakeDB()
+
+	failures := 2
+	fake.execErr = func(string) error {
+		if failures > 0 {
+			failures--
+			return fmt.Errorf("update: %w", sqlStateError("40001"))
+		}
+		return nil
+	}
+
+	attempts := 0
+	err := fastRetry.InTx(context.Background(), db, nil, func(tx Runner) error {
+		attempts++
+		_, err := tx.Exec("UPDATE a SET b = 1")
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, attempts)
+	assert.Equal(t, 3, fake.Count("begin"))
+	assert.Equal(t, 2, fake.Count("rollback"))
+	assert.Equal(t, 1, fake.Count("commit"))
+
+	failures = 3
+	attempts = 0
+	err = fastRetry.InTx(context.Background(), db, nil, func(tx Runner) error {
+		attempts++
+		_, err := tx.Exec("UPDATE a SET b = 1")
+		return err
+	})
+	assert.EqualError(t, err, "update: sqlstate 40001")
+	assert.Equal(t, 3, attempts, "attempts are limited")
+}
+
+func TestInTxRetryCommit(t *testing.T) {
+	db, fake := newFakeDB()
+
+	fake.commitErr = func() error {
+		fake.commitErr = nil
+		return &mysqlError{Number: 1213, Message: "Deadlock found"}
+	}
+
+	attempts := 0
+	err := fastRetry.InTx(context.Background(), db, nil, func(tx Runner) error {
+		attempts++
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, attempts)
+	assert.Equal(t, []string{"begin", "commit failed", "begin", "commit"}, fake.Log())
+}
+
+func TestInTxNoRetry(t *testing.T) {
+	db, _ := newFakeDB()
+
+	attempts := 0
+	err := fastRetry.InTx(context.Background(), db, nil, func(tx Runner) error {
+		attempts++
+		return sqlStateError("23505")
+	})
+	assert.Equal(t, sqlStateError("23505"), err)
+	assert.Equal(t, 1, attempts)
+
+	attempts = 0
+	policy := TxRetryPolicy{MaxAttempts: 2, Retryable: func(err error) bool { return true }}
+	err = policy.InTx(context.Background(), db, nil, func(tx Runner) error {
+		attempts++
+		return sqlStateError("23505")
+	})
+	assert.Equal(t, sqlStateError("23505"), err)
+	assert.Equal(t, 2, attempts, "Retryable overrides IsRetryableTxError")
+}
+
+func TestInTxCanceledBackoff(t *testing.T) {
+	db, _ := newFakeDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	policy := TxRetryPolicy{MaxAttempts: 3, MinBackoff: time.Hour, MaxBackoff: time.Hour}
+	err := policy.InTx(ctx, db, nil, func(tx Runner) error {
+		return sqlStateError("40P01")
+	})
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Contains(t, err.Error(), "(last error: "+sqlStateError("40P01").Error()+")")
+}
+
+func TestInTxStmtCacheProxy(t *testing.T) {
+	db, fake := newFakeDB()
+	db.SetMaxOpenConns(2)
+	proxy := NewStmtCacheProxy(db)
+	defer proxy.Close()
+
+	err := InTx(context.Background(), proxy, nil, func(tx Runner) error {
+		_, err := Delete("a").RunWith(tx).Exec()
+		return err
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, fake.Count("exec DELETE FROM a"))
+	assert.Equal(t, 1, fake.Count("commit"))
+	assert.Equal(t, uint64(1), proxy.Stats().Misses)
+}
+
+func TestInTxUnsupported(t *testing.T) {
+	err := InTx(context.Background(), &DBStub{}, nil, func(tx Runner) error { return nil })
+	assert.EqualError(t, err, "cannot begin a transaction with *sqrl.DBStub")
+}
+
+func TestIsRetryableTxError(t *testing.T) {
+	assert.True(t, IsRetryableTxError(sqlStateError("40001")))
+	assert.True(t, IsRetryableTxError(sqlStateError("40P01")))
+	assert.True(t, IsRetryableTxError(fmt.Errorf("commit: %w", &mysqlError{Number: 1213})))
+	assert.False(t, IsRetryableTxError(sqlStateError("23505")))
+	assert.False(t, IsRetryableTxError(&mysqlError{Number: 1062}))
+	assert.False(t, IsRetryableTxError((*mysqlError)(nil)))
+	assert.False(t, IsRetryableTxError(errors.New("40001")))
+	assert.False(t, IsRetryableTxError(nil))
+}
+
+func TestTxRetryPolicyBackoff(t *testing.T) {
+	p := TxRetryPolicy{MinBackoff: time.Millisecond, MaxBackoff: 3 * time.Millisecond}
+	for i := 0; i < 100; i++ {
+		assert.True(t, p.backoff(1) <= time.Millisecond)
+		assert.True(t, p.backoff(2) <= 2*time.Millisecond)
+		assert.True(t, p.backoff(10) <= 3*time.Millisecond)
+	}
+	assert.Equal(t, time.Duration(0), TxRetryPolicy{}.backoff(1))
+}
+
+// execLog returns the statements executed with db.
+func execLog(fake *fakeDB) []string {
+	var log []string
+	for _, l := range fake.Log() {
+		if strings.HasPrefix(l, "exec ") || l == "commit" || l == "rollback" {
+			log = append(log, strings.TrimSuffix(strings.TrimPrefix(l, "exec "), " []"))
+		}
+	}
+	return log
+}
+
+func nextSavepoints(n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("sp_%d", atomic.LoadUint64(&savepointID)+uint64(i)+1)
+	}
+	return names
+}
+
+func TestInTxSavepoint(t *testing.T) {
+	db, fake := newFakeDB()
+	sp := nextSavepoints(3)
+	boom := errors.New("boom")
+
+	err := InTx(context.Background(), db, nil, func(tx Runner) error {
+		tx.Exec("UPDATE a")
+		err := InTx(context.Background(), tx, nil, func(tx Runner) error {
+			tx.Exec("UPDATE b")
+			return InTx(context.Background(), tx, nil, func(tx Runner) error {
+				_, err := tx.Exec("UPDATE c")
+				return err
+			})
+		})
+		assert.NoError(t, err)
+
+		err = InTx(context.Background(), tx, &sql.TxOptions{ReadOnly: true}, func(tx Runner) error {
+			tx.Exec("UPDATE d")
+			return boom
+		})
+		assert.Equal(t, boom, err)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"UPDATE a",
+		"SAVEPOINT " + sp[0],
+		"UPDATE b",
+		"SAVEPOINT " + sp[1],
+		"UPDATE c",
+		"RELEASE SAVEPOINT " + sp[1],
+		"RELEASE SAVEPOINT " + sp[0],
+		"SAVEPOINT " + sp[2],
+		"UPDATE d",
+		"ROLLBACK TO SAVEPOINT " + sp[2],
+		"commit",
+	}, execLog(fake))
+}
+
+func TestInTxSavepointPanic(t *testing.T) {
+	db, fake := newFakeDB()
+	sp := nextSavepoints(1)
+
+	assert.Panics(t, func() {
+		InTx(context.Background(), db, nil, func(tx Runner) error {
+			return InTx(context.Background(), tx, nil, func(tx Runner) error {
+				panic("boom")
+			})
+		})
+	})
+	assert.Equal(t, []string{
+		"SAVEPOINT " + sp[0],
+		"ROLLBACK TO SAVEPOINT " + sp[0],
+		"rollback",
+	}, execLog(fake))
+}
+
+func TestInTxSavepointSqlTx(t *testing.T) {
+	db, fake := newFakeDB()
+	sp := nextSavepoints(1)
+
+	tx, err := db.Begin()
+	assert.NoError(t, err)
+	err = InTx(context.Background(), tx, nil, func(tx Runner) error {
+		_, err := Delete("a").RunWith(tx).Exec()
+		return err
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit())
+
+	assert.Equal(t, []string{
+		"SAVEPOINT " + sp[0],
+		"DELETE FROM a",
+		"RELEASE SAVEPOINT " + sp[0],
+		"commit",
+	}, execLog(fake))
+}
+
+func TestInTxSavepointRetry(t *testing.T) {
+	db, fake := newFakeDB()
+
+	fail := true
+	fake.execErr = func(query string) error {
+		if query == "UPDATE b" && fail {
+			fail = false
+			return sqlStateError("40001")
+		}
+		return nil
+	}
+
+	inner := 0
+	err := fastRetry.InTx(context.Background(), db, nil, func(tx Runner) error {
+		return InTx(context.Background(), tx, nil, func(tx Runner) error {
+			inner++
+			_, err := tx.Exec("UPDATE b")
+			return err
+		})
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, inner, "the outer transaction is retried")
+	assert.Equal(t, 2, fake.Count("begin"))
+	assert.Equal(t, 1, fake.Count("exec ROLLBACK TO SAVEPOINT"))
+
+	fake.execErr = func(query string) error {
+		if strings.HasPrefix(query, "ROLLBACK TO") {
+			return errors.New("connection lost")
+		}
+		if query == "UPDATE b" {
+			return sqlStateError("40P01")
+		}
+		return nil
+	}
+	err = InTx(context.Background(), db, nil, func(tx Runner) error {
+		err := InTx(context.Background(), tx, nil, func(tx Runner) error {
+			_, err := tx.Exec("UPDATE b")
+			return err
+		})
+		assert.True(t, IsRetryableTxError(err), "the error of fn must be kept")
+		assert.Contains(t, err.Error(), "rollback to savepoint: connection lost")
+		return nil
+	})
+	assert.NoError(t, err)
+}
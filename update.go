@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
-	"strings"
 )
 
 type setClause struct {
@@ -36,6 +35,11 @@ type UpdateBuilder struct {
 	offsetValid bool
 
 	suffixes exprs
+
+	// unscoped and withDeleted disable the scopes of the query, see Unscoped
+	// and WithDeleted.
+	unscoped    bool
+	withDeleted bool
 }
 
 // NewUpdateBuilder creates new instance of UpdateBuilder
@@ -43,9 +47,51 @@ func NewUpdateBuilder(b StatementBuilderType) *UpdateBuilder {
 	return &UpdateBuilder{StatementBuilderType: b}
 }
 
-// RunWith sets a Runner (like database/sql.DB) to be used with e.g. Exec.
-func (b *UpdateBuilder) RunWith(runner BaseRunner) *UpdateBuilder {
-	b.runWith = wrapRunner(runner)
+// Clone returns a deep copy of the builder.
+//
+// Modifying the clone, including subqueries added with FromSelect, never
+// affects the original builder and vice versa.
+func (b *UpdateBuilder) Clone() *UpdateBuilder {
+	c := *b
+	c.returning = b.returning.clone()
+	c.prefixes = b.prefixes.clone()
+	c.fromParts = cloneSqlizers(b.fromParts)
+	if b.setClauses != nil {
+		c.setClauses = make([]setClause, len(b.setClauses))
+		for i, clause := range b.setClauses {
+			c.setClauses[i] = setClause{column: clause.column, value: cloneValue(clause.value)}
+		}
+	}
+	c.whereParts = cloneSqlizers(b.whereParts)
+	c.orderBys = cloneStrings(b.orderBys)
+	c.suffixes = b.suffixes.clone()
+	return &c
+}
+
+// derive returns the builder that a modification should be applied to: b
+// itself, or a copy of b if the builder is immutable.
+//
+// The copy shares clause slices with b, but their capacity is capped so that
+// appending to them always allocates.
+func (b *UpdateBuilder) derive() *UpdateBuilder {
+	if !b.immutable {
+		return b
+	}
+	c := *b
+	c.returning = c.returning[:len(c.returning):len(c.returning)]
+	c.prefixes = c.prefixes[:len(c.prefixes):len(c.prefixes)]
+	c.fromParts = c.fromParts[:len(c.fromParts):len(c.fromParts)]
+	c.setClauses = c.setClauses[:len(c.setClauses):len(c.setClauses)]
+	c.whereParts = c.whereParts[:len(c.whereParts):len(c.whereParts)]
+	c.orderBys = c.orderBys[:len(c.orderBys):len(c.orderBys)]
+	c.suffixes = c.suffixes[:len(c.suffixes):len(c.suffixes)]
+	return &c
+}
+
+// RunWith sets a Runner (like database/sql.DB or sql.Conn) to be used with e.g. Exec.
+func (b *UpdateBuilder) RunWith(runner BaseRunnerContext) *UpdateBuilder {
+	b = b.derive()
+	b.runWith = adaptRunner(runner)
 	return b
 }
 
@@ -100,25 +146,59 @@ func (b *UpdateBuilder) Scan(dest ...interface{}) error {
 // PlaceholderFormat sets PlaceholderFormat (e.g. Question or Dollar) for the
 // query.
 func (b *UpdateBuilder) PlaceholderFormat(f PlaceholderFormat) *UpdateBuilder {
+	b = b.derive()
 	b.placeholderFormat = f
 	return b
 }
 
 // ToSql builds the query into a SQL string and bound args.
 func (b *UpdateBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
+	return statementToSql(b, b.StatementBuilderType)
+}
+
+// Tag adds a key/value tag that ToSql appends to the query as a sqlcommenter
+// comment, e.g. "/*route='%2Fusers'*/". Keys and values are URL-encoded.
+//
+// Tags are only rendered for top-level statements, not for subqueries.
+func (b *UpdateBuilder) Tag(key, value string) *UpdateBuilder {
+	b = b.derive()
+	b.tags = b.tags.with(key, value)
+	return b
+}
+
+// Unscoped disables the default scopes of the query, see
+// StatementBuilderType.Scope. Subqueries keep their scopes.
+func (b *UpdateBuilder) Unscoped() *UpdateBuilder {
+	b = b.derive()
+	b.unscoped = true
+	return b
+}
+
+// WithDeleted disables the scopes of soft-deleted tables of the query, so
+// that it sees deleted rows too, see StatementBuilderType.SoftDelete.
+// Subqueries keep their scopes.
+func (b *UpdateBuilder) WithDeleted() *UpdateBuilder {
+	b = b.derive()
+	b.withDeleted = true
+	return b
+}
+
+// AppendSql implements SqlAppender
+func (b *UpdateBuilder) AppendSql(sql *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	var err error
+
 	if len(b.table) == 0 {
-		err = fmt.Errorf("update statements must specify a table")
-		return
+		return nil, fmt.Errorf("update statements must specify a table")
 	}
 	if len(b.setClauses) == 0 {
-		err = fmt.Errorf("update statements must have at least one Set clause")
-		return
+		return nil, fmt.Errorf("update statements must have at least one Set clause")
 	}
 
-	sql := &bytes.Buffer{}
-
 	if len(b.prefixes) > 0 {
-		args, _ = b.prefixes.AppendToSql(sql, " ", args)
+		args, err = b.prefixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 		sql.WriteString(" ")
 	}
 
@@ -126,30 +206,29 @@ func (b *UpdateBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 	sql.WriteString(b.table)
 
 	sql.WriteString(" SET ")
-	setSqls := make([]string, len(b.setClauses))
 	for i, setClause := range b.setClauses {
-		var valSql string
+		if i > 0 {
+			sql.WriteString(", ")
+		}
+		sql.WriteString(setClause.column)
+		sql.WriteString(" = ")
 		switch typedVal := setClause.value.(type) {
 		case Sqlizer:
-			var valArgs []interface{}
-			valSql, valArgs, err = typedVal.ToSql()
+			args, err = appendSql(sql, typedVal, args)
 			if err != nil {
-				return
+				return nil, err
 			}
-			args = append(args, valArgs...)
 		default:
-			valSql = "?"
+			sql.WriteByte('?')
 			args = append(args, typedVal)
 		}
-		setSqls[i] = fmt.Sprintf("%s = %s", setClause.column, valSql)
 	}
-	sql.WriteString(strings.Join(setSqls, ", "))
 
 	if len(b.fromParts) > 0 {
 		sql.WriteString(" FROM ")
 		args, err = appendToSql(b.fromParts, sql, ", ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
@@ -157,13 +236,13 @@ func (b *UpdateBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 		sql.WriteString(" WHERE ")
 		args, err = appendToSql(b.whereParts, sql, " AND ", args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.orderBys) > 0 {
 		sql.WriteString(" ORDER BY ")
-		sql.WriteString(strings.Join(b.orderBys, ", "))
+		writeStrings(sql, b.orderBys, ", ")
 	}
 
 	// TODO: limit == 0 and offswt == 0 are valid. Need to go dbr way and implement offsetValid and limitValid
@@ -180,48 +259,54 @@ func (b *UpdateBuilder) ToSql() (sqlStr string, args []interface{}, err error) {
 	if len(b.returning) > 0 {
 		args, err = b.returning.AppendToSql(sql, args)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
 	if len(b.suffixes) > 0 {
 		sql.WriteString(" ")
-		args, _ = b.suffixes.AppendToSql(sql, " ", args)
+		args, err = b.suffixes.AppendToSql(sql, " ", args)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	sqlStr, err = b.placeholderFormat.ReplacePlaceholders(sql.String())
-	return
+	return args, nil
 }
 
 // SQL methods
 
 // Prefix adds an expression to the beginning of the query
 func (b *UpdateBuilder) Prefix(sql string, args ...interface{}) *UpdateBuilder {
+	b = b.derive()
 	b.prefixes = append(b.prefixes, Expr(sql, args...))
 	return b
 }
 
 // Table sets the table to be updateb.
 func (b *UpdateBuilder) Table(table string) *UpdateBuilder {
+	b = b.derive()
 	b.table = table
 	return b
 }
 
 // Set adds SET clauses to the query.
 func (b *UpdateBuilder) Set(column string, value interface{}) *UpdateBuilder {
+	b = b.derive()
 	b.setClauses = append(b.setClauses, setClause{column: column, value: value})
 	return b
 }
 
 // SetMap is a convenience method which calls .Set for each key/value pair in clauses.
 func (b *UpdateBuilder) SetMap(clauses map[string]interface{}) *UpdateBuilder {
+	b = b.derive()
 	keys := make([]string, 0, len(clauses))
 	for key := range clauses {
 		keys = append(keys, key)
 	}
 	sort.Strings(keys)
 	for _, key := range keys {
-		b.Set(key, clauses[key])
+		b.setClauses = append(b.setClauses, setClause{column: key, value: clauses[key]})
 	}
 	return b
 }
@@ -230,6 +315,7 @@ func (b *UpdateBuilder) SetMap(clauses map[string]interface{}) *UpdateBuilder {
 //
 // See SelectBuilder.Where for more information.
 func (b *UpdateBuilder) Where(pred interface{}, args ...interface{}) *UpdateBuilder {
+	b = b.derive()
 	b.whereParts = append(b.whereParts, NewWherePart(pred, args...))
 	return b
 }
@@ -238,6 +324,7 @@ func (b *UpdateBuilder) Where(pred interface{}, args ...interface{}) *UpdateBuil
 //
 // UPDATE ... FROM is an PostgreSQL specific extension
 func (b *UpdateBuilder) From(tables ...string) *UpdateBuilder {
+	b = b.derive()
 	parts := make([]Sqlizer, len(tables))
 	for i, table := range tables {
 		parts[i] = newPart(table)
@@ -251,18 +338,21 @@ func (b *UpdateBuilder) From(tables ...string) *UpdateBuilder {
 //
 // UPDATE ... FROM is an PostgreSQL specific extension
 func (b *UpdateBuilder) FromSelect(from *SelectBuilder, alias string) *UpdateBuilder {
+	b = b.derive()
 	b.fromParts = append(b.fromParts, Alias(from, alias))
 	return b
 }
 
 // OrderBy adds ORDER BY expressions to the query.
 func (b *UpdateBuilder) OrderBy(orderBys ...string) *UpdateBuilder {
+	b = b.derive()
 	b.orderBys = append(b.orderBys, orderBys...)
 	return b
 }
 
 // Limit sets a LIMIT clause on the query.
 func (b *UpdateBuilder) Limit(limit uint64) *UpdateBuilder {
+	b = b.derive()
 	b.limit = limit
 	b.limitValid = true
 	return b
@@ -270,6 +360,7 @@ func (b *UpdateBuilder) Limit(limit uint64) *UpdateBuilder {
 
 // Offset sets a OFFSET clause on the query.
 func (b *UpdateBuilder) Offset(offset uint64) *UpdateBuilder {
+	b = b.derive()
 	b.offset = offset
 	b.offsetValid = true
 	return b
@@ -279,6 +370,7 @@ func (b *UpdateBuilder) Offset(offset uint64) *UpdateBuilder {
 //
 // UPDATE ... RETURNING is PostgreSQL specific extension
 func (b *UpdateBuilder) Returning(columns ...string) *UpdateBuilder {
+	b = b.derive()
 	b.returning.Returning(columns...)
 	return b
 }
@@ -287,12 +379,14 @@ func (b *UpdateBuilder) Returning(columns ...string) *UpdateBuilder {
 //
 // UPDATE ... RETURNING is PostgreSQL specific extension
 func (b *UpdateBuilder) ReturningSelect(from *SelectBuilder, alias string) *UpdateBuilder {
+	b = b.derive()
 	b.returning.ReturningSelect(from, alias)
 	return b
 }
 
 // Suffix adds an expression to the end of the query
 func (b *UpdateBuilder) Suffix(sql string, args ...interface{}) *UpdateBuilder {
+	b = b.derive()
 	b.suffixes = append(b.suffixes, Expr(sql, args...))
 	return b
 }
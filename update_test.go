@@ -148,3 +148,20 @@ func TestUpdateBuilderNoRunner(t *testing.T) {
 	err = b.Scan()
 	assert.Equal(t, ErrRunnerNotSet, err)
 }
+
+func TestUpdateBuilderClone(t *testing.T) {
+	base := Update("a").Set("b", 1).FromSelect(Select("c").From("d"), "e").Where("f = ?", 2)
+
+	clone := base.Clone().Set("g", Expr("g + ?", 3)).Where("h = ?", 4)
+	base.Returning("i")
+
+	sql, args, err := base.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE a SET b = ? FROM (SELECT c FROM d) AS e WHERE f = ? RETURNING i", sql)
+	assert.Equal(t, []interface{}{1, 2}, args)
+
+	sql, args, err = clone.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE a SET b = ?, g = g + ? FROM (SELECT c FROM d) AS e WHERE f = ? AND h = ?", sql)
+	assert.Equal(t, []interface{}{1, 3, 2, 4}, args)
+}
@@ -1,6 +1,9 @@
 package sqrl
 
-import "fmt"
+import (
+	"bytes"
+	"fmt"
+)
 
 type WherePart part
 
@@ -32,3 +35,21 @@ func (p WherePart) ToSql() (sql string, args []interface{}, err error) {
 	}
 	return
 }
+
+// AppendSql implements SqlAppender
+func (p WherePart) AppendSql(buf *bytes.Buffer, args []interface{}) ([]interface{}, error) {
+	switch pred := p.pred.(type) {
+	case nil:
+		// no-op
+	case Sqlizer:
+		return appendSql(buf, pred, args)
+	case map[string]interface{}:
+		return Eq(pred).AppendSql(buf, args)
+	case string:
+		buf.WriteString(pred)
+		args = append(args, p.args...)
+	default:
+		return nil, fmt.Errorf("expected string-keyed map or string, not %T", pred)
+	}
+	return args, nil
+}